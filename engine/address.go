@@ -0,0 +1,217 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/GoSec-Labs/StateStinger/utils/bech32"
+)
+
+// defaultBech32Prefix is the account prefix of the Cosmos Hub
+const defaultBech32Prefix = "cosmos"
+
+// addressRole is the kind of address a field expects
+type addressRole int
+
+const (
+	roleAccount addressRole = iota
+	roleValoper
+	roleValcons
+)
+
+// addressFieldRole guesses from a field name whether it holds an address
+// and of which kind, e.g. from_address, ValidatorAddress or signer
+func addressFieldRole(name string) (addressRole, bool) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "valcons") || strings.Contains(lower, "cons_address") || strings.Contains(lower, "consaddress"):
+		return roleValcons, true
+	case strings.Contains(lower, "validator") || strings.Contains(lower, "valoper"):
+		return roleValoper, true
+	case strings.Contains(lower, "address"):
+		return roleAccount, true
+	}
+	switch lower {
+	case "sender", "signer", "creator", "authority", "owner", "recipient",
+		"receiver", "delegator", "granter", "grantee", "admin", "from", "to":
+		return roleAccount, true
+	}
+	return roleAccount, false
+}
+
+// scalarRole maps cosmos_proto.scalar annotations to address roles
+func scalarRole(scalar string) (addressRole, bool) {
+	switch scalar {
+	case "cosmos.AddressString":
+		return roleAccount, true
+	case "cosmos.ValidatorAddressString":
+		return roleValoper, true
+	case "cosmos.ConsensusAddressString":
+		return roleValcons, true
+	}
+	return roleAccount, false
+}
+
+// AddressGenerator produces valid, nearly valid and confused bech32 addresses
+type AddressGenerator struct {
+	rand *rand.Rand
+	hrps [3]string // Account, validator operator and consensus prefixes
+}
+
+func NewAddressGenerator(r *rand.Rand, prefix string) *AddressGenerator {
+	if prefix == "" {
+		prefix = defaultBech32Prefix
+	}
+	return &AddressGenerator{
+		rand: r,
+		hrps: [3]string{prefix, prefix + "valoper", prefix + "valcons"},
+	}
+}
+
+// payload returns random address bytes: 20 bytes for keys, 32 for module
+// and interchain accounts
+func (g *AddressGenerator) payload() []byte {
+	size := 20
+	if g.rand.Intn(4) == 0 {
+		size = 32
+	}
+	data := make([]byte, size)
+	g.rand.Read(data)
+	return data
+}
+
+func (g *AddressGenerator) encode(hrp string, data []byte, enc bech32.Encoding) string {
+	s, err := bech32.Encode(hrp, data, enc)
+	if err != nil {
+		return hrp + "1"
+	}
+	return s
+}
+
+// Valid returns a well-formed address with the prefix of role
+func (g *AddressGenerator) Valid(role addressRole) string {
+	return g.encode(g.hrps[role], g.payload(), bech32.Bech32)
+}
+
+// addressVariants are the ways an address can be subtly wrong
+var addressVariants = []func(g *AddressGenerator, role addressRole) string{
+	// Cross-prefix confusion, e.g. a valoper address where an account is expected
+	func(g *AddressGenerator, role addressRole) string {
+		other := addressRole((int(role) + 1 + g.rand.Intn(2)) % 3)
+		return g.encode(g.hrps[other], g.payload(), bech32.Bech32)
+	},
+	// Another chain's prefix
+	func(g *AddressGenerator, role addressRole) string {
+		prefixes := []string{"osmo", "juno", "evmos", "cosmosvaloper", "cosmospub", "x"}
+		return g.encode(prefixes[g.rand.Intn(len(prefixes))], g.payload(), bech32.Bech32)
+	},
+	// Wrong checksum
+	func(g *AddressGenerator, role addressRole) string {
+		s := []byte(g.Valid(role))
+		i := len(s) - 1 - g.rand.Intn(6)
+		s[i] = nextCharsetChar(s[i])
+		return string(s)
+	},
+	// Mixed case, which the spec forbids
+	func(g *AddressGenerator, role addressRole) string {
+		s := []byte(g.Valid(role))
+		var letters []int
+		for i := len(g.hrps[role]) + 1; i < len(s); i++ {
+			if s[i] >= 'a' && s[i] <= 'z' {
+				letters = append(letters, i)
+			}
+		}
+		if len(letters) > 0 {
+			i := letters[g.rand.Intn(len(letters))]
+			s[i] -= 'a' - 'A'
+		}
+		return string(s)
+	},
+	// All upper case, which the spec allows but string comparisons do not
+	func(g *AddressGenerator, role addressRole) string {
+		return strings.ToUpper(g.Valid(role))
+	},
+	// bech32m checksum where bech32 is expected
+	func(g *AddressGenerator, role addressRole) string {
+		return g.encode(g.hrps[role], g.payload(), bech32.Bech32m)
+	},
+	// Payload lengths the SDK's address verifier should reject
+	func(g *AddressGenerator, role addressRole) string {
+		sizes := []int{0, 1, 19, 21, 31, 33, 64, 255, 256}
+		data := make([]byte, sizes[g.rand.Intn(len(sizes))])
+		g.rand.Read(data)
+		return g.encode(g.hrps[role], data, bech32.Bech32)
+	},
+	// The all-zero address, which no key controls
+	func(g *AddressGenerator, role addressRole) string {
+		return g.encode(g.hrps[role], make([]byte, 20), bech32.Bech32)
+	},
+	// Broken structure: no separator, empty hrp, invalid characters
+	func(g *AddressGenerator, role addressRole) string {
+		s := g.Valid(role)
+		switch g.rand.Intn(4) {
+		case 0:
+			return strings.Replace(s, "1", "", 1)
+		case 1:
+			return s[len(g.hrps[role]):]
+		case 2:
+			return s[:len(s)-1] + "b"
+		}
+		return s + strings.Repeat("q", 90)
+	},
+	// Not bech32 at all
+	func(g *AddressGenerator, role addressRole) string {
+		cases := []string{"", " ", fmt.Sprintf("0x%040x", g.rand.Uint64()), g.hrps[role] + "1", g.hrps[role]}
+		return cases[g.rand.Intn(len(cases))]
+	},
+}
+
+// nextCharsetChar replaces a bech32 character with a different valid one
+func nextCharsetChar(c byte) byte {
+	i := strings.IndexByte(bech32.Charset, c)
+	return bech32.Charset[(i+1)%len(bech32.Charset)]
+}
+
+// Confused returns an address that is wrong in one way for role
+func (g *AddressGenerator) Confused(role addressRole) string {
+	return addressVariants[g.rand.Intn(len(addressVariants))](g, role)
+}
+
+// Generate returns a valid address validPercent of the time and a
+// confused one otherwise
+func (g *AddressGenerator) Generate(role addressRole, validPercent int) string {
+	if g.rand.Intn(100) < validPercent {
+		return g.Valid(role)
+	}
+	return g.Confused(role)
+}
+
+// AddressMutator sends bech32 addresses, valid and subtly broken ones, as
+// the payload after the handler and outcome bytes
+type AddressMutator struct {
+	BaseMutator
+	addresses    *AddressGenerator
+	validPercent int
+}
+
+func NewAddressMutator(r *rand.Rand, prefix string) *AddressMutator {
+	return &AddressMutator{
+		BaseMutator: BaseMutator{
+			rand: r,
+			name: "AddressMutator",
+		},
+		addresses:    NewAddressGenerator(r, prefix),
+		validPercent: 30,
+	}
+}
+
+func (m *AddressMutator) GenerateFuzzInput() []byte {
+	input := []byte{byte(m.rand.Intn(256)), byte(m.rand.Intn(256))}
+	role := addressRole(m.rand.Intn(3))
+	return append(input, m.addresses.Generate(role, m.validPercent)...)
+}
+
+func (m *AddressMutator) ValidateOutput(output []byte, err error) *FuzzResult {
+	return nil
+}
@@ -0,0 +1,203 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// checkpointFile is the name of the checkpoint kept in the output directory
+const checkpointFile = "checkpoint.json"
+
+// CheckpointableMutator is implemented by mutators, and oracles, that carry
+// state from one input to the next, so that state survives a checkpoint and
+// resume
+type CheckpointableMutator interface {
+	MarshalState() ([]byte, error)
+	UnmarshalState(data []byte) error
+}
+
+// Checkpoint is the persisted state of a campaign at a round boundary.
+// Worker RNGs are reseeded at every boundary, so the round number stands in
+// for their state.
+type Checkpoint struct {
+	Seed            int64
+	Workers         int
+	CheckpointEvery int
+	Next            int // First iteration not yet run
+	Summary         FuzzSummary
+	MutatorState    []map[string][]byte // Per worker, keyed by mutator name
+	OracleState     []map[string][]byte // Per worker, keyed by oracle name
+	State           [][]byte            // Per worker, the store built from execution deltas
+	DiffState       [][]byte            // Per worker, the store of the -diff-target module
+	History         [][]byte            // Per worker, the executions findings are replayed from
+	Corpus          []string            // Corpus entry keys in index order
+	Buckets         []*Bucket
+	Scheduler       schedulerState
+}
+
+// saveCheckpoint writes the current campaign state to the output directory
+func (f *FuzzEngine) saveCheckpoint() error {
+	checkpoint := Checkpoint{
+		Seed:            f.seed,
+		Workers:         len(f.workers),
+		CheckpointEvery: f.config.CheckpointEvery,
+		Next:            f.next,
+		Summary:         f.summary,
+		MutatorState:    make([]map[string][]byte, len(f.workers)),
+		OracleState:     make([]map[string][]byte, len(f.workers)),
+		State:           make([][]byte, len(f.workers)),
+		DiffState:       make([][]byte, len(f.workers)),
+		History:         make([][]byte, len(f.workers)),
+		Buckets:         f.dedup.Buckets(),
+		Scheduler:       f.sched.state(),
+	}
+
+	for i, w := range f.workers {
+		checkpoint.MutatorState[i] = make(map[string][]byte)
+		for _, mutator := range w.mutators {
+			if cm, ok := mutator.(CheckpointableMutator); ok {
+				state, err := cm.MarshalState()
+				if err != nil {
+					return fmt.Errorf("failed to save state of %s: %v", mutator.Name(), err)
+				}
+				checkpoint.MutatorState[i][mutator.Name()] = state
+			}
+		}
+
+		checkpoint.OracleState[i] = make(map[string][]byte)
+		for _, oracle := range w.oracles {
+			if cm, ok := oracle.(CheckpointableMutator); ok {
+				state, err := cm.MarshalState()
+				if err != nil {
+					return fmt.Errorf("failed to save state of oracle %s: %v", oracle.Name(), err)
+				}
+				checkpoint.OracleState[i][oracle.Name()] = state
+			}
+		}
+
+		state, err := w.state.MarshalState()
+		if err != nil {
+			return fmt.Errorf("failed to save store of worker %d: %v", i, err)
+		}
+		checkpoint.State[i] = state
+
+		if w.diff != nil {
+			if checkpoint.DiffState[i], err = w.diffState.MarshalState(); err != nil {
+				return fmt.Errorf("failed to save diff store of worker %d: %v", i, err)
+			}
+		}
+
+		if checkpoint.History[i], err = w.history.MarshalState(); err != nil {
+			return fmt.Errorf("failed to save history of worker %d: %v", i, err)
+		}
+	}
+
+	if f.corpus != nil {
+		checkpoint.Corpus = f.corpus.Keys()
+	}
+
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves a torn checkpoint
+	filename := filepath.Join(f.config.OutputDir, checkpointFile)
+	if err := os.WriteFile(filename+".tmp", data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(filename+".tmp", filename); err != nil {
+		return err
+	}
+
+	if err := f.dedup.Save(filepath.Join(f.config.OutputDir, "findings.json")); err != nil {
+		return fmt.Errorf("failed to save findings index: %v", err)
+	}
+
+	if f.config.Verbose {
+		log.Printf("Checkpoint saved at iteration %d", f.next)
+	}
+
+	return nil
+}
+
+// loadCheckpoint reads a checkpoint written by saveCheckpoint
+func loadCheckpoint(filename string) (*Checkpoint, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", filename, err)
+	}
+
+	return &checkpoint, nil
+}
+
+// restore brings a freshly built engine to the state recorded in checkpoint
+func (f *FuzzEngine) restore(checkpoint *Checkpoint) error {
+	f.next = checkpoint.Next
+	f.summary = checkpoint.Summary
+	f.dedup.Restore(checkpoint.Buckets)
+	f.sched.restore(checkpoint.Scheduler)
+
+	if f.corpus != nil {
+		f.corpus.Reorder(checkpoint.Corpus)
+	}
+
+	for i, w := range f.workers {
+		if i >= len(checkpoint.MutatorState) {
+			break
+		}
+		for _, mutator := range w.mutators {
+			state, ok := checkpoint.MutatorState[i][mutator.Name()]
+			if !ok {
+				continue
+			}
+			if cm, ok := mutator.(CheckpointableMutator); ok {
+				if err := cm.UnmarshalState(state); err != nil {
+					return fmt.Errorf("failed to restore state of %s: %v", mutator.Name(), err)
+				}
+			}
+		}
+
+		if i < len(checkpoint.OracleState) {
+			for _, oracle := range w.oracles {
+				state, ok := checkpoint.OracleState[i][oracle.Name()]
+				if !ok {
+					continue
+				}
+				if cm, ok := oracle.(CheckpointableMutator); ok {
+					if err := cm.UnmarshalState(state); err != nil {
+						return fmt.Errorf("failed to restore state of oracle %s: %v", oracle.Name(), err)
+					}
+				}
+			}
+		}
+		if i < len(checkpoint.State) && checkpoint.State[i] != nil {
+			if err := w.state.UnmarshalState(checkpoint.State[i]); err != nil {
+				return fmt.Errorf("failed to restore store of worker %d: %v", i, err)
+			}
+		}
+		if w.diff != nil && i < len(checkpoint.DiffState) && checkpoint.DiffState[i] != nil {
+			if err := w.diffState.UnmarshalState(checkpoint.DiffState[i]); err != nil {
+				return fmt.Errorf("failed to restore diff store of worker %d: %v", i, err)
+			}
+		}
+		if i < len(checkpoint.History) && checkpoint.History[i] != nil {
+			if err := w.history.UnmarshalState(checkpoint.History[i]); err != nil {
+				return fmt.Errorf("failed to restore history of worker %d: %v", i, err)
+			}
+		}
+	}
+
+	log.Printf("Resuming campaign at iteration %d with %d unique findings",
+		f.next, f.dedup.Len())
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bankModule creates a minimal bank module for the simulated target
+func bankModule(t *testing.T) string {
+	return writeModule(t, map[string]string{
+		"types/types.go": "package types\n",
+		"keeper/msg_server.go": "package keeper\n" +
+			"func HandleMsgSend(ctx context.Context, msg *types.MsgSend) error { return nil }\n" +
+			"func HandleMsgMultiSend(ctx context.Context, msg *types.MsgMultiSend) error { return nil }\n",
+	})
+}
+
+// campaignConfigs are the campaigns the checkpoint tests run
+var campaignConfigs = []struct {
+	name  string
+	setup func(config *Config)
+}{
+	{"single worker", func(config *Config) {}},
+	{"workers", func(config *Config) {
+		config.Workers = 3
+	}},
+	{"coverage and adaptive weights", func(config *Config) {
+		config.Workers = 3
+		config.Coverage = true
+		config.Adaptive = true
+	}},
+	{"stateful mutators and oracles", func(config *Config) {
+		config.Workers = 2
+		config.StateMutator = []string{"StatefulMutator", "CoinMutator"}
+		config.Oracles = []string{"crash", "state", "bank", "coins", "mutator"}
+	}},
+}
+
+// campaign returns the configuration of a campaign against target
+func campaign(target, output string, count int, setup func(config *Config)) Config {
+	config := defaultConfig()
+	config.TargetPath = target
+	config.ModuleName = "bank"
+	config.OutputDir = output
+	config.Seed = 7
+	config.FuzzCount = count
+	config.CheckpointEvery = 500
+	setup(&config)
+	return config
+}
+
+// readOutput returns the files of a campaign's output directory by name
+func readOutput(t *testing.T, dir string) map[string][]byte {
+	files := make(map[string][]byte)
+	require.NoError(t, filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[rel], err = os.ReadFile(path)
+		return err
+	}))
+	return files
+}
+
+// TestCheckpointRoundTrip saves a checkpoint, restores it into a fresh
+// engine and saves it again, which must give the same checkpoint
+func TestCheckpointRoundTrip(t *testing.T) {
+	target := bankModule(t)
+	for _, tt := range campaignConfigs {
+		t.Run(tt.name, func(t *testing.T) {
+			output := t.TempDir()
+			NewFuzzerEngine(campaign(target, output, 1000, tt.setup)).Run(context.Background())
+
+			filename := filepath.Join(output, checkpointFile)
+			saved, err := os.ReadFile(filename)
+			require.NoError(t, err)
+			checkpoint, err := loadCheckpoint(filename)
+			require.NoError(t, err)
+			assert.Equal(t, 1000, checkpoint.Next)
+			assert.Equal(t, int64(7), checkpoint.Seed)
+			assert.Len(t, checkpoint.State, checkpoint.Workers)
+			assert.Len(t, checkpoint.History, checkpoint.Workers)
+
+			config := campaign(target, output, 1000, tt.setup)
+			config.Resume = true
+			resumed := NewFuzzerEngine(config)
+			require.NoError(t, resumed.saveCheckpoint())
+
+			again, err := os.ReadFile(filename)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(saved), string(again))
+		})
+	}
+}
+
+// TestResumeMatchesUninterruptedRun checks that a campaign stopped at a
+// checkpoint and resumed writes exactly what it would have written in one go
+func TestResumeMatchesUninterruptedRun(t *testing.T) {
+	target := bankModule(t)
+	for _, tt := range campaignConfigs {
+		t.Run(tt.name, func(t *testing.T) {
+			whole, split := t.TempDir(), t.TempDir()
+			NewFuzzerEngine(campaign(target, whole, 1500, tt.setup)).Run(context.Background())
+			NewFuzzerEngine(campaign(target, split, 500, tt.setup)).Run(context.Background())
+
+			config := campaign(target, split, 1500, tt.setup)
+			config.Resume = true
+			NewFuzzerEngine(config).Run(context.Background())
+
+			assert.Equal(t, readOutput(t, whole), readOutput(t, split))
+		})
+	}
+}
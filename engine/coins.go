@@ -0,0 +1,562 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoSec-Labs/StateStinger/utils/target/cosmossdk"
+)
+
+// denomPattern is the SDK's default coin denomination format
+var denomPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9/:._-]{2,127}$`)
+
+// decPattern is the string form math.LegacyDec parses, at most 18 decimals
+var decPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]{1,18})?$`)
+
+// maxDecBits is the widest LegacyDec the SDK accepts, counted on the value
+// scaled by 10^18
+const maxDecBits = 256 + 60
+
+var (
+	decPrecision = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	maxDecRaw    = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), maxDecBits), big.NewInt(1))
+)
+
+// formatDec formats a LegacyDec from its value scaled by 10^18
+func formatDec(raw *big.Int) string {
+	sign := ""
+	if raw.Sign() < 0 {
+		sign = "-"
+	}
+	whole, frac := new(big.Int).QuoRem(new(big.Int).Abs(raw), decPrecision, new(big.Int))
+	return fmt.Sprintf("%s%s.%018s", sign, whole, frac)
+}
+
+// CoinGenerator produces well-formed and malformed Coin and DecCoin values
+// in their JSON form
+type CoinGenerator struct {
+	rand      *rand.Rand
+	addresses *AddressGenerator
+}
+
+func NewCoinGenerator(r *rand.Rand, addresses *AddressGenerator) *CoinGenerator {
+	return &CoinGenerator{
+		rand:      r,
+		addresses: addresses,
+	}
+}
+
+// Denom returns a denomination the SDK accepts: a base denom, an IBC
+// voucher, a token factory denom or one at the length limit
+func (g *CoinGenerator) Denom() string {
+	switch g.rand.Intn(6) {
+	case 0:
+		hash := make([]byte, 32)
+		g.rand.Read(hash)
+		return fmt.Sprintf("ibc/%X", hash)
+	case 1:
+		return fmt.Sprintf("factory/%s/%s", g.addresses.Valid(roleAccount), g.baseDenom())
+	case 2:
+		return fmt.Sprintf("gamm/pool/%d", 1+g.rand.Intn(1000))
+	case 3:
+		return "u" + strings.Repeat("x", 127)
+	}
+	return g.baseDenom()
+}
+
+func (g *CoinGenerator) baseDenom() string {
+	denoms := []string{"stake", "uatom", "uosmo", "ujuno", "aevmos", "atom", "usdc", "wei"}
+	return denoms[g.rand.Intn(len(denoms))]
+}
+
+// invalidDenom returns a denomination the SDK must reject
+func (g *CoinGenerator) invalidDenom() string {
+	denoms := []string{
+		"",
+		"a",
+		"ab",
+		"1atom",
+		"u atom",
+		"uatom!",
+		"ÅTOM",
+		"/uatom",
+		"uatom\x00",
+		"u" + strings.Repeat("x", 128),
+	}
+	return denoms[g.rand.Intn(len(denoms))]
+}
+
+// amount returns a positive math.Int, occasionally at the 256-bit limit
+func (g *CoinGenerator) amount() string {
+	switch g.rand.Intn(8) {
+	case 0:
+		return "1"
+	case 1:
+		return maxUint256.String()
+	case 2:
+		return new(big.Int).Add(new(big.Int).Rand(g.rand, maxUint256), big.NewInt(1)).String()
+	}
+	return fmt.Sprintf("%d", 1+g.rand.Int63n(1<<62))
+}
+
+// invalidAmount returns a math.Int no Coins value may hold: zero, negative,
+// beyond 256 bits or not an integer at all
+func (g *CoinGenerator) invalidAmount() string {
+	amounts := []string{
+		"0",
+		"-1",
+		new(big.Int).Neg(maxUint256).String(),
+		two256.String(),
+		new(big.Int).Add(two256, big.NewInt(1)).String(),
+		"",
+		"0x10",
+		"1e18",
+		" 1",
+	}
+	return amounts[g.rand.Intn(len(amounts))]
+}
+
+// decAmount returns a positive LegacyDec, at the precision limits some of
+// the time
+func (g *CoinGenerator) decAmount() string {
+	switch g.rand.Intn(6) {
+	case 0:
+		return formatDec(big.NewInt(1))
+	case 1:
+		return formatDec(maxDecRaw)
+	}
+	raw := new(big.Int).Rand(g.rand, new(big.Int).Lsh(big.NewInt(1), 100))
+	return formatDec(raw.Add(raw, big.NewInt(1)))
+}
+
+// invalidDecAmount returns a LegacyDec no DecCoins value may hold
+func (g *CoinGenerator) invalidDecAmount() string {
+	amounts := []string{
+		formatDec(big.NewInt(0)),
+		formatDec(big.NewInt(-1)),
+		"0.0000000000000000001",
+		"1.0000000000000000001",
+		formatDec(new(big.Int).Add(maxDecRaw, big.NewInt(1))),
+		"",
+		"1.",
+		"1e18",
+	}
+	return amounts[g.rand.Intn(len(amounts))]
+}
+
+func (g *CoinGenerator) validAmount(dec bool) string {
+	if dec {
+		return g.decAmount()
+	}
+	return g.amount()
+}
+
+// Coin returns a single coin, malformed unless valid is set
+func (g *CoinGenerator) Coin(dec bool, valid bool) map[string]interface{} {
+	coin := map[string]interface{}{
+		"denom":  g.Denom(),
+		"amount": g.validAmount(dec),
+	}
+	if valid {
+		return coin
+	}
+
+	switch g.rand.Intn(3) {
+	case 0:
+		coin["denom"] = g.invalidDenom()
+	case 1:
+		// A lone coin may be zero, so only negative and unparsable amounts count
+		if dec {
+			coin["amount"] = "-" + g.decAmount()
+		} else {
+			coin["amount"] = "-" + g.amount()
+		}
+	default:
+		coin["amount"] = json.Number(g.validAmount(dec))
+	}
+	return coin
+}
+
+// Coins returns a list of coins, sorted with distinct positive entries if
+// valid is set and broken in one way otherwise
+func (g *CoinGenerator) Coins(dec bool, valid bool) []interface{} {
+	n := g.rand.Intn(4)
+	if !valid && n < 2 {
+		n = 2
+	}
+
+	seen := make(map[string]bool)
+	denoms := make([]string, 0, n)
+	for len(denoms) < n {
+		denom := g.Denom()
+		if !seen[denom] {
+			seen[denom] = true
+			denoms = append(denoms, denom)
+		}
+	}
+	sort.Strings(denoms)
+
+	coins := make([]map[string]interface{}, n)
+	for i, denom := range denoms {
+		coins[i] = map[string]interface{}{
+			"denom":  denom,
+			"amount": g.validAmount(dec),
+		}
+	}
+
+	if !valid {
+		i := g.rand.Intn(n)
+		switch g.rand.Intn(5) {
+		case 0:
+			// Unsorted
+			coins[0], coins[n-1] = coins[n-1], coins[0]
+		case 1:
+			// Duplicate denomination, next to or apart from the original
+			duplicate := map[string]interface{}{
+				"denom":  coins[i]["denom"],
+				"amount": g.validAmount(dec),
+			}
+			coins = append(coins, nil)
+			j := i + 1 + g.rand.Intn(len(coins)-i-1)
+			copy(coins[j+1:], coins[j:])
+			coins[j] = duplicate
+		case 2:
+			coins[i]["denom"] = g.invalidDenom()
+		default:
+			if dec {
+				coins[i]["amount"] = g.invalidDecAmount()
+			} else {
+				coins[i]["amount"] = g.invalidAmount()
+			}
+		}
+	}
+
+	list := make([]interface{}, len(coins))
+	for i := range coins {
+		list[i] = coins[i]
+	}
+	return list
+}
+
+// coinType reports whether t is a Coin or DecCoin, or a list of them, for
+// both the SDK's types and ones the module declares itself
+func coinType(t *cosmossdk.TypeSchema) (list bool, dec bool, ok bool) {
+	if t.Kind == cosmossdk.KindSlice && t.Elem != nil && t.Elem.Kind != cosmossdk.KindSlice {
+		if _, dec, ok := coinType(t.Elem); ok {
+			return true, dec, true
+		}
+		return false, false, false
+	}
+
+	name := t.GoType
+	if t.Kind == cosmossdk.KindMessage {
+		name = t.Message
+	}
+	name = strings.TrimLeft(name, "*")
+	name = name[strings.LastIndexByte(name, '.')+1:]
+	switch name {
+	case "Coin":
+		return false, false, true
+	case "DecCoin":
+		return false, true, true
+	case "Coins":
+		return true, false, true
+	case "DecCoins":
+		return true, true, true
+	}
+	return false, false, false
+}
+
+// validateDenom checks a denomination against the SDK's default format
+func validateDenom(denom string) error {
+	if !denomPattern.MatchString(denom) {
+		return errors.New("invalid denom")
+	}
+	return nil
+}
+
+// parseCoinAmount parses a coin amount the way math.Int and math.LegacyDec
+// unmarshal it. Amounts with a decimal point are taken as LegacyDecs.
+func parseCoinAmount(value interface{}) (*big.Int, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, errors.New("amount is not a string")
+	}
+
+	if strings.Contains(s, ".") {
+		if !decPattern.MatchString(s) {
+			return nil, errors.New("invalid decimal amount")
+		}
+		whole, frac, _ := strings.Cut(s, ".")
+		raw, _ := new(big.Int).SetString(whole+frac+strings.Repeat("0", 18-len(frac)), 10)
+		if raw.BitLen() > maxDecBits {
+			return nil, errors.New("decimal amount out of range")
+		}
+		return raw, nil
+	}
+
+	amount, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, errors.New("invalid amount")
+	}
+	if amount.BitLen() > 256 {
+		return nil, errors.New("amount overflows 256 bits")
+	}
+	return amount, nil
+}
+
+// validateCoin checks a lone coin, which may be zero but not negative
+func validateCoin(coin map[string]interface{}) error {
+	if err := validateDenom(fmt.Sprint(coin["denom"])); err != nil {
+		return err
+	}
+	amount, err := parseCoinAmount(coin["amount"])
+	if err != nil {
+		return err
+	}
+	if amount.Sign() < 0 {
+		return errors.New("negative amount")
+	}
+	return nil
+}
+
+// validateCoins checks a list the way sdk.Coins.Validate does: positive
+// amounts and denominations sorted without duplicates
+func validateCoins(coins []interface{}) error {
+	prev := ""
+	for i, elem := range coins {
+		coin := elem.(map[string]interface{})
+		denom := fmt.Sprint(coin["denom"])
+		if err := validateDenom(denom); err != nil {
+			return err
+		}
+		amount, err := parseCoinAmount(coin["amount"])
+		if err != nil {
+			return err
+		}
+		if amount.Sign() <= 0 {
+			return errors.New("amount is not positive")
+		}
+		if i > 0 {
+			if denom == prev {
+				return errors.New("duplicate denomination")
+			}
+			if denom < prev {
+				return errors.New("denominations are not sorted")
+			}
+		}
+		prev = denom
+	}
+	return nil
+}
+
+// isCoin reports whether a decoded JSON value has the shape of a coin
+func isCoin(value interface{}) bool {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, hasDenom := object["denom"]
+	_, hasAmount := object["amount"]
+	return hasDenom && hasAmount
+}
+
+// checkCoins finds every coin and coin list in a decoded message and
+// returns the first validation error. Errors name the defect but not the
+// value, so findings deduplicate by kind.
+func checkCoins(value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if isCoin(v) {
+			return validateCoin(v)
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := checkCoins(v[key]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		list := len(v) > 0
+		for _, elem := range v {
+			list = list && isCoin(elem)
+		}
+		if list {
+			return validateCoins(v)
+		}
+		for _, elem := range v {
+			if err := checkCoins(elem); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// malformedCoins returns the first problem with the coins in the JSON
+// message of an input, or nil if they are valid or there is no message
+func malformedCoins(input []byte) error {
+	if len(input) < 3 || input[2] != '{' {
+		return nil
+	}
+
+	var message interface{}
+	decoder := json.NewDecoder(bytes.NewReader(input[2:]))
+	decoder.UseNumber()
+	if decoder.Decode(&message) != nil {
+		return nil
+	}
+	return checkCoins(message)
+}
+
+// acceptedMalformedCoins reports an input the target accepted although it
+// carries a coin value the SDK would have rejected. The coins oracle and
+// CoinMutator both judge inputs with it.
+func acceptedMalformedCoins(input []byte, err error) *FuzzResult {
+	if err != nil {
+		return nil
+	}
+	if invalid := malformedCoins(input); invalid != nil {
+		return &FuzzResult{
+			ID:              fmt.Sprintf("coins_%d", time.Now().UnixNano()),
+			Failed:          true,
+			AcceptedInvalid: true,
+			ErrorMessage:    "Handler accepted malformed coins: " + invalid.Error(),
+		}
+	}
+	return nil
+}
+
+// CoinMutator builds messages for the target's handlers with Coin, Coins,
+// DecCoin and DecCoins fields set to boundary and malformed values. Its
+// oracle flags handlers that accept a malformed value.
+type CoinMutator struct {
+	BaseMutator
+	target       *cosmossdk.CosmosModule
+	messages     *StructMutator // Fills in the fields around the coins
+	coins        *CoinGenerator
+	validPercent int
+}
+
+func NewCoinMutator(r *rand.Rand, target *cosmossdk.CosmosModule, dictionary [][]byte, prefix string) *CoinMutator {
+	messages := NewStructMutator(r, target, dictionary, prefix)
+	return &CoinMutator{
+		BaseMutator: BaseMutator{
+			rand: r,
+			name: "CoinMutator",
+		},
+		target:       target,
+		messages:     messages,
+		coins:        NewCoinGenerator(r, messages.addresses),
+		validPercent: 40,
+	}
+}
+
+func (m *CoinMutator) GenerateFuzzInput() []byte {
+	handler := 0
+	if len(m.target.Handlers) > 0 {
+		handler = m.rand.Intn(len(m.target.Handlers))
+	}
+	input := []byte{byte(handler), byte(m.rand.Intn(256))}
+
+	var message map[string]interface{}
+	if len(m.target.Handlers) > 0 {
+		if schema := m.target.MessageSchema(m.target.Handlers[handler]); schema != nil {
+			message = m.messages.generateMessage(schema, 0, false)
+			if !m.fillCoins(schema, message, 0) {
+				message = nil
+			}
+		}
+	}
+
+	// Handlers without a known message get a bare MsgSend-style amount
+	if message == nil {
+		message = map[string]interface{}{
+			"amount": m.coins.Coins(m.rand.Intn(4) == 0, m.valid()),
+		}
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		payload = []byte("{}")
+	}
+	return append(input, payload...)
+}
+
+func (m *CoinMutator) valid() bool {
+	return m.rand.Intn(100) < m.validPercent
+}
+
+// fillCoins replaces the coin fields of message, including ones in nested
+// messages, and reports whether there were any
+func (m *CoinMutator) fillCoins(schema *cosmossdk.StructSchema, message map[string]interface{}, depth int) bool {
+	found := false
+	for _, field := range schema.Fields {
+		if list, dec, ok := coinType(field.Type); ok {
+			if list {
+				message[field.JSONName] = m.coins.Coins(dec, m.valid())
+			} else {
+				message[field.JSONName] = m.coins.Coin(dec, m.valid())
+			}
+			found = true
+			continue
+		}
+		if depth >= maxStructDepth {
+			continue
+		}
+
+		t := field.Type
+		if t.Kind == cosmossdk.KindSlice && t.Elem != nil {
+			t = t.Elem
+		}
+		if t.Kind != cosmossdk.KindMessage {
+			continue
+		}
+		nested := m.target.Schema(t.Message)
+		if nested == nil {
+			continue
+		}
+
+		switch v := message[field.JSONName].(type) {
+		case map[string]interface{}:
+			found = m.fillCoins(nested, v, depth+1) || found
+		case []interface{}:
+			for _, elem := range v {
+				if object, ok := elem.(map[string]interface{}); ok {
+					found = m.fillCoins(nested, object, depth+1) || found
+				}
+			}
+		}
+	}
+	return found
+}
+
+// DecodeInput returns the JSON message of the input
+func (m *CoinMutator) DecodeInput(input []byte) json.RawMessage {
+	if len(input) < 3 || !json.Valid(input[2:]) {
+		return nil
+	}
+	return json.RawMessage(input[2:])
+}
+
+// ValidateInput reports any coin value the SDK would have rejected in an
+// input the target accepted
+func (m *CoinMutator) ValidateInput(input []byte, output []byte, err error) *FuzzResult {
+	return acceptedMalformedCoins(input, err)
+}
+
+func (m *CoinMutator) ValidateOutput(output []byte, err error) *FuzzResult {
+	return nil
+}
@@ -1,11 +1,20 @@
 package engine
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
 /*
@@ -14,26 +23,112 @@ This is will be an intery point for the fuzzing engine
 
 // Config hlds the global configuration for stateStinger
 type Config struct {
-	TargetPath   string // Path to the target binary
-	ModuleName   string // Name of the module to be fuzzed
-	FuzzCount    int
-	Seed         int64
-	OutputDir    string
-	Verbose      bool
-	StateMutator []string
-	SpecialCases bool
+	TargetPath      string        // Path to the target binary
+	ModuleName      string        // Name of the module to be fuzzed
+	FuzzCount       int           // Iterations to run, 0 for no limit
+	Duration        time.Duration // Wall-clock budget, 0 for no limit
+	Seed            int64
+	OutputDir       string
+	Verbose         bool
+	StateMutator    []string                     // Mutators to run by registry name, empty for the defaults
+	MutatorParams   map[string]map[string]string // Parameters per mutator name
+	Oracles         []string                     // Oracles to run by registry name, empty for the defaults
+	OracleParams    map[string]map[string]string // Parameters per oracle name
+	SpecialCases    bool
+	Workers         int                // Number of parallel fuzzing workers
+	Coverage        bool               // Keep inputs that reach new edges in OutputDir/corpus
+	Minimize        bool               // Also save a minimized copy of every failure
+	CheckpointEvery int                // Iterations between checkpoints, 0 to disable
+	Resume          bool               // Continue from the checkpoint in OutputDir
+	MutatorWeights  map[string]float64 // Static selection weight per mutator name
+	Adaptive        bool               // Reweight mutators by their yield during the run
+	Plugins         []string           // Executables serving out-of-process mutators
+	PluginTimeout   time.Duration      // How long a plugin may take to answer
+	Dictionaries    []string           // AFL-format token files
+	AutoDictionary  bool               // Add tokens found in the target's keeper/ and types/ sources
+	Bech32Prefix    string             // Account address prefix of the target chain
+	DiffTarget      string             // Second module directory every input is also run against
+	SDKPanics       bool               // Recover non-runtime panics into ErrPanic errors as baseapp does
+	Subprocess      bool               // Run the target in a harness child process per worker
+	ExecTimeout     time.Duration      // Per-input timeout in subprocess mode
+	MemoryLimit     int                // Memory limit of the harness in MB, 0 for none
 }
 
 var GlobalConfig Config
 
 func Execute() {
-	flag.StringVar(&GlobalConfig.TargetPath, "target", "", "Path to the Cosmos SDK module directory to fuzz")
-	flag.StringVar(&GlobalConfig.ModuleName, "module", "", "Name of the module to target")
-	flag.IntVar(&GlobalConfig.FuzzCount, "count", 5000, "Number of fuzzing iterations")
-	flag.Int64Var(&GlobalConfig.Seed, "seed", 0, "Random seed (0 for time-based)")
-	flag.StringVar(&GlobalConfig.OutputDir, "output", "./fuzz_results", "Directory to store results")
-	flag.BoolVar(&GlobalConfig.Verbose, "verbose", false, "Enable verbose output")
-	flag.BoolVar(&GlobalConfig.SpecialCases, "special", true, "Enable special case testing")
+	// Subcommands take over the whole command line
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "minimize":
+			runMinimize(os.Args[2:])
+			return
+		case "replay":
+			runReplay(os.Args[2:])
+			return
+		case "mutators":
+			runListMutators(os.Args[2:])
+			return
+		case "oracles":
+			runListOracles(os.Args[2:])
+			return
+		case "lint":
+			runLint(os.Args[2:])
+			return
+		case "harness":
+			runHarness(os.Args[2:])
+			return
+		case "gen-harness":
+			runGenHarness(os.Args[2:])
+			return
+		case "gofuzz":
+			runGoFuzz(os.Args[2:])
+			return
+		}
+	}
+
+	// Values from a config file become the defaults the flags override
+	GlobalConfig = defaultConfig()
+	if path := configFlag(os.Args[1:]); path != "" {
+		if err := loadConfigFile(path, &GlobalConfig); err != nil {
+			log.Fatalf("Error loading config file: %v", err)
+		}
+	}
+
+	flag.String("config", "", "JSON file with configuration, overridden by flags")
+	flag.StringVar(&GlobalConfig.TargetPath, "target", GlobalConfig.TargetPath, "Path to the Cosmos SDK module directory to fuzz")
+	flag.StringVar(&GlobalConfig.ModuleName, "module", GlobalConfig.ModuleName, "Name of the module to target")
+	flag.IntVar(&GlobalConfig.FuzzCount, "count", GlobalConfig.FuzzCount, "Number of fuzzing iterations (0 for no limit)")
+	flag.DurationVar(&GlobalConfig.Duration, "duration", GlobalConfig.Duration, "Stop fuzzing after this long, e.g. 8h (0 for no limit)")
+	flag.Int64Var(&GlobalConfig.Seed, "seed", GlobalConfig.Seed, "Random seed (0 for time-based)")
+	flag.StringVar(&GlobalConfig.OutputDir, "output", GlobalConfig.OutputDir, "Directory to store results")
+	flag.BoolVar(&GlobalConfig.Verbose, "verbose", GlobalConfig.Verbose, "Enable verbose output")
+	flag.BoolVar(&GlobalConfig.SpecialCases, "special", GlobalConfig.SpecialCases, "Enable special case testing")
+	flag.IntVar(&GlobalConfig.Workers, "workers", GlobalConfig.Workers, "Number of parallel fuzzing workers")
+	flag.BoolVar(&GlobalConfig.Minimize, "minimize", GlobalConfig.Minimize, "Minimize failing inputs when they are recorded")
+	flag.IntVar(&GlobalConfig.CheckpointEvery, "checkpoint-every", GlobalConfig.CheckpointEvery, "Iterations between campaign checkpoints (0 to disable)")
+	flag.BoolVar(&GlobalConfig.Resume, "resume", GlobalConfig.Resume, "Resume the campaign checkpointed in the output directory")
+	flag.Func("mutators", "Mutators to run with optional parameters, e.g. RandomTxMutator:maxlen=1024,StatefulMutator (see 'statestinger mutators')", parseMutators)
+	flag.Func("oracles", "Oracles judging every execution with optional parameters, e.g. crash:expected=invalid arguments,state (see 'statestinger oracles')", parseOracles)
+	flag.Func("plugin", "Executable serving a mutator over the stdio plugin protocol (repeatable)", func(path string) error {
+		GlobalConfig.Plugins = append(GlobalConfig.Plugins, path)
+		return nil
+	})
+	flag.Func("dict", "AFL-format dictionary of tokens for the mutators to splice in (repeatable)", func(path string) error {
+		GlobalConfig.Dictionaries = append(GlobalConfig.Dictionaries, path)
+		return nil
+	})
+	flag.BoolVar(&GlobalConfig.AutoDictionary, "auto-dict", GlobalConfig.AutoDictionary, "Add tokens extracted from the target's constants to the dictionary")
+	flag.StringVar(&GlobalConfig.DiffTarget, "diff-target", GlobalConfig.DiffTarget, "Second version of the module to run every input against, reporting divergences")
+	flag.BoolVar(&GlobalConfig.SDKPanics, "sdk-panics", GlobalConfig.SDKPanics, "Recover panics into ErrPanic errors as baseapp does, reporting runtime panics apart")
+	flag.BoolVar(&GlobalConfig.Subprocess, "subprocess", GlobalConfig.Subprocess, "Run the target in a child process per worker, surviving hangs and OOMs")
+	flag.DurationVar(&GlobalConfig.ExecTimeout, "exec-timeout", GlobalConfig.ExecTimeout, "Time an input may run in subprocess mode before it counts as a hang")
+	flag.IntVar(&GlobalConfig.MemoryLimit, "memory-limit", GlobalConfig.MemoryLimit, "Memory limit of the subprocess in MB (0 for none)")
+	flag.StringVar(&GlobalConfig.Bech32Prefix, "bech32-prefix", GlobalConfig.Bech32Prefix, "Account address prefix of the target chain, e.g. osmo")
+	flag.DurationVar(&GlobalConfig.PluginTimeout, "plugin-timeout", GlobalConfig.PluginTimeout, "Time a plugin may take to answer before it is restarted")
+	flag.Func("weights", "Static mutator weights, e.g. RandomTxMutator=2,StatefulMutator=0.5", parseWeights)
+	flag.BoolVar(&GlobalConfig.Adaptive, "adaptive", GlobalConfig.Adaptive, "Adapt mutator weights to new coverage and unique findings")
+	flag.BoolVar(&GlobalConfig.Coverage, "coverage", GlobalConfig.Coverage, "Enable coverage-guided fuzzing with a persistent corpus")
 
 	flag.Parse()
 
@@ -43,6 +138,11 @@ func Execute() {
 		os.Exit(1)
 	}
 
+	// A time budget alone runs until the budget is spent
+	if GlobalConfig.Duration > 0 && !flagSet("count") {
+		GlobalConfig.FuzzCount = 0
+	}
+
 	if GlobalConfig.ModuleName == "" {
 		GlobalConfig.ModuleName = filepath.Base(GlobalConfig.TargetPath)
 		log.Printf("Module name not provided, using directory name: %s", GlobalConfig.ModuleName)
@@ -52,19 +152,222 @@ func Execute() {
 		log.Fatalf("Error creating output directory: %v", err)
 	}
 
+	// Stop gracefully on Ctrl-C or SIGTERM, a second signal exits immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			log.Printf("Signal received, finishing in-flight iterations (press Ctrl-C again to abort)")
+			stop()
+		case <-done:
+		}
+	}()
+
 	// Initialize and start the fuzzing engine
 	engine := NewFuzzerEngine(GlobalConfig)
-	results := engine.Run()
+	results := engine.Run(ctx)
+	close(done)
+
+	if err := saveSummary(filepath.Join(GlobalConfig.OutputDir, "summary.json"), results); err != nil {
+		log.Printf("Warning: Failed to save summary: %v", err)
+	}
 
 	//Report results
 	fmt.Printf("\n=== StateStinger Fuzzing Results ===\n")
 	fmt.Printf("Total tests run: %d\n", results.TotalTests)
 	fmt.Printf("Failed tests: %d\n", results.Failed)
+	fmt.Printf("Unique findings: %d\n", results.UniqueFindings)
 	fmt.Printf("State inconsistencies: %d\n", results.StateInconsistencies)
 	fmt.Printf("Consensus failures: %d\n", results.ConsensusFailures)
 	fmt.Printf("Crashes detected: %d\n", results.Crashes)
+	if results.AcceptedInvalid > 0 {
+		fmt.Printf("Malformed inputs accepted: %d\n", results.AcceptedInvalid)
+	}
+	if GlobalConfig.DiffTarget != "" {
+		fmt.Printf("Divergences: %d\n", results.Divergences)
+	}
+	if GlobalConfig.Subprocess {
+		fmt.Printf("Hangs: %d\n", results.Hangs)
+		fmt.Printf("Out of memory: %d\n", results.OOMs)
+	}
+
+	if GlobalConfig.Coverage {
+		fmt.Printf("Corpus size: %d\n", results.CorpusSize)
+		fmt.Printf("Edges covered: %d\n", results.EdgesCovered)
+	}
+
+	if len(results.MutationOps) > 0 {
+		fmt.Printf("\nHavoc operation hit rates:\n")
+		for _, op := range results.MutationOps {
+			fmt.Printf("  %-16s %6d applied %6d hits (%.1f%%)\n", op.Op, op.Applied, op.Hits, 100*op.HitRate)
+		}
+	}
 
 	if results.Failed > 0 {
 		fmt.Printf("\nDetailed failure reports saved to: %s\n", GlobalConfig.OutputDir)
 	}
 }
+
+// defaultConfig returns the configuration used when neither a config file
+// nor a flag sets a value
+func defaultConfig() Config {
+	return Config{
+		FuzzCount:       5000,
+		OutputDir:       "./fuzz_results",
+		SpecialCases:    true,
+		Workers:         1,
+		CheckpointEvery: 10000,
+		PluginTimeout:   defaultPluginTimeout,
+		AutoDictionary:  true,
+		Bech32Prefix:    defaultBech32Prefix,
+		ExecTimeout:     defaultExecTimeout,
+		MemoryLimit:     defaultMemoryLimit,
+	}
+}
+
+// configFlag finds the value of -config before the flags are parsed
+func configFlag(args []string) string {
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// loadConfigFile reads a JSON config file on top of config. Keys are the
+// Config field names, e.g. {"StateMutator": ["RandomTxMutator"],
+// "MutatorParams": {"RandomTxMutator": {"maxlen": "1024"}}}.
+func loadConfigFile(path string, config *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// parseMutators handles -mutators, replacing any selection from a config file
+func parseMutators(value string) error {
+	names, params, err := parseMutatorSpec(value)
+	if err != nil {
+		return err
+	}
+
+	GlobalConfig.StateMutator = names
+	if GlobalConfig.MutatorParams == nil {
+		GlobalConfig.MutatorParams = make(map[string]map[string]string)
+	}
+	for name, p := range params {
+		GlobalConfig.MutatorParams[name] = p
+	}
+
+	return nil
+}
+
+// parseOracles handles -oracles, replacing any selection from a config file
+func parseOracles(value string) error {
+	names, params, err := parseMutatorSpec(value)
+	if err != nil {
+		return err
+	}
+
+	GlobalConfig.Oracles = names
+	if GlobalConfig.OracleParams == nil {
+		GlobalConfig.OracleParams = make(map[string]map[string]string)
+	}
+	for name, p := range params {
+		GlobalConfig.OracleParams[name] = p
+	}
+
+	return nil
+}
+
+// runListMutators implements `statestinger mutators [-plugin path]...`
+func runListMutators(args []string) {
+	fs := flag.NewFlagSet("mutators", flag.ExitOnError)
+	fs.Func("plugin", "Also list the mutator served by this plugin executable (repeatable)", func(path string) error {
+		_, err := RegisterPlugin(path, 5*time.Second)
+		return err
+	})
+	fs.Parse(args)
+
+	for _, info := range RegisteredMutators() {
+		fmt.Printf("%-22s %s\n", info.Name, info.Description)
+
+		params := make([]string, 0, len(info.Params))
+		for param := range info.Params {
+			params = append(params, param)
+		}
+		sort.Strings(params)
+		for _, param := range params {
+			fmt.Printf("  %-20s %s\n", param, info.Params[param])
+		}
+	}
+}
+
+// runListOracles implements `statestinger oracles`
+func runListOracles(args []string) {
+	fs := flag.NewFlagSet("oracles", flag.ExitOnError)
+	fs.Parse(args)
+
+	for _, info := range RegisteredOracles() {
+		fmt.Printf("%-22s %s\n", info.Name, info.Description)
+
+		params := make([]string, 0, len(info.Params))
+		for param := range info.Params {
+			params = append(params, param)
+		}
+		sort.Strings(params)
+		for _, param := range params {
+			fmt.Printf("  %-20s %s\n", param, info.Params[param])
+		}
+	}
+}
+
+// parseWeights parses a comma separated list of name=weight pairs into GlobalConfig
+func parseWeights(value string) error {
+	if GlobalConfig.MutatorWeights == nil {
+		GlobalConfig.MutatorWeights = make(map[string]float64)
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return fmt.Errorf("expected name=weight, got %q", pair)
+		}
+		w, err := strconv.ParseFloat(weight, 64)
+		if err != nil || w < 0 {
+			return fmt.Errorf("invalid weight for %s: %q", name, weight)
+		}
+		GlobalConfig.MutatorWeights[name] = w
+	}
+
+	return nil
+}
+
+// flagSet reports whether a flag was given explicitly on the command line
+func flagSet(name string) bool {
+	found := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
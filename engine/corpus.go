@@ -0,0 +1,258 @@
+package engine
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Corpus keeps the inputs that reached new coverage and persists them on disk
+// unless it was created in memory.
+// It is shared by all workers, so every method is safe for concurrent use.
+// Workers add to it through a CorpusView of their own.
+type Corpus struct {
+	mu      sync.RWMutex
+	dir     string
+	entries [][]byte
+	seen    map[string]bool
+	edges   map[uint32]bool
+}
+
+// NewCorpus creates an empty corpus that is kept in memory only
+func NewCorpus() *Corpus {
+	return &Corpus{
+		entries: make([][]byte, 0),
+		seen:    make(map[string]bool),
+		edges:   make(map[uint32]bool),
+	}
+}
+
+// LoadCorpus opens the corpus stored in dir, creating the directory if needed
+func LoadCorpus(dir string) (*Corpus, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create corpus directory: %v", err)
+	}
+
+	c := &Corpus{
+		dir:     dir,
+		entries: make([][]byte, 0),
+		seen:    make(map[string]bool),
+		edges:   make(map[uint32]bool),
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read corpus directory: %v", err)
+	}
+
+	// Sort by name so a resumed corpus is always indexed in the same order
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read corpus entry %s: %v", file.Name(), err)
+		}
+		c.seen[corpusKey(data)] = true
+		c.entries = append(c.entries, data)
+	}
+
+	return c, nil
+}
+
+// corpusKey is the content hash used as an entry's file name
+func corpusKey(input []byte) string {
+	sum := sha1.Sum(input)
+	return hex.EncodeToString(sum[:])
+}
+
+// MarkCovered records edges without adding an entry, used when re-running a
+// corpus loaded from disk. It reports whether any of the edges were new.
+func (c *Corpus) MarkCovered(edges []uint32) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.markLocked(edges)
+}
+
+func (c *Corpus) markLocked(edges []uint32) bool {
+	found := false
+	for _, edge := range edges {
+		if !c.edges[edge] {
+			c.edges[edge] = true
+			found = true
+		}
+	}
+	return found
+}
+
+// AddIfNew stores input when it covered at least one edge not seen before
+func (c *Corpus) AddIfNew(input []byte, edges []uint32) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.markLocked(edges) {
+		return false
+	}
+
+	key := corpusKey(input)
+	if c.seen[key] {
+		return true
+	}
+
+	entry := make([]byte, len(input))
+	copy(entry, input)
+	c.seen[key] = true
+	c.entries = append(c.entries, entry)
+
+	if c.dir != "" {
+		if err := os.WriteFile(filepath.Join(c.dir, key), entry, 0644); err != nil {
+			log.Printf("Warning: Failed to save corpus entry: %v", err)
+		}
+	}
+
+	return true
+}
+
+// CorpusView is a worker's side of the corpus during a round. It judges
+// coverage against the corpus as the round found it and the worker's own
+// finds, and holds its entries back until Merge, so neither what a worker
+// keeps nor what its mutators draw depends on how far the other workers got.
+type CorpusView struct {
+	corpus  *Corpus
+	edges   map[uint32]bool // Edges first reached by this worker in the round
+	pending []corpusCandidate
+}
+
+// corpusCandidate is an input a view kept, with the edges it reached
+type corpusCandidate struct {
+	input []byte
+	edges []uint32
+}
+
+// View creates a view of the corpus for one worker
+func (c *Corpus) View() *CorpusView {
+	return &CorpusView{corpus: c, edges: make(map[uint32]bool)}
+}
+
+// AddIfNew keeps input for the next Merge when it covered at least one edge
+// neither the corpus nor the worker had seen
+func (v *CorpusView) AddIfNew(input []byte, edges []uint32) bool {
+	v.corpus.mu.RLock()
+	found := false
+	for _, edge := range edges {
+		if !v.corpus.edges[edge] && !v.edges[edge] {
+			v.edges[edge] = true
+			found = true
+		}
+	}
+	v.corpus.mu.RUnlock()
+	if !found {
+		return false
+	}
+
+	entry := make([]byte, len(input))
+	copy(entry, input)
+	v.pending = append(v.pending, corpusCandidate{input: entry, edges: edges})
+	return true
+}
+
+// Merge adds the inputs the view kept to the corpus. Views are merged in
+// worker order with no worker running, and an input whose edges an earlier
+// view already brought in is dropped.
+func (v *CorpusView) Merge() {
+	for _, candidate := range v.pending {
+		v.corpus.AddIfNew(candidate.input, candidate.edges)
+	}
+	v.pending = nil
+	clear(v.edges)
+}
+
+// Keys returns the content hashes of the stored inputs in index order
+func (c *Corpus) Keys() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make([]string, len(c.entries))
+	for i, entry := range c.entries {
+		keys[i] = corpusKey(entry)
+	}
+	return keys
+}
+
+// Reorder restores the index order saved in a checkpoint. Entries missing
+// from keys, such as ones added after the checkpoint, keep their place at the end.
+func (c *Corpus) Reorder(keys []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	byKey := make(map[string][]byte, len(c.entries))
+	for _, entry := range c.entries {
+		byKey[corpusKey(entry)] = entry
+	}
+
+	entries := make([][]byte, 0, len(c.entries))
+	for _, key := range keys {
+		if entry, ok := byKey[key]; ok {
+			entries = append(entries, entry)
+			delete(byKey, key)
+		}
+	}
+	for _, entry := range c.entries {
+		if _, ok := byKey[corpusKey(entry)]; ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	c.entries = entries
+}
+
+// Pick returns a copy of a random corpus entry, or nil if the corpus is empty
+func (c *Corpus) Pick(r *rand.Rand) []byte {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.entries) == 0 {
+		return nil
+	}
+
+	entry := c.entries[r.Intn(len(c.entries))]
+	input := make([]byte, len(entry))
+	copy(input, entry)
+	return input
+}
+
+// Entries returns a snapshot of the stored inputs
+func (c *Corpus) Entries() [][]byte {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := make([][]byte, len(c.entries))
+	copy(entries, c.entries)
+	return entries
+}
+
+// Size returns the number of stored inputs
+func (c *Corpus) Size() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.entries)
+}
+
+// EdgesCovered returns the number of distinct edges reached so far
+func (c *Corpus) EdgesCovered() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.edges)
+}
@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Bucket groups findings that share a signature
+type Bucket struct {
+	ID        string // Derived from the signature, stable across runs
+	Signature string
+	Class     string
+	Handler   string
+	Message   string // Normalized error message
+	Count     int
+	Iteration int // Iteration of the representative finding
+}
+
+// Deduplicator buckets findings by signature so the same bug found many
+// times is only stored once. It is owned by the collector goroutine.
+type Deduplicator struct {
+	buckets map[string]*Bucket
+}
+
+// NewDeduplicator creates an empty set of buckets
+func NewDeduplicator() *Deduplicator {
+	return &Deduplicator{
+		buckets: make(map[string]*Bucket),
+	}
+}
+
+var (
+	hexPattern    = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+	bech32Pattern = regexp.MustCompile(`\b[a-z]+1[02-9ac-hj-np-z]{38,}\b`)
+	numberPattern = regexp.MustCompile(`[0-9]+`)
+)
+
+// normalizeMessage strips the parts of an error message that vary between
+// occurrences of the same bug, such as addresses, amounts and pointers
+func normalizeMessage(message string) string {
+	// Addresses go first, their data part may contain "0x" followed by hex
+	message = bech32Pattern.ReplaceAllString(message, "<addr>")
+	message = hexPattern.ReplaceAllString(message, "0x?")
+	message = numberPattern.ReplaceAllString(message, "N")
+	return strings.TrimSpace(message)
+}
+
+// signature computes the bucketing key of a finding
+func signature(result FuzzResult) (string, string) {
+	// Use the target's own error, mutators word the same failure differently.
+	// Findings that did not come from a mutator fall back to their message,
+	// as do accepted inputs and divergences, whose message says what was
+	// wrong with them. Nondeterministic runs always use their message, as
+	// the target's error is only one side of them.
+	message := result.TargetError
+	if len(result.Traces) > 0 {
+		message = result.ErrorMessage
+	} else if message == "" && (result.Mutator == "" || result.AcceptedInvalid || result.Divergence) {
+		message = result.ErrorMessage
+	}
+	message = normalizeMessage(message)
+
+	parts := []string{failureClass(result), result.Handler, message}
+	if result.Invariant != "" {
+		parts = append(parts, result.Invariant)
+	}
+	if result.Rule != "" {
+		parts = append(parts, result.Rule)
+	}
+	if frames := panicFrames(result.Stack, 3); len(frames) > 0 {
+		parts = append(parts, strings.Join(frames, ";"))
+	}
+	return strings.Join(parts, "|"), message
+}
+
+// panicFrames returns the names of the innermost functions on the stack of
+// a panic below the panic itself, leaving out the runtime's frames and the
+// fuzzer's own below CosmosModule.Execute or a generated FuzzXxx test, so
+// the same panic value raised from different places falls into different
+// buckets
+func panicFrames(stack string, n int) []string {
+	var frames []string
+	below := false
+	for _, line := range strings.Split(stack, "\n") {
+		if line == "" || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "goroutine ") {
+			continue
+		}
+		// Drop the arguments, they hold pointers that vary between runs
+		name := line
+		if i := strings.LastIndexByte(name, '('); i > 0 {
+			name = name[:i]
+		}
+		if name == "panic" {
+			below = true
+			continue
+		}
+		if !below || strings.HasPrefix(name, "runtime.") {
+			continue
+		}
+		if strings.HasSuffix(name, ".(*CosmosModule).Execute") || strings.Contains(name, "_test.Fuzz") {
+			break
+		}
+		frames = append(frames, name)
+		if len(frames) == n {
+			break
+		}
+	}
+	return frames
+}
+
+// Add files a finding into its bucket. It reports the bucket and whether the
+// stored representative changed, which happens for a new bucket or when an
+// earlier iteration hits an existing one. Preferring the earliest iteration
+// keeps representatives identical however the workers were scheduled.
+func (d *Deduplicator) Add(result FuzzResult) (*Bucket, bool) {
+	sig, message := signature(result)
+	sum := sha256.Sum256([]byte(sig))
+	id := hex.EncodeToString(sum[:8])
+
+	bucket, ok := d.buckets[id]
+	if !ok {
+		bucket = &Bucket{
+			ID:        id,
+			Signature: sig,
+			Class:     failureClass(result),
+			Handler:   result.Handler,
+			Message:   message,
+			Iteration: result.Iteration,
+		}
+		d.buckets[id] = bucket
+	}
+
+	bucket.Count++
+	if ok && result.Iteration >= bucket.Iteration {
+		return bucket, false
+	}
+
+	bucket.Iteration = result.Iteration
+	return bucket, true
+}
+
+// Restore replaces the buckets with ones saved in a checkpoint
+func (d *Deduplicator) Restore(buckets []*Bucket) {
+	d.buckets = make(map[string]*Bucket, len(buckets))
+	for _, bucket := range buckets {
+		d.buckets[bucket.ID] = bucket
+	}
+}
+
+// Len returns the number of unique findings
+func (d *Deduplicator) Len() int {
+	return len(d.buckets)
+}
+
+// Buckets returns all buckets, most frequent first
+func (d *Deduplicator) Buckets() []*Bucket {
+	buckets := make([]*Bucket, 0, len(d.buckets))
+	for _, bucket := range d.buckets {
+		buckets = append(buckets, bucket)
+	}
+
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Count != buckets[j].Count {
+			return buckets[i].Count > buckets[j].Count
+		}
+		return buckets[i].ID < buckets[j].ID
+	})
+	return buckets
+}
+
+// Save writes the bucket index as JSON to filename
+func (d *Deduplicator) Save(filename string) error {
+	data, err := json.MarshalIndent(d.Buckets(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// panicStack builds the stack of a panic raised in the given functions,
+// innermost first, below CosmosModule.Execute
+func panicStack(functions ...string) string {
+	stack := "goroutine 7 [running]:\n" +
+		"runtime/debug.Stack()\n\t/usr/local/go/src/runtime/debug/stack.go:26 +0x5e\n" +
+		"panic({0x10a3b20?, 0xc000014270?})\n\t/usr/local/go/src/runtime/panic.go:770 +0x132\n"
+	for _, function := range functions {
+		stack += function + "(0xc0000a6000, {0xc0000b2000, 0x20, 0x20})\n\t/src/x/keeper/msg_server.go:42 +0x1f\n"
+	}
+	return stack + "github.com/GoSec-Labs/StateStinger/utils/target/cosmossdk.(*CosmosModule).Execute(0xc0000c4000)\n" +
+		"\t/src/utils/target/cosmossdk/cosmos.go:150 +0x85\n" +
+		"github.com/GoSec-Labs/StateStinger/engine.(*fuzzWorker).run(0xc0000d0000)\n"
+}
+
+func TestSignature(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b FuzzResult
+		same bool
+	}{
+		{
+			name: "amounts and addresses",
+			a: FuzzResult{Mutator: "CoinMutator", Handler: "Send", Failed: true,
+				TargetError: "insufficient funds: 5stake < 10stake for cosmos1qypqxpq9qcrsszg2pvxq6rs0zqg3yyc5lzv7xu"},
+			b: FuzzResult{Mutator: "RandomTxMutator", Handler: "Send", Failed: true,
+				TargetError: "insufficient funds: 0stake < 99stake for cosmos1zg69w7y6hn0aqy352euf40x77qddq3dc8m0pqs"},
+			same: true,
+		},
+		{
+			name: "pointers",
+			a:    FuzzResult{Mutator: "RandomTxMutator", Crashed: true, TargetError: "bad pointer 0xc000012345"},
+			b:    FuzzResult{Mutator: "RandomTxMutator", Crashed: true, TargetError: "bad pointer 0xdeadbeef"},
+			same: true,
+		},
+		{
+			name: "mutators word the same failure differently",
+			a:    FuzzResult{Mutator: "RandomTxMutator", Handler: "Send", Crashed: true, TargetError: "boom", ErrorMessage: "Crash: boom"},
+			b:    FuzzResult{Mutator: "CoinMutator", Handler: "Send", Crashed: true, TargetError: "boom", ErrorMessage: "Coin crash"},
+			same: true,
+		},
+		{
+			name: "handler",
+			a:    FuzzResult{Mutator: "RandomTxMutator", Handler: "Send", Crashed: true, TargetError: "boom"},
+			b:    FuzzResult{Mutator: "RandomTxMutator", Handler: "MultiSend", Crashed: true, TargetError: "boom"},
+		},
+		{
+			name: "class",
+			a:    FuzzResult{Mutator: "RandomTxMutator", Crashed: true, TargetError: "boom"},
+			b:    FuzzResult{Mutator: "RandomTxMutator", ConsensusFailure: true, TargetError: "boom"},
+		},
+		{
+			name: "invariant",
+			a:    FuzzResult{Oracle: "bank", StateInconsistency: true, Invariant: "bank/total-supply"},
+			b:    FuzzResult{Oracle: "bank", StateInconsistency: true, Invariant: "bank/nonnegative-balance"},
+		},
+		{
+			name: "accepted inputs use their message",
+			a:    FuzzResult{Mutator: "CoinMutator", AcceptedInvalid: true, ErrorMessage: "Accepted malformed coins: duplicate denom"},
+			b:    FuzzResult{Mutator: "CoinMutator", AcceptedInvalid: true, ErrorMessage: "Accepted malformed coins: negative amount"},
+		},
+		{
+			name: "findings without a mutator use their message",
+			a:    FuzzResult{Hazard: true, Rule: "time", ErrorMessage: "Wall-clock time from time.Now"},
+			b:    FuzzResult{Hazard: true, Rule: "time", ErrorMessage: "Wall-clock time from time.Since"},
+		},
+		{
+			name: "same panic value from the same place",
+			a: FuzzResult{Mutator: "RandomTxMutator", Crashed: true, PanicValue: "boom",
+				Stack: panicStack("example.com/x/keeper.msgServer.Send")},
+			b: FuzzResult{Mutator: "StructMutator", Crashed: true, PanicValue: "boom",
+				Stack: panicStack("example.com/x/keeper.msgServer.Send")},
+			same: true,
+		},
+		{
+			name: "same panic value from different places",
+			a: FuzzResult{Mutator: "RandomTxMutator", Crashed: true, PanicValue: "boom",
+				Stack: panicStack("example.com/x/keeper.msgServer.Send")},
+			b: FuzzResult{Mutator: "RandomTxMutator", Crashed: true, PanicValue: "boom",
+				Stack: panicStack("example.com/x/keeper.Keeper.subUnlockedCoins", "example.com/x/keeper.msgServer.Send")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := signature(tt.a)
+			b, _ := signature(tt.b)
+			if tt.same {
+				assert.Equal(t, a, b)
+			} else {
+				assert.NotEqual(t, a, b)
+			}
+		})
+	}
+}
+
+func TestPanicFrames(t *testing.T) {
+	stack := panicStack("example.com/x/keeper.Keeper.a", "example.com/x/keeper.Keeper.b",
+		"example.com/x/keeper.Keeper.c", "example.com/x/keeper.msgServer.Send")
+
+	assert.Equal(t, []string{"example.com/x/keeper.Keeper.a", "example.com/x/keeper.Keeper.b"}, panicFrames(stack, 2))
+	assert.Len(t, panicFrames(stack, 10), 4, "frames below Execute are the fuzzer's own")
+	assert.Empty(t, panicFrames("", 3))
+}
+
+func TestDeduplicatorRepresentative(t *testing.T) {
+	finding := func(iteration int) FuzzResult {
+		return FuzzResult{Mutator: "RandomTxMutator", Handler: "Send", Crashed: true, Failed: true,
+			TargetError: "boom", Iteration: iteration}
+	}
+
+	tests := []struct {
+		iteration int
+		changed   bool
+		count     int
+		kept      int // Iteration of the representative afterwards
+	}{
+		{50, true, 1, 50},
+		{70, false, 2, 50},
+		{20, true, 3, 20},
+		{20, false, 4, 20},
+	}
+
+	dedup := NewDeduplicator()
+	for _, tt := range tests {
+		bucket, changed := dedup.Add(finding(tt.iteration))
+		assert.Equal(t, tt.changed, changed, "iteration %d", tt.iteration)
+		assert.Equal(t, tt.count, bucket.Count)
+		assert.Equal(t, tt.kept, bucket.Iteration)
+	}
+	assert.Equal(t, 1, dedup.Len())
+}
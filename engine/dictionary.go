@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadDictionary reads a dictionary in the AFL token format: one token per
+// line as name="value", name@level="value" or just "value", with \xNN, \\
+// and \" escapes. Blank lines and lines starting with # are skipped.
+func LoadDictionary(path string) ([][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	tokens := make([][]byte, 0)
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		token, err := parseDictionaryLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+		if len(token) > 0 {
+			tokens = append(tokens, token)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// parseDictionaryLine decodes the quoted value of one dictionary entry
+func parseDictionaryLine(line string) ([]byte, error) {
+	start := strings.IndexByte(line, '"')
+	if start < 0 || !strings.HasSuffix(line, `"`) || start == len(line)-1 {
+		return nil, fmt.Errorf("expected a quoted value, got %q", line)
+	}
+
+	// Anything before the value is name, name@level and the '='
+	if name := strings.TrimSpace(line[:start]); name != "" && !strings.HasSuffix(name, "=") {
+		return nil, fmt.Errorf("expected name=\"value\", got %q", line)
+	}
+
+	value := line[start+1 : len(line)-1]
+	token := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '\\' {
+			token = append(token, c)
+			continue
+		}
+
+		if i+1 >= len(value) {
+			return nil, fmt.Errorf("dangling backslash in %q", line)
+		}
+		i++
+		switch value[i] {
+		case '\\', '"':
+			token = append(token, value[i])
+		case 'x':
+			if i+2 >= len(value) {
+				return nil, fmt.Errorf("short \\x escape in %q", line)
+			}
+			b, err := strconv.ParseUint(value[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("bad \\x escape in %q", line)
+			}
+			token = append(token, byte(b))
+			i += 2
+		default:
+			return nil, fmt.Errorf("unknown escape \\%c in %q", value[i], line)
+		}
+	}
+
+	return token, nil
+}
+
+// mergeTokens appends the tokens not already in dict
+func mergeTokens(dict [][]byte, tokens [][]byte) [][]byte {
+	seen := make(map[string]bool, len(dict))
+	for _, token := range dict {
+		seen[string(token)] = true
+	}
+	for _, token := range tokens {
+		if !seen[string(token)] {
+			seen[string(token)] = true
+			dict = append(dict, token)
+		}
+	}
+	return dict
+}
+
+// spliceToken inserts token at a random position of input or overwrites
+// the bytes there with it
+func spliceToken(r *rand.Rand, input []byte, token []byte) []byte {
+	pos := r.Intn(len(input) + 1)
+	if r.Intn(2) == 0 || pos+len(token) > len(input) {
+		spliced := make([]byte, 0, len(input)+len(token))
+		spliced = append(spliced, input[:pos]...)
+		spliced = append(spliced, token...)
+		return append(spliced, input[pos:]...)
+	}
+	copy(input[pos:], token)
+	return input
+}
+
+// DictionaryMutator builds inputs around tokens from the dictionary
+type DictionaryMutator struct {
+	BaseMutator
+	dictionary [][]byte
+	maxTokens  int
+}
+
+func NewDictionaryMutator(r *rand.Rand, dictionary [][]byte) *DictionaryMutator {
+	return &DictionaryMutator{
+		BaseMutator: BaseMutator{
+			rand: r,
+			name: "DictionaryMutator",
+		},
+		dictionary: dictionary,
+		maxTokens:  4,
+	}
+}
+
+func (m *DictionaryMutator) GenerateFuzzInput() []byte {
+	// Keep the first byte random so every handler gets to see the tokens
+	input := make([]byte, 1+m.rand.Intn(64))
+	m.rand.Read(input)
+	if len(m.dictionary) == 0 {
+		return input
+	}
+
+	tokens := 1 + m.rand.Intn(m.maxTokens)
+	for i := 0; i < tokens; i++ {
+		token := m.dictionary[m.rand.Intn(len(m.dictionary))]
+		input = append(input[:1], spliceToken(m.rand, input[1:], token)...)
+	}
+
+	return input
+}
+
+func (m *DictionaryMutator) ValidateOutput(output []byte, err error) *FuzzResult {
+	return nil
+}
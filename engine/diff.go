@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DiffSide is what one of the two targets of a differential run did
+type DiffSide struct {
+	Target     string // Path of the module
+	Output     []byte
+	Error      string
+	Events     []string
+	StateHash  string // Hash of the store built from the target's writes
+	WritesHash string // Hash of the writes of this execution
+}
+
+// DiffReport describes how the two targets of a differential run diverged
+type DiffReport struct {
+	Reason    string // What diverged: class, events or state
+	Primary   DiffSide
+	Secondary DiffSide
+}
+
+// diffSide records an execution for a report
+func diffSide(target string, exec *Execution) DiffSide {
+	side := DiffSide{
+		Target: target,
+		Output: exec.Output,
+		Events: exec.Events,
+	}
+	if exec.Err != nil {
+		side.Error = exec.Err.Error()
+	}
+	if exec.State != nil {
+		side.StateHash = exec.State.Hash()
+		side.WritesHash = exec.State.WritesHash()
+	}
+	return side
+}
+
+// maxOutcomeOutput is how much of an output outcomeClass quotes
+const maxOutcomeOutput = 64
+
+// outcomeClass names the outcome of an execution: its panic, its error or
+// else its output, which is how the target reports failures it does not
+// return an error for
+func outcomeClass(exec *Execution) string {
+	switch {
+	case exec.Panic != nil:
+		return "panic: " + exec.Panic.Value
+	case exec.Err != nil:
+		return "error: " + exec.Err.Error()
+	case len(exec.Output) > maxOutcomeOutput:
+		return fmt.Sprintf("output %q...", exec.Output[:maxOutcomeOutput])
+	}
+	return fmt.Sprintf("output %q", exec.Output)
+}
+
+// diffOracle reports inputs the -diff-target module handles differently
+// from the primary target: another outcome class, other events or other
+// writes. Writes are compared per execution rather than by store hash, as
+// one divergence would otherwise leave every later execution diverging too.
+type diffOracle struct {
+	primary   string
+	secondary string
+}
+
+func (o *diffOracle) Name() string {
+	return "diff"
+}
+
+func (o *diffOracle) Check(exec Execution) *FuzzResult {
+	other := exec.Diff
+	if other == nil {
+		return nil
+	}
+
+	var reason, message string
+	switch {
+	case outcomeClass(&exec) != outcomeClass(other):
+		reason = "class"
+		message = fmt.Sprintf("Targets diverged: %s vs %s", outcomeClass(&exec), outcomeClass(other))
+	case !slices.Equal(exec.Events, other.Events):
+		reason = "events"
+		message = fmt.Sprintf("Targets emitted different events: %d vs %d", len(exec.Events), len(other.Events))
+	case exec.State != nil && other.State != nil && exec.State.WritesHash() != other.State.WritesHash():
+		reason = "state"
+		message = "Targets wrote different state"
+	default:
+		return nil
+	}
+
+	return &FuzzResult{
+		ID:           fmt.Sprintf("diff_%d", time.Now().UnixNano()),
+		Failed:       true,
+		Divergence:   true,
+		ErrorMessage: message,
+		Diff: &DiffReport{
+			Reason:    reason,
+			Primary:   diffSide(o.primary, &exec),
+			Secondary: diffSide(o.secondary, other),
+		},
+	}
+}
+
+// checkDiffTarget warns about differences between the two targets that make
+// the same input mean different things to them
+func checkDiffTarget(f *FuzzEngine) {
+	if !slices.Equal(f.target.Handlers, f.diffTarget.Handlers) {
+		log.Printf("Warning: Targets have different handlers (%s vs %s), the first input byte may select different handlers",
+			strings.Join(f.target.Handlers, ","), strings.Join(f.diffTarget.Handlers, ","))
+	}
+}
+
+func init() {
+	RegisterOracle(OracleInfo{
+		Name:        "diff",
+		Description: "Divergence from the -diff-target module in outcome, events or state",
+	}, func(env OracleEnv) (Oracle, error) {
+		o := &diffOracle{}
+		if env.Target != nil {
+			o.primary = env.Target.Path
+		}
+		if env.DiffTarget != nil {
+			o.secondary = env.DiffTarget.Path
+		}
+		return o, nil
+	})
+}
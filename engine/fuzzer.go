@@ -1,26 +1,66 @@
 package engine
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/GoSec-Labs/StateStinger/utils/target/cosmossdk"
 )
 
+// syncEvery is the number of iterations between the points where workers
+// share their corpus finds and scheduler rewards
+const syncEvery = 1000
+
 // FuzzResult tracks the outcome of a single fuzzing test
 type FuzzResult struct {
 	ID                 string
+	Mutator            string            // Name of the mutator that generated the input
+	Oracle             string            // Name of the oracle that reported the finding
+	OracleParams       map[string]string `json:",omitempty"` // Parameters the oracle was configured with
+	MutatorParams      map[string]string `json:",omitempty"` // Parameters the mutator was configured with
+	Bech32Prefix       string            `json:",omitempty"` // Account prefix the campaign ran with
+	SDKPanics          bool              `json:",omitempty"` // The campaign recovered panics into ErrPanic
+	Plugin             string            `json:",omitempty"` // Executable serving the mutator, for plugin mutators
+	Invariant          string            // Invariant the finding broke, for invariant oracles
+	Rule               string            // Lint rule the finding broke, for static hazards
+	Module             string
+	Seed               int64 // Campaign seed
+	Worker             int
+	Iteration          int
 	Input              []byte
+	Steps              [][]byte          // Executions that led up to the input, ending with it
+	State              map[string][]byte `json:",omitempty"` // Store the first step ran on
+	DiffState          map[string][]byte `json:",omitempty"` // Store of the -diff-target module the first step ran on
+	Handler            string            // Target handler the input was dispatched to
+	TargetError        string            // Error returned by the target, if any
 	ErrorMessage       string
 	Failed             bool
 	StateInconsistency bool
 	ConsensusFailure   bool
 	Crashed            bool
+	AcceptedInvalid    bool            // The target accepted an input it should have rejected
+	Divergence         bool            // The -diff-target module handled the input differently
+	Hang               bool            // The input ran past the subprocess timeout
+	OOM                bool            // The input ran the subprocess out of memory
+	Hazard             bool            // Found by `statestinger lint` in the source, without an input
+	Location           string          `json:",omitempty"` // Source position of a static hazard
+	FuzzTest           string          `json:",omitempty"` // Native Go fuzz test that found the finding, for `statestinger gofuzz`
+	CorpusFile         string          `json:",omitempty"` // Entry go test saved the input in
+	PanicValue         string          `json:",omitempty"` // Value the target panicked with
+	Stack              string          `json:",omitempty"` // Stack of the panicking goroutine
+	RuntimePanic       bool            `json:",omitempty"` // The panic was a runtime error, e.g. a nil dereference
+	Decoded            json.RawMessage `json:",omitempty"` // Readable view of a structured input
+	Diff               *DiffReport     `json:",omitempty"` // Both sides of a divergence
+	Traces             []Trace         `json:",omitempty"` // Disagreeing runs of a nondeterministic input
 }
 
 // FuzzSummary contains aggregate results from a fuzzing run
@@ -30,26 +70,97 @@ type FuzzSummary struct {
 	StateInconsistencies int
 	ConsensusFailures    int
 	Crashes              int
+	AcceptedInvalid      int
+	Divergences          int
+	Hangs                int
+	OOMs                 int
+	UniqueFindings       int
+	CorpusSize           int
+	EdgesCovered         int
+	MutatorWeights       []WeightSnapshot // Mutator selection probabilities over time
+	MutationOps          []OpStats        // Hit rate of each havoc operation
 }
 
 // FuzzEngine is the core fuzzing implementation
 type FuzzEngine struct {
-	config   Config
-	rand     *rand.Rand
-	mutators []StateMutator
-	results  []FuzzResult
-	summary  FuzzSummary
+	config     Config
+	seed       int64
+	workers    []*fuzzWorker
+	corpus     *Corpus
+	dedup      *Deduplicator
+	sched      *Scheduler
+	plugins    []string // Names of the mutators served by plugins
+	dictionary [][]byte // Tokens from -dict files and the target
+	next       int      // First iteration not yet run, restored on resume
+	target     *cosmossdk.CosmosModule
+	diffTarget *cosmossdk.CosmosModule // nil unless -diff-target is set
+	results    []FuzzResult
+	summary    FuzzSummary
 }
 
 // StateMutator defines an interface for state mutation strategies
 type StateMutator interface {
 	Name() string
 	GenerateFuzzInput() []byte
-	ValidateOutput(output []byte, err error) *FuzzResult
+	ValidateOutput(output []byte, err error) *FuzzResult // Mutator-specific checks, the oracles judge errors and markers
+}
+
+// InputDecoder is implemented by mutators whose inputs have a structure
+// worth showing in findings, such as an encoded message
+type InputDecoder interface {
+	DecodeInput(input []byte) json.RawMessage
+}
+
+// Observation is what the target did with one input
+type Observation struct {
+	Input  []byte
+	Output []byte
+	Err    error
+	Delta  []cosmossdk.StateChange // Store writes, empty unless the input succeeded
+}
+
+// Observer is implemented by mutators that learn from the execution of each
+// of their inputs, e.g. to reference objects created earlier in a sequence
+type Observer interface {
+	Observe(obs Observation)
+}
+
+// InputValidator is implemented by mutators whose oracle needs the input as
+// well as the outcome, e.g. to flag malformed inputs the target accepted
+type InputValidator interface {
+	ValidateInput(input []byte, output []byte, err error) *FuzzResult
+}
+
+// Disabler is implemented by mutators that can stop working for good, such
+// as plugins that keep crashing. A disabled mutator leaves the schedule.
+type Disabler interface {
+	Disabled() bool
+}
+
+// validate checks the outcome of an input with the mutator that generated it
+func validate(mutator StateMutator, input []byte, output []byte, err error) *FuzzResult {
+	if v, ok := mutator.(InputValidator); ok {
+		return v.ValidateInput(input, output, err)
+	}
+	return mutator.ValidateOutput(output, err)
 }
 
 // NewFuzzEngine creates a new fuzzing engine with the given configuration
 func NewFuzzerEngine(config Config) *FuzzEngine {
+	// A resumed campaign must keep the seed, worker count and round size it
+	// was started with, otherwise the RNG streams would not line up
+	var checkpoint *Checkpoint
+	if config.Resume {
+		var err error
+		checkpoint, err = loadCheckpoint(filepath.Join(config.OutputDir, checkpointFile))
+		if err != nil {
+			log.Fatalf("Failed to load checkpoint: %v", err)
+		}
+		config.Seed = checkpoint.Seed
+		config.Workers = checkpoint.Workers
+		config.CheckpointEvery = checkpoint.CheckpointEvery
+	}
+
 	var seed int64
 	if config.Seed == 0 {
 		seed = time.Now().UnixNano()
@@ -57,80 +168,407 @@ func NewFuzzerEngine(config Config) *FuzzEngine {
 		seed = config.Seed
 	}
 
-	rng := rand.New(rand.NewSource(seed))
+	workers := config.Workers
+	if workers < 1 {
+		workers = 1
+	}
 
-	log.Printf("Initializing StateStinger with seed: %d", seed)
+	log.Printf("Initializing StateStinger with seed: %d (%d workers)", seed, workers)
 
 	engine := &FuzzEngine{
-		config:   config,
-		rand:     rng,
-		mutators: make([]StateMutator, 0),
-		results:  make([]FuzzResult, 0),
+		config:  config,
+		seed:    seed,
+		workers: make([]*fuzzWorker, 0, workers),
+		results: make([]FuzzResult, 0),
+		dedup:   NewDeduplicator(),
 	}
 
-	// Register default mutators
-	engine.registerDefaultMutators()
+	// Set up target module
+	targetModule, err := cosmossdk.LoadCosmosModule(config.TargetPath, config.ModuleName)
+	if err != nil {
+		log.Fatalf("Failed to load target module: %v", err)
+	}
+	targetModule.SDKPanics = config.SDKPanics
+	engine.target = targetModule
+
+	if config.DiffTarget != "" {
+		diffModule, err := cosmossdk.LoadCosmosModule(config.DiffTarget, config.ModuleName)
+		if err != nil {
+			log.Fatalf("Failed to load diff target module: %v", err)
+		}
+		diffModule.SDKPanics = config.SDKPanics
+		engine.diffTarget = diffModule
+		checkDiffTarget(engine)
+		log.Printf("Differential mode: comparing %s against %s", config.TargetPath, config.DiffTarget)
+	}
+
+	// Dictionary tokens from files come first, then the ones found in the target
+	for _, path := range config.Dictionaries {
+		tokens, err := LoadDictionary(path)
+		if err != nil {
+			log.Fatalf("Failed to load dictionary: %v", err)
+		}
+		engine.dictionary = mergeTokens(engine.dictionary, tokens)
+	}
+	if config.AutoDictionary {
+		engine.dictionary = mergeTokens(engine.dictionary, targetModule.Dictionary)
+	}
+	if len(engine.dictionary) > 0 {
+		log.Printf("Using a dictionary of %d tokens", len(engine.dictionary))
+	}
+
+	if config.Coverage {
+		corpus, err := LoadCorpus(filepath.Join(config.OutputDir, "corpus"))
+		if err != nil {
+			log.Fatalf("Failed to load corpus: %v", err)
+		}
+		engine.corpus = corpus
+		log.Printf("Coverage-guided mode enabled, loaded %d corpus entries", corpus.Size())
+	}
+
+	for _, path := range config.Plugins {
+		name, err := RegisterPlugin(path, config.PluginTimeout)
+		if err != nil {
+			log.Fatalf("Failed to load plugin: %v", err)
+		}
+		engine.plugins = append(engine.plugins, name)
+		log.Printf("Loaded plugin mutator %s from %s", name, path)
+	}
+
+	// Every worker gets its own RNG stream and mutator instances
+	for id := 0; id < workers; id++ {
+		rng := rand.New(rand.NewSource(workerSeed(seed, id)))
+		mutators, err := engine.buildMutators(rng)
+		if err != nil {
+			log.Fatalf("Failed to set up mutators: %v", err)
+		}
+		oracles, err := engine.buildOracles()
+		if err != nil {
+			log.Fatalf("Failed to set up oracles: %v", err)
+		}
+		worker := &fuzzWorker{
+			id:        id,
+			exec:      engine.executor(engine.target),
+			seed:      seed,
+			rand:      rng,
+			mutators:  mutators,
+			oracles:   oracles,
+			params:    config.OracleParams,
+			mutParams: config.MutatorParams,
+			prefix:    config.Bech32Prefix,
+			state:     NewStateStore(),
+			diffState: NewStateStore(),
+			history:   NewHistory(),
+		}
+		if engine.corpus != nil {
+			worker.corpus = engine.corpus.View()
+		}
+		if engine.diffTarget != nil {
+			worker.diff = engine.executor(engine.diffTarget)
+		}
+		engine.workers = append(engine.workers, worker)
+	}
+
+	log.Printf("Registered %d mutation strategies", len(engine.workers[0].mutators))
+	if config.Subprocess {
+		log.Printf("Subprocess mode: %s per input, %d MB memory limit", config.ExecTimeout, config.MemoryLimit)
+	}
+
+	// All workers build the same mutator list, so one scheduler serves them all
+	names := make([]string, 0, len(engine.workers[0].mutators))
+	for _, mutator := range engine.workers[0].mutators {
+		names = append(names, mutator.Name())
+	}
+	for name := range config.MutatorWeights {
+		if !slices.Contains(names, name) {
+			log.Printf("Warning: Weight given for unknown mutator %s", name)
+		}
+	}
+	engine.sched = NewScheduler(names, config.MutatorWeights, config.Adaptive)
+	for _, w := range engine.workers {
+		w.schedule = engine.sched.View()
+	}
+
+	if checkpoint != nil {
+		if err := engine.restore(checkpoint); err != nil {
+			log.Fatalf("Failed to resume campaign: %v", err)
+		}
+	}
 
 	return engine
 }
 
-// registerDefaultMutators adds the standard mutation strategies
-func (f *FuzzEngine) registerDefaultMutators() {
-	f.mutators = append(f.mutators, NewRandomTxMutator(f.rand))
-	f.mutators = append(f.mutators, NewBoundaryValueMutator(f.rand))
-	f.mutators = append(f.mutators, NewStatefulMutator(f.rand))
+// buildMutators creates the selected mutation strategies on top of the given RNG
+func (f *FuzzEngine) buildMutators(r *rand.Rand) ([]StateMutator, error) {
+	names := f.config.StateMutator
+	if len(names) == 0 {
+		names = defaultMutators(f.config)
+		if len(f.dictionary) > 0 {
+			names = append(names, "DictionaryMutator")
+		}
+		if len(f.target.Schemas) > 0 {
+			names = append(names, "StructMutator")
+		}
+		if f.target.Proto != nil && len(f.target.Proto.Msgs) > 0 {
+			names = append(names, "ProtoMutator")
+		}
+		names = append(names, f.plugins...)
+	}
 
-	if f.config.SpecialCases {
-		f.mutators = append(f.mutators, NewSpecialCasesMutator(f.rand))
+	mutators := make([]StateMutator, 0, len(names))
+	for _, name := range names {
+		mutator, err := NewMutator(name, MutatorEnv{
+			Rand:       r,
+			Corpus:     f.corpus,
+			Dictionary: f.dictionary,
+			Target:     f.target,
+			Prefix:     f.config.Bech32Prefix,
+			Params:     f.config.MutatorParams[name],
+		})
+		if err != nil {
+			return nil, err
+		}
+		mutators = append(mutators, mutator)
 	}
 
-	log.Printf("Registered %d mutation strategies", len(f.mutators))
+	return mutators, nil
 }
 
-// Run executes the fuzzing process
-func (f *FuzzEngine) Run() FuzzSummary {
-	log.Printf("Starting fuzzing run with %d iterations on module '%s'",
-		f.config.FuzzCount, f.config.ModuleName)
+// buildOracles creates the selected oracles for one worker
+func (f *FuzzEngine) buildOracles() ([]Oracle, error) {
+	names := f.config.Oracles
+	if len(names) == 0 {
+		names = defaultOracles()
+	}
+	// Differential runs are about divergences, report them before anything else
+	if f.diffTarget != nil && !slices.Contains(names, "diff") {
+		names = append([]string{"diff"}, names...)
+	}
 
-	// Set up target module
-	targetModule, err := cosmossdk.LoadCosmosModule(f.config.TargetPath, f.config.ModuleName)
-	if err != nil {
-		log.Fatalf("Failed to load target module: %v", err)
+	oracles := make([]Oracle, 0, len(names))
+	for _, name := range names {
+		oracle, err := NewOracle(name, OracleEnv{
+			Target:     f.target,
+			DiffTarget: f.diffTarget,
+			Params:     f.config.OracleParams[name],
+			Limits:     f.limits(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		oracles = append(oracles, oracle)
 	}
 
-	// Main fuzzing loop
-	for i := 0; i < f.config.FuzzCount; i++ {
-		if i > 0 && i%1000 == 0 {
-			log.Printf("Progress: %d/%d iterations completed", i, f.config.FuzzCount)
+	return oracles, nil
+}
+
+// executor runs module in process, or in a harness child of its own in
+// subprocess mode
+func (f *FuzzEngine) executor(module *cosmossdk.CosmosModule) Executor {
+	if !f.config.Subprocess {
+		return module
+	}
+	return NewHarness(HarnessConfig{
+		TargetPath:  module.Path,
+		ModuleName:  module.Name,
+		Timeout:     f.config.ExecTimeout,
+		MemoryLimit: f.config.MemoryLimit,
+		SDKPanics:   module.SDKPanics,
+	})
+}
+
+// limits are the timeout and memory limit of harness children started
+// outside the workers' executors
+func (f *FuzzEngine) limits() HarnessConfig {
+	return HarnessConfig{Timeout: f.config.ExecTimeout, MemoryLimit: f.config.MemoryLimit}
+}
+
+// Run executes the fuzzing process until the iteration count or the time
+// budget is exhausted, or ctx is cancelled. On cancellation in-flight
+// iterations are drained and their findings recorded before returning.
+func (f *FuzzEngine) Run(ctx context.Context) FuzzSummary {
+	if f.config.FuzzCount > 0 {
+		log.Printf("Starting fuzzing run with %d iterations on module '%s'",
+			f.config.FuzzCount, f.config.ModuleName)
+	} else {
+		log.Printf("Starting fuzzing run on module '%s'", f.config.ModuleName)
+	}
+
+	if f.config.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.config.Duration)
+		defer cancel()
+		log.Printf("Time budget: %s", f.config.Duration)
+	}
+
+	targetModule := f.target
+
+	// Re-run stored corpus entries so their coverage counts as already seen
+	if f.corpus != nil {
+		for _, entry := range f.corpus.Entries() {
+			f.corpus.MarkCovered(targetModule.Execute(entry).Edges)
 		}
+		log.Printf("Corpus covers %d edges", f.corpus.EdgesCovered())
+	}
 
-		// Choose a random mutator
-		mutator := f.mutators[f.rand.Intn(len(f.mutators))]
+	// Without checkpoints the whole campaign is a single round
+	roundSize := f.config.CheckpointEvery
+	if roundSize <= 0 {
+		roundSize = f.config.FuzzCount
+	}
+
+	for f.next < f.config.FuzzCount || f.config.FuzzCount <= 0 {
+		end := 0
+		if roundSize > 0 {
+			end = f.next + roundSize
+			if f.config.FuzzCount > 0 && end > f.config.FuzzCount {
+				end = f.config.FuzzCount
+			}
+		}
 
-		// Generate fuzz input
-		input := mutator.GenerateFuzzInput()
+		// Reseeding at every round boundary makes the RNG state at a
+		// checkpoint a function of the round alone
+		if f.config.CheckpointEvery > 0 {
+			for _, w := range f.workers {
+				w.rand.Seed(roundSeed(workerSeed(f.seed, w.id), f.next/f.config.CheckpointEvery))
+			}
+		}
 
-		// Execute on target
-		output, err := targetModule.ExecuteFuzz(input)
+		// Workers only share their corpus finds and scheduler rewards
+		// between syncs, which keeps runs reproducible
+		for start := f.next; (end <= 0 || start < end) && ctx.Err() == nil; start += syncEvery {
+			stop := start + syncEvery
+			if end > 0 && stop > end {
+				stop = end
+			}
+			f.runSync(ctx, targetModule, start, stop)
+		}
+		if ctx.Err() != nil || end <= 0 {
+			break
+		}
 
-		// Validate result
-		result := mutator.ValidateOutput(output, err)
+		f.next = end
+		if f.config.CheckpointEvery > 0 {
+			if err := f.saveCheckpoint(); err != nil {
+				log.Printf("Warning: Failed to save checkpoint: %v", err)
+			}
+		}
+	}
 
-		// Track result
-		if result != nil {
-			f.trackResult(result)
+	// Shut down mutators, oracles and executors that hold resources, such
+	// as plugin and harness processes
+	for _, w := range f.workers {
+		for _, mutator := range w.mutators {
+			if closer, ok := mutator.(io.Closer); ok {
+				closer.Close()
+			}
+		}
+		for _, oracle := range w.oracles {
+			if closer, ok := oracle.(io.Closer); ok {
+				closer.Close()
+			}
 		}
+		if closer, ok := w.exec.(io.Closer); ok {
+			closer.Close()
+		}
+		if closer, ok := w.diff.(io.Closer); ok {
+			closer.Close()
+		}
+	}
 
-		f.summary.TotalTests++
+	if ctx.Err() != nil {
+		log.Printf("Fuzzing stopped after %d iterations: %v", f.summary.TotalTests, context.Cause(ctx))
+	}
+
+	f.summary.UniqueFindings = f.dedup.Len()
+	f.sched.Snapshot(f.summary.TotalTests, true)
+	f.summary.MutatorWeights = f.sched.History()
+	f.summary.MutationOps = mergeOpStats(f.workers)
+	if err := f.dedup.Save(filepath.Join(f.config.OutputDir, "findings.json")); err != nil {
+		log.Printf("Warning: Failed to save findings index: %v", err)
+	}
+
+	if f.corpus != nil {
+		f.summary.CorpusSize = f.corpus.Size()
+		f.summary.EdgesCovered = f.corpus.EdgesCovered()
 	}
 
 	return f.summary
 }
 
-// trackResult processes and tracks the result of a fuzzing iteration
-func (f *FuzzEngine) trackResult(result *FuzzResult) {
+// runSync executes iterations [start, end) on all workers and collects
+// their results, then merges the corpus finds and scheduler rewards of the
+// workers once all of them are done
+func (f *FuzzEngine) runSync(ctx context.Context, target *cosmossdk.CosmosModule, start, end int) {
+	outcomes := make(chan iterationOutcome, 64*len(f.workers))
+
+	var wg sync.WaitGroup
+	for _, w := range f.workers {
+		w.schedule.Sync()
+		wg.Add(1)
+		go func(w *fuzzWorker) {
+			defer wg.Done()
+			w.run(ctx, target, start, end, len(f.workers), outcomes)
+		}(w)
+	}
+
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	// Results are collected on this goroutine only, so the summary and
+	// the failure records never need locking. They arrive in no fixed
+	// order, so a new bucket rewards the mutator of its earliest iteration
+	// once all are in.
+	firsts := make(map[string]iterationOutcome)
+	for outcome := range outcomes {
+		if outcome.result != nil {
+			if bucket := f.trackResult(outcome.result); bucket != nil {
+				first, ok := firsts[bucket.ID]
+				if ok && outcome.iteration < first.iteration || !ok && bucket.Count == 1 {
+					firsts[bucket.ID] = outcome
+				}
+			}
+		}
+		f.sched.Update(outcome.mutator, outcome.newCoverage)
+
+		f.summary.TotalTests++
+		if f.summary.TotalTests%1000 == 0 {
+			if f.config.FuzzCount <= 0 {
+				log.Printf("Progress: %d iterations completed", f.summary.TotalTests)
+			} else if f.summary.TotalTests < f.config.FuzzCount {
+				log.Printf("Progress: %d/%d iterations completed", f.summary.TotalTests, f.config.FuzzCount)
+			}
+		}
+	}
+
+	for _, first := range firsts {
+		if !first.newCoverage {
+			f.sched.Reward(first.mutator)
+		}
+	}
+	for _, w := range f.workers {
+		if w.corpus != nil {
+			w.corpus.Merge()
+		}
+	}
+	f.sched.Snapshot(f.summary.TotalTests, false)
+}
+
+// trackResult processes and tracks the result of a fuzzing iteration.
+// It returns the bucket of a failure, nil otherwise.
+func (f *FuzzEngine) trackResult(result *FuzzResult) *Bucket {
+	var found *Bucket
 	if result.Failed {
-		f.recordFailure(*result)
+		// Only the representative of each bucket is written to disk
+		bucket, changed := f.dedup.Add(*result)
+		if changed {
+			representative := *result
+			representative.ID = bucket.ID
+			f.recordFailure(representative)
+		}
+		found = bucket
 		f.summary.Failed++
 
 		if result.StateInconsistency {
@@ -142,7 +580,20 @@ func (f *FuzzEngine) trackResult(result *FuzzResult) {
 		if result.Crashed {
 			f.summary.Crashes++
 		}
+		if result.AcceptedInvalid {
+			f.summary.AcceptedInvalid++
+		}
+		if result.Divergence {
+			f.summary.Divergences++
+		}
+		if result.Hang {
+			f.summary.Hangs++
+		}
+		if result.OOM {
+			f.summary.OOMs++
+		}
 	}
+	return found
 }
 
 // recordFailure saves detailed information about a failed test
@@ -151,21 +602,68 @@ func (f *FuzzEngine) recordFailure(result FuzzResult) {
 	filename := filepath.Join(f.config.OutputDir,
 		fmt.Sprintf("failure_%s.json", result.ID))
 
-	// Save failure details to file
-	file, err := os.Create(filename)
-	if err != nil {
+	if err := saveResult(filename, result); err != nil {
 		log.Printf("Warning: Failed to save failure details: %v", err)
 		return
 	}
+
+	if f.config.Verbose {
+		log.Printf("Failure detected [%s]: %s", result.ID, result.ErrorMessage)
+	}
+
+	if f.config.Minimize && f.target != nil {
+		minimized := NewMinimizer(f.target, f.diffTarget, f.limits()).Minimize(result)
+		filename = filepath.Join(f.config.OutputDir,
+			fmt.Sprintf("failure_%s.min.json", result.ID))
+		if err := saveResult(filename, minimized); err != nil {
+			log.Printf("Warning: Failed to save minimized failure: %v", err)
+		}
+
+		if f.config.Verbose {
+			log.Printf("Minimized [%s]: %d -> %d bytes, %d -> %d steps", result.ID,
+				len(result.Input), len(minimized.Input), len(result.Steps), len(minimized.Steps))
+		}
+	}
+}
+
+// saveSummary writes the final summary of a run as JSON to filename
+func saveSummary(filename string, summary FuzzSummary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// saveResult writes a result as JSON to filename
+func saveResult(filename string, result FuzzResult) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
 	defer file.Close()
 
 	// Serialize the result to JSON
 	encoder := json.NewEncoder(file)
 	if err := encoder.Encode(result); err != nil {
-		log.Printf("Warning: Failed to serialize failure details: %v", err)
+		return fmt.Errorf("failed to serialize result: %v", err)
 	}
 
-	if f.config.Verbose {
-		log.Printf("Failure detected [%s]: %s", result.ID, result.ErrorMessage)
+	return nil
+}
+
+// loadResult reads a result previously written by saveResult
+func loadResult(filename string) (FuzzResult, error) {
+	var result FuzzResult
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return result, err
+	}
+
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, fmt.Errorf("failed to parse %s: %v", filename, err)
 	}
+
+	return result, nil
 }
@@ -0,0 +1,569 @@
+package engine
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+gen-harness writes native Go fuzz tests for a module, one FuzzXxx per
+MsgServer method of its keeper, into keeper/statestinger_fuzz_test.go. Each
+test decodes the fuzz bytes into the method's request, drops messages that
+fail ValidateBasic as the ante handler would, and calls the method on a
+keeper backed by an in-memory store. Only panics fail a test, errors are
+how handlers reject messages.
+
+The keeper comes from the module's keepertest helper when it has one, the
+<Module>Keeper(t testing.TB) function scaffolded chains keep in
+testutil/keeper. Otherwise the file sets up the store itself, the same way
+those helpers do, and passes NewKeeper what it can build in memory. Keepers
+that depend on anything else, such as other modules' keepers, need the
+helper.
+*/
+
+const (
+	harnessFileName = "statestinger_fuzz_test.go"
+	harnessHeader   = "// Code generated by statestinger gen-harness. DO NOT EDIT."
+)
+
+// MsgMethod is a MsgServer method of the keeper
+type MsgMethod struct {
+	Name    string // Method name, e.g. Send
+	Request string // Request type in the types package, e.g. MsgSend
+	Proto   bool   // The request has a gogoproto Unmarshal method, JSON is used otherwise
+}
+
+// HarnessGenerator writes native Go fuzz tests for a module's MsgServer
+type HarnessGenerator struct {
+	module     string
+	root       string
+	keeperPath string // Import path of the keeper package
+	typesPath  string // Import path of the package holding the requests
+	methods    []MsgMethod
+	helper     string   // Name of the keepertest helper, "" to set up the store here
+	helperPath string   // Import path of the keepertest package
+	newKeeper  []string // Parameter types of keeper.NewKeeper, nil if there is none
+}
+
+// NewHarnessGenerator creates a generator for the module at root
+func NewHarnessGenerator(module, root string) *HarnessGenerator {
+	return &HarnessGenerator{module: module, root: root}
+}
+
+// Methods returns the MsgServer methods found by Discover
+func (g *HarnessGenerator) Methods() []MsgMethod {
+	return g.methods
+}
+
+// Helper returns the keepertest helper the tests use, "" if they set up
+// the store themselves
+func (g *HarnessGenerator) Helper() string {
+	return g.helper
+}
+
+// Discover finds the MsgServer methods of the keeper, their request types
+// and how to construct the keeper
+func (g *HarnessGenerator) Discover() error {
+	modRoot, modPath, err := findGoModule(g.root)
+	if err != nil {
+		return err
+	}
+	keeperDir := filepath.Join(g.root, "keeper")
+	g.keeperPath, err = importPath(modRoot, modPath, keeperDir)
+	if err != nil {
+		return err
+	}
+
+	files, err := parseGoDir(keeperDir)
+	if err != nil {
+		return err
+	}
+
+	hasServerImpl := false
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			if fn.Recv == nil {
+				switch fn.Name.Name {
+				case "NewMsgServerImpl":
+					hasServerImpl = true
+				case "NewKeeper":
+					g.newKeeper = []string{}
+					for _, field := range fn.Type.Params.List {
+						kind := types.ExprString(field.Type)
+						for range max(len(field.Names), 1) {
+							g.newKeeper = append(g.newKeeper, kind)
+						}
+					}
+				}
+				continue
+			}
+
+			request, pkg, ok := msgServerSignature(fn)
+			if !ok {
+				continue
+			}
+			typesPath, ok := fileImport(file, pkg)
+			if !ok {
+				continue
+			}
+			if g.typesPath == "" {
+				g.typesPath = typesPath
+			} else if g.typesPath != typesPath {
+				log.Printf("Warning: Skipping %s, its request is not in %s", fn.Name.Name, g.typesPath)
+				continue
+			}
+			g.methods = append(g.methods, MsgMethod{Name: fn.Name.Name, Request: request})
+		}
+	}
+
+	if len(g.methods) == 0 {
+		return fmt.Errorf("no MsgServer methods found in %s", keeperDir)
+	}
+	if !hasServerImpl {
+		return fmt.Errorf("keeper has no NewMsgServerImpl to build the MsgServer with")
+	}
+
+	// Requests generated by gogoproto decode themselves
+	if typesDir, ok := packageDir(modRoot, modPath, g.typesPath); ok {
+		unmarshalers := protoUnmarshalers(typesDir)
+		for i := range g.methods {
+			g.methods[i].Proto = unmarshalers[g.methods[i].Request]
+		}
+	} else {
+		log.Printf("Warning: %s is outside the module, decoding requests as JSON", g.typesPath)
+	}
+
+	g.findHelper(modRoot, modPath)
+	if g.helper == "" && g.newKeeper == nil {
+		return fmt.Errorf("keeper has neither a keepertest helper nor a NewKeeper to set it up with")
+	}
+
+	// A nil keeper dependency would fail every test with a nil dereference
+	// that is no bug of the module
+	if g.helper == "" {
+		var missing []string
+		for _, param := range g.newKeeper {
+			if arg, _ := keeperArg(param); arg == "" {
+				missing = append(missing, param)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("NewKeeper takes %s, which have no in-memory stand-in; add a %sKeeper(t testing.TB) helper to testutil/keeper that builds the keeper with them",
+				strings.Join(missing, ", "), strings.ToUpper(g.module[:1])+g.module[1:])
+		}
+	}
+	return nil
+}
+
+// msgServerSignature matches the shape of a MsgServer method,
+// func (k msgServer) Send(context.Context, *types.MsgSend) (*types.MsgSendResponse, error),
+// and returns the request type and the package it is qualified with
+func msgServerSignature(fn *ast.FuncDecl) (string, string, bool) {
+	if !fn.Name.IsExported() {
+		return "", "", false
+	}
+	params, results := fn.Type.Params.List, fn.Type.Results
+	if len(params) != 2 || len(params[0].Names) > 1 || len(params[1].Names) > 1 || results == nil || len(results.List) != 2 {
+		return "", "", false
+	}
+	if types.ExprString(params[0].Type) != "context.Context" || types.ExprString(results.List[1].Type) != "error" {
+		return "", "", false
+	}
+
+	star, ok := params[1].Type.(*ast.StarExpr)
+	if !ok {
+		return "", "", false
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	if !ok || !strings.HasPrefix(sel.Sel.Name, "Msg") {
+		return "", "", false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return "", "", false
+	}
+	if types.ExprString(results.List[0].Type) != "*"+pkg.Name+"."+sel.Sel.Name+"Response" {
+		return "", "", false
+	}
+	return sel.Sel.Name, pkg.Name, true
+}
+
+// findHelper looks for the <Module>Keeper function of testutil/keeper
+func (g *HarnessGenerator) findHelper(modRoot, modPath string) {
+	dir := filepath.Join(modRoot, "testutil", "keeper")
+	files, err := parseGoDir(dir)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || !strings.EqualFold(fn.Name.Name, g.module+"Keeper") {
+				continue
+			}
+			if len(fn.Type.Params.List) != 1 || fn.Type.Results == nil || len(fn.Type.Results.List) != 2 {
+				continue
+			}
+			if path, err := importPath(modRoot, modPath, dir); err == nil {
+				g.helper, g.helperPath = fn.Name.Name, path
+			}
+			return
+		}
+	}
+}
+
+// protoUnmarshalers returns the types of a package with an Unmarshal method
+func protoUnmarshalers(dir string) map[string]bool {
+	found := make(map[string]bool)
+	files, err := parseGoDir(dir)
+	if err != nil {
+		return found
+	}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Name.Name != "Unmarshal" {
+				continue
+			}
+			recv := fn.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+			if ident, ok := recv.(*ast.Ident); ok {
+				found[ident.Name] = true
+			}
+		}
+	}
+	return found
+}
+
+// Generate returns the source of the fuzz tests
+func (g *HarnessGenerator) Generate() ([]byte, error) {
+	imports := map[string]string{
+		"testing":    "",
+		g.keeperPath: "",
+		g.typesPath:  importAlias(g.typesPath, "types"),
+	}
+	for _, method := range g.methods {
+		if !method.Proto {
+			imports["encoding/json"] = ""
+		}
+	}
+
+	var body bytes.Buffer
+	setup := "fuzzKeeper"
+	if g.helper != "" {
+		setup = "keepertest." + g.helper
+		imports[g.helperPath] = "keepertest"
+	} else {
+		g.writeSetup(&body, imports)
+	}
+
+	for _, method := range g.methods {
+		// Seed with an empty request
+		seed, decode := "[]byte{}", "msg.Unmarshal(data)"
+		if !method.Proto {
+			seed, decode = `[]byte("{}")`, "json.Unmarshal(data, msg)"
+		}
+		fmt.Fprintf(&body, `
+// Fuzz%[1]s calls MsgServer.%[1]s with a %[2]s decoded from the fuzz input
+func Fuzz%[1]s(f *testing.F) {
+	f.Add(%[5]s)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg := &types.%[2]s{}
+		if err := %[3]s; err != nil {
+			return
+		}
+		// The ante handler never lets invalid messages through
+		if m, ok := any(msg).(interface{ ValidateBasic() error }); ok && m.ValidateBasic() != nil {
+			return
+		}
+
+		k, ctx := %[4]s(t)
+		srv := keeper.NewMsgServerImpl(k)
+		_, _ = srv.%[1]s(ctx, msg)
+	})
+}
+`, method.Name, method.Request, decode, setup, seed)
+	}
+
+	// Standard library imports go first, in a group of their own
+	var std, others []string
+	for path := range imports {
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			others = append(others, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(others)
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "%s\n\npackage keeper_test\n\nimport (\n", harnessHeader)
+	for i, group := range [][]string{std, others} {
+		if i > 0 {
+			src.WriteString("\n")
+		}
+		for _, path := range group {
+			fmt.Fprintf(&src, "\t%s %q\n", imports[path], path)
+		}
+	}
+	src.WriteString(")\n")
+	src.Write(body.Bytes())
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid source: %v", err)
+	}
+	return formatted, nil
+}
+
+// writeSetup writes fuzzKeeper, which builds the keeper on an in-memory
+// store as keepertest helpers do
+func (g *HarnessGenerator) writeSetup(body *bytes.Buffer, imports map[string]string) {
+	for path, alias := range map[string]string{
+		"cosmossdk.io/log":                                    "",
+		"cosmossdk.io/store":                                  "",
+		"cosmossdk.io/store/metrics":                          "",
+		"cosmossdk.io/store/types":                            "storetypes",
+		"github.com/cometbft/cometbft/proto/tendermint/types": "cmtproto",
+		"github.com/cosmos/cosmos-db":                         "dbm",
+		"github.com/cosmos/cosmos-sdk/types":                  "sdk",
+	} {
+		imports[path] = alias
+	}
+
+	var args []string
+	for _, param := range g.newKeeper {
+		arg, needs := keeperArg(param)
+		for path, alias := range needs {
+			imports[path] = alias
+		}
+		args = append(args, arg)
+	}
+	call := "keeper.NewKeeper()"
+	if len(args) > 0 {
+		call = "keeper.NewKeeper(\n" + strings.Join(args, ",\n") + ",\n)"
+	}
+
+	fmt.Fprintf(body, `
+// fuzzKeeper sets up the keeper on an in-memory store
+func fuzzKeeper(tb testing.TB) (keeper.Keeper, sdk.Context) {
+	storeKey := storetypes.NewKVStoreKey(types.StoreKey)
+	db := dbm.NewMemDB()
+	stateStore := store.NewCommitMultiStore(db, log.NewNopLogger(), metrics.NewNoOpMetrics())
+	stateStore.MountStoreWithDB(storeKey, storetypes.StoreTypeIAVL, db)
+	if err := stateStore.LoadLatestVersion(); err != nil {
+		tb.Fatal(err)
+	}
+
+	k := %s
+	ctx := sdk.NewContext(stateStore, cmtproto.Header{}, false, log.NewNopLogger())
+	return k, ctx
+}
+`, call)
+}
+
+// keeperArg returns what to pass NewKeeper for a parameter of the given
+// type and the imports that needs, or "" if nothing fits
+func keeperArg(param string) (string, map[string]string) {
+	switch {
+	case param == "codec.BinaryCodec" || param == "codec.Codec":
+		return "codec.NewProtoCodec(codectypes.NewInterfaceRegistry())", map[string]string{
+			"github.com/cosmos/cosmos-sdk/codec":       "",
+			"github.com/cosmos/cosmos-sdk/codec/types": "codectypes",
+		}
+	case strings.HasSuffix(param, "KVStoreService"):
+		return "runtime.NewKVStoreService(storeKey)", map[string]string{
+			"github.com/cosmos/cosmos-sdk/runtime": "",
+		}
+	case strings.HasSuffix(param, "StoreKey"):
+		return "storeKey", nil
+	case param == "log.Logger":
+		return "log.NewNopLogger()", nil
+	case param == "string":
+		// The authority, which is the gov module account
+		return "authtypes.NewModuleAddress(govtypes.ModuleName).String()", map[string]string{
+			"github.com/cosmos/cosmos-sdk/x/auth/types": "authtypes",
+			"github.com/cosmos/cosmos-sdk/x/gov/types":  "govtypes",
+		}
+	}
+	return "", nil
+}
+
+// importAlias returns alias if the package at path would not be named
+// alias by default, "" otherwise
+func importAlias(importPath, alias string) string {
+	if path.Base(importPath) == alias {
+		return ""
+	}
+	return alias
+}
+
+// findGoModule returns the directory of the go.mod above dir and its module path
+func findGoModule(dir string) (string, string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+					modPath := strings.TrimSpace(rest)
+					if unquoted, err := strconv.Unquote(modPath); err == nil {
+						modPath = unquoted
+					}
+					return dir, modPath, nil
+				}
+			}
+			return "", "", fmt.Errorf("no module path in %s", filepath.Join(dir, "go.mod"))
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", fmt.Errorf("no go.mod found above the module, go test needs one")
+		}
+		dir = parent
+	}
+}
+
+// importPath returns the import path of dir inside the module at modRoot
+func importPath(modRoot, modPath, dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(modRoot, dir)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." {
+		return modPath, nil
+	}
+	return modPath + "/" + filepath.ToSlash(rel), nil
+}
+
+// packageDir is the inverse of importPath
+func packageDir(modRoot, modPath, importPath string) (string, bool) {
+	if importPath == modPath {
+		return modRoot, true
+	}
+	rel, ok := strings.CutPrefix(importPath, modPath+"/")
+	if !ok {
+		return "", false
+	}
+	return filepath.Join(modRoot, filepath.FromSlash(rel)), true
+}
+
+// parseGoDir parses the non-test Go files of dir in name order
+func parseGoDir(dir string) ([]*ast.File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// fileImport returns the path a file imports under the name pkg
+func fileImport(file *ast.File, pkg string) (string, bool) {
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := path.Base(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name == pkg {
+			return importPath, true
+		}
+	}
+	return "", false
+}
+
+// runGenHarness implements `statestinger gen-harness [flags] <module>`
+func runGenHarness(args []string) {
+	fs := flag.NewFlagSet("gen-harness", flag.ExitOnError)
+	moduleName := fs.String("module", "", "Name of the module (default: the directory name)")
+	force := fs.Bool("force", false, "Overwrite the test file even if it was not generated")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: statestinger gen-harness [flags] <module>\n")
+		fmt.Fprintf(fs.Output(), "Writes a FuzzXxx test per MsgServer method to keeper/%s.\n", harnessFileName)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	root := fs.Arg(0)
+	if *moduleName == "" {
+		*moduleName = filepath.Base(root)
+	}
+
+	generator := NewHarnessGenerator(*moduleName, root)
+	if err := generator.Discover(); err != nil {
+		log.Fatalf("Failed to discover MsgServer methods: %v", err)
+	}
+	src, err := generator.Generate()
+	if err != nil {
+		log.Fatalf("Failed to generate fuzz tests: %v", err)
+	}
+
+	// Never overwrite tests someone wrote by hand
+	filename := filepath.Join(root, "keeper", harnessFileName)
+	if existing, err := os.ReadFile(filename); err == nil && !bytes.HasPrefix(existing, []byte(harnessHeader)) && !*force {
+		log.Fatalf("%s exists and was not generated, use -force to overwrite it", filename)
+	}
+	if err := os.WriteFile(filename, src, 0644); err != nil {
+		log.Fatalf("Failed to write fuzz tests: %v", err)
+	}
+
+	for _, method := range generator.Methods() {
+		decoding := "protobuf"
+		if !method.Proto {
+			decoding = "JSON"
+		}
+		fmt.Printf("Fuzz%s: %s from %s\n", method.Name, method.Request, decoding)
+	}
+	setup := "its own in-memory store"
+	if generator.Helper() != "" {
+		setup = "keepertest." + generator.Helper()
+	}
+	fmt.Printf("\nWrote %d fuzz tests using %s to %s\n", len(generator.Methods()), setup, filename)
+	fmt.Printf("Run them with: statestinger gofuzz %s\n", root)
+}
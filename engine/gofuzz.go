@@ -0,0 +1,267 @@
+package engine
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoSec-Labs/StateStinger/utils/target/cosmossdk"
+)
+
+// goFuzzTests lists the fuzz tests of the package in dir
+func goFuzzTests(dir string) ([]string, error) {
+	cmd := exec.Command("go", "test", "-list", "^Fuzz", ".")
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("go test -list failed: %v\n%s", err, lastLines(string(output), 10))
+	}
+
+	var tests []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "Fuzz") {
+			tests = append(tests, strings.TrimSpace(line))
+		}
+	}
+	return tests, nil
+}
+
+// readCorpusEntry decodes a file of testdata/fuzz written by go test, which
+// holds a single []byte value
+func readCorpusEntry(filename string) ([]byte, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || lines[0] != "go test fuzz v1" {
+		return nil, fmt.Errorf("%s is not a go test fuzz v1 entry with one value", filename)
+	}
+
+	value, ok := strings.CutPrefix(lines[1], "[]byte(")
+	value, ok2 := strings.CutSuffix(value, ")")
+	if !ok || !ok2 {
+		return nil, fmt.Errorf("%s does not hold a []byte", filename)
+	}
+	input, err := strconv.Unquote(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", filename, err)
+	}
+	return []byte(input), nil
+}
+
+// goTestFailure is what go test printed about a failing corpus entry
+type goTestFailure struct {
+	Message string
+	Panic   *cosmossdk.PanicInfo
+}
+
+var panicSuffix = regexp.MustCompile(` \[recovered.*\]$`)
+
+// parseGoTestFailure picks the panic or the test's own error out of the
+// output of go test
+func parseGoTestFailure(output string) goTestFailure {
+	var failure goTestFailure
+	var stack []string
+	inStack := false
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(nil, maxHarnessOutput)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case failure.Panic == nil && strings.HasPrefix(line, "panic: "):
+			value := panicSuffix.ReplaceAllString(strings.TrimPrefix(line, "panic: "), "")
+			failure.Panic = &cosmossdk.PanicInfo{
+				Value:   value,
+				Runtime: strings.HasPrefix(value, "runtime error: "),
+			}
+		case failure.Panic != nil && strings.HasPrefix(line, "goroutine ") && !inStack:
+			inStack = true
+			stack = append(stack, line)
+		case inStack:
+			if line == "" || strings.HasPrefix(line, "FAIL") || strings.HasPrefix(line, "exit status") {
+				inStack = false
+				continue
+			}
+			stack = append(stack, line)
+		case failure.Panic == nil && failure.Message == "" && strings.HasPrefix(line, "    ") &&
+			!strings.HasPrefix(strings.TrimSpace(line), "--- "):
+			// The first message the test logged before failing
+			failure.Message = strings.TrimSpace(line)
+		}
+	}
+
+	if failure.Panic != nil {
+		failure.Panic.Stack = strings.Join(stack, "\n")
+		failure.Message = panicMessage(Execution{Panic: failure.Panic})
+	} else if failure.Message == "" {
+		failure.Message = "Fuzz test failed"
+	}
+	return failure
+}
+
+// importCrashers turns the corpus entries go test saved for a fuzz test into
+// findings. Entries that no longer fail are left out.
+func importCrashers(dir, test, module string) ([]FuzzResult, int, error) {
+	corpusDir := filepath.Join(dir, "testdata", "fuzz", test)
+	entries, err := os.ReadDir(corpusDir)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var results []FuzzResult
+	passing := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		filename := filepath.Join(corpusDir, entry.Name())
+		input, err := readCorpusEntry(filename)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+
+		cmd := exec.Command("go", "test", "-run", "^"+test+"$/^"+regexp.QuoteMeta(entry.Name())+"$", ".")
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if err == nil {
+			passing++
+			continue
+		}
+
+		failure := parseGoTestFailure(string(output))
+		result := FuzzResult{
+			ID:           fmt.Sprintf("gofuzz_%s_%s", test, entry.Name()),
+			Module:       module,
+			Input:        input,
+			Handler:      strings.TrimPrefix(test, "Fuzz"),
+			ErrorMessage: failure.Message,
+			Failed:       true,
+			Crashed:      true,
+			FuzzTest:     test,
+			CorpusFile:   filename,
+		}
+		attachPanic(&result, failure.Panic)
+		results = append(results, result)
+	}
+	return results, passing, nil
+}
+
+// runGoFuzz implements `statestinger gofuzz [flags] <module>`
+func runGoFuzz(args []string) {
+	fs := flag.NewFlagSet("gofuzz", flag.ExitOnError)
+	outputDir := fs.String("output", "./gofuzz_results", "Directory to store findings")
+	moduleName := fs.String("module", "", "Name of the module (default: the directory name)")
+	fuzzTime := fs.Duration("fuzztime", 30*time.Second, "How long to fuzz each test")
+	run := fs.String("run", "", "Only fuzz the tests matching this regular expression")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: statestinger gofuzz [flags] <module>\n")
+		fmt.Fprintf(fs.Output(), "Runs go test -fuzz on the keeper's fuzz tests, see gen-harness, and imports\n")
+		fmt.Fprintf(fs.Output(), "the crashers it saves. Exits with status 1 if any crasher fails.\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	root := fs.Arg(0)
+	if *moduleName == "" {
+		*moduleName = filepath.Base(root)
+	}
+	var filter *regexp.Regexp
+	if *run != "" {
+		var err error
+		if filter, err = regexp.Compile(*run); err != nil {
+			log.Fatalf("Invalid -run pattern: %v", err)
+		}
+	}
+
+	keeperDir := filepath.Join(root, "keeper")
+	tests, err := goFuzzTests(keeperDir)
+	if err != nil {
+		log.Fatalf("Failed to list fuzz tests: %v", err)
+	}
+	if len(tests) == 0 {
+		log.Fatalf("No fuzz tests in %s, generate them with statestinger gen-harness", keeperDir)
+	}
+
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		log.Fatalf("Error creating output directory: %v", err)
+	}
+
+	// go test fuzzes one test at a time and stops at its first crasher
+	var findings []FuzzResult
+	fuzzed, passing := 0, 0
+	for _, test := range tests {
+		if filter != nil && !filter.MatchString(test) {
+			continue
+		}
+		fuzzed++
+
+		log.Printf("Fuzzing %s for %s", test, *fuzzTime)
+		cmd := exec.Command("go", "test", "-run", "^$", "-fuzz", "^"+test+"$", "-fuzztime", fuzzTime.String(), ".")
+		cmd.Dir = keeperDir
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			log.Printf("%s: go test exited: %v", test, err)
+		}
+
+		results, fixed, err := importCrashers(keeperDir, test, *moduleName)
+		if err != nil {
+			log.Printf("Warning: Failed to import crashers of %s: %v", test, err)
+		}
+		findings = append(findings, results...)
+		passing += fixed
+	}
+
+	dedup := NewDeduplicator()
+	for i, finding := range findings {
+		finding.Iteration = i
+		fmt.Printf("%s/%s: %s\n", finding.FuzzTest, filepath.Base(finding.CorpusFile), finding.ErrorMessage)
+		bucket, changed := dedup.Add(finding)
+		if changed {
+			finding.ID = bucket.ID
+			filename := filepath.Join(*outputDir, fmt.Sprintf("failure_%s.json", finding.ID))
+			if err := saveResult(filename, finding); err != nil {
+				log.Printf("Warning: Failed to save finding: %v", err)
+			}
+		}
+	}
+	if err := dedup.Save(filepath.Join(*outputDir, "findings.json")); err != nil {
+		log.Printf("Warning: Failed to save findings index: %v", err)
+	}
+
+	fmt.Printf("\n=== StateStinger Go Fuzz Results ===\n")
+	fmt.Printf("Fuzz tests run: %d\n", fuzzed)
+	fmt.Printf("Crashers imported: %d\n", len(findings))
+	if passing > 0 {
+		fmt.Printf("Saved inputs that now pass: %d\n", passing)
+	}
+	fmt.Printf("Unique findings: %d\n", dedup.Len())
+	if len(findings) > 0 {
+		fmt.Printf("\nDetailed failure reports saved to: %s\n", *outputDir)
+		os.Exit(1)
+	}
+}
+
+// goFuzzCommand is how to rerun a finding imported by gofuzz
+func goFuzzCommand(result FuzzResult) string {
+	return fmt.Sprintf("go test -run '^%s$/^%s$' in %s", result.FuzzTest,
+		filepath.Base(result.CorpusFile), filepath.Dir(filepath.Dir(filepath.Dir(filepath.Dir(result.CorpusFile)))))
+}
@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCorpusEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []byte
+		wantErr bool
+	}{
+		{"plain", "go test fuzz v1\n[]byte(\"\\x00\\x01{}\")\n", []byte("\x00\x01{}"), false},
+		{"without trailing newline", "go test fuzz v1\n[]byte(\"abc\")", []byte("abc"), false},
+		{"raw string", "go test fuzz v1\n[]byte(`a\"b`)\n", []byte("a\"b"), false},
+		{"empty value", "go test fuzz v1\n[]byte(\"\")\n", []byte{}, false},
+		{"other version", "go test fuzz v2\n[]byte(\"abc\")\n", nil, true},
+		{"two values", "go test fuzz v1\n[]byte(\"a\")\n[]byte(\"b\")\n", nil, true},
+		{"not a byte slice", "go test fuzz v1\nstring(\"abc\")\n", nil, true},
+		{"bad quoting", "go test fuzz v1\n[]byte(\"abc)\n", nil, true},
+		{"empty file", "", nil, true},
+	}
+
+	dir := t.TempDir()
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(dir, strings.Repeat("f", i+1))
+			require.NoError(t, os.WriteFile(filename, []byte(tt.content), 0644))
+
+			got, err := readCorpusEntry(filename)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := readCorpusEntry(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestParseGoTestFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		message string
+		panic   string // Panic value, "" if there should be none
+		runtime bool
+		stack   []string // Lines the stack starts and ends with
+	}{
+		{
+			name: "runtime panic",
+			output: `--- FAIL: FuzzSend (0.00s)
+    --- FAIL: FuzzSend/582528ddfad69eb5 (0.00s)
+panic: runtime error: invalid memory address or nil pointer dereference [recovered]
+	panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x5f1e2a]
+
+goroutine 7 [running]:
+testing.tRunner.func1.2({0x6b3e40, 0x9e5a70})
+	/usr/local/go/src/testing/testing.go:1632 +0x230
+panic({0x6b3e40?, 0x9e5a70?})
+	/usr/local/go/src/runtime/panic.go:770 +0x132
+example.com/x/keeper.msgServer.Send({0x0?}, {0x7a1c60?, 0xc0000a6000?}, 0xc0000b2000)
+	/src/x/keeper/msg_server.go:10 +0x2a
+FAIL	example.com/x/keeper	0.012s
+FAIL
+`,
+			message: "Runtime panic: runtime error: invalid memory address or nil pointer dereference",
+			panic:   "runtime error: invalid memory address or nil pointer dereference",
+			runtime: true,
+			stack:   []string{"goroutine 7 [running]:", "\t/src/x/keeper/msg_server.go:10 +0x2a"},
+		},
+		{
+			name: "handler panic",
+			output: `--- FAIL: FuzzSend (0.00s)
+    --- FAIL: FuzzSend/0a1b (0.00s)
+panic: negative coin amount [recovered]
+	panic: negative coin amount
+
+goroutine 21 [running]:
+example.com/x/keeper.Keeper.subUnlockedCoins(...)
+	/src/x/keeper/send.go:80
+
+exit status 2
+`,
+			message: "Handler panicked: negative coin amount",
+			panic:   "negative coin amount",
+			stack:   []string{"goroutine 21 [running]:", "\t/src/x/keeper/send.go:80"},
+		},
+		{
+			name: "test error",
+			output: `--- FAIL: FuzzSend (0.00s)
+    --- FAIL: FuzzSend/0a1b (0.00s)
+        statestinger_fuzz_test.go:31: supply changed by 5stake
+        statestinger_fuzz_test.go:32: second message
+FAIL
+`,
+			message: "statestinger_fuzz_test.go:31: supply changed by 5stake",
+		},
+		{
+			name:    "nothing to go by",
+			output:  "FAIL\texample.com/x/keeper [build failed]\n",
+			message: "Fuzz test failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failure := parseGoTestFailure(tt.output)
+			assert.Equal(t, tt.message, failure.Message)
+			if tt.panic == "" {
+				assert.Nil(t, failure.Panic)
+				return
+			}
+
+			require.NotNil(t, failure.Panic)
+			assert.Equal(t, tt.panic, failure.Panic.Value)
+			assert.Equal(t, tt.runtime, failure.Panic.Runtime)
+			lines := strings.Split(failure.Panic.Stack, "\n")
+			assert.Equal(t, tt.stack[0], lines[0])
+			assert.Equal(t, tt.stack[1], lines[len(lines)-1])
+		})
+	}
+}
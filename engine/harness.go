@@ -0,0 +1,508 @@
+package engine
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoSec-Labs/StateStinger/utils/target/cosmossdk"
+)
+
+/*
+In subprocess mode every worker runs the target in a child process, the
+statestinger binary started as `statestinger harness`, so that a hang or a
+runaway allocation costs one input rather than the campaign. The child
+reads harnessRequests from file descriptor 3 and answers each one with a
+harnessResponse on file descriptor 4, in the framing plugins use. Its
+stdout and stderr stay free for whatever the target prints. After loading
+the module it sends a response with Ready set. The store the target's
+handlers read stays with the supervisor: while executing an input the
+child may send responses with Get set, each answered by a request holding
+the value of that key, before the response with the result.
+
+The memory limit is a hard cap the kernel enforces. Once the child is
+ready the supervisor sets its RLIMIT_AS to the address space it reserved
+while starting up, which for the Go runtime is far more than it touches,
+plus the limit, and any allocation past that kills the child with the
+runtime's out of memory error. Where the cap cannot be set the supervisor
+falls back to polling the child's resident set and killing it once the
+limit is exceeded. The child also sets the limit as its soft memory limit,
+so the GC works harder close to it.
+*/
+
+const (
+	defaultExecTimeout  = 5 * time.Second
+	defaultMemoryLimit  = 2048 // MB
+	harnessStartTimeout = 30 * time.Second
+	memoryPollInterval  = 10 * time.Millisecond
+	maxHarnessOutput    = 64 << 10 // Bytes of child output kept for reports
+)
+
+var (
+	// ErrHang is the error of an execution the harness killed for taking too long
+	ErrHang = errors.New("execution timed out")
+	// ErrOOM is the error of an execution that ran the harness out of memory
+	ErrOOM = errors.New("out of memory")
+)
+
+// Executor runs inputs against the target, in process or in a harness, on
+// top of the store the target's handlers read
+type Executor interface {
+	ExecuteOn(input []byte, store cosmossdk.Store) *cosmossdk.ExecResult
+}
+
+// HarnessConfig describes the child process a Harness runs
+type HarnessConfig struct {
+	TargetPath  string
+	ModuleName  string
+	Timeout     time.Duration // Per input
+	MemoryLimit int           // Memory the child may allocate past its startup in MB, 0 for none
+	SDKPanics   bool
+	GOMAXPROCS  int  // GOMAXPROCS of the child, 0 to inherit the supervisor's
+	Perturb     bool // Perturb the scheduling of every execution, see perturb
+	Noise       int  // Goroutines yielding alongside a perturbed execution
+}
+
+type harnessRequest struct {
+	Input []byte `json:",omitempty"`
+	Value []byte `json:",omitempty"` // Answer to a Get, with Found
+	Found bool   `json:",omitempty"`
+}
+
+type harnessResponse struct {
+	Ready    bool                    `json:",omitempty"`
+	Get      string                  `json:",omitempty"` // Store key the target reads, not a result yet
+	Output   []byte                  `json:",omitempty"`
+	Error    *string                 `json:",omitempty"`
+	ErrPanic bool                    `json:",omitempty"` // Error wraps cosmossdk.ErrPanic
+	Handler  string                  `json:",omitempty"`
+	Edges    []uint32                `json:",omitempty"`
+	Delta    []cosmossdk.StateChange `json:",omitempty"`
+	Events   []string                `json:",omitempty"`
+	GasUsed  uint64                  `json:",omitempty"`
+	Panic    *cosmossdk.PanicInfo    `json:",omitempty"`
+}
+
+// remoteError is an error returned in the child, which keeps wrapping
+// ErrPanic across the pipe
+type remoteError struct {
+	message string
+	wrapped error
+}
+
+func (e *remoteError) Error() string {
+	return e.message
+}
+
+func (e *remoteError) Unwrap() error {
+	return e.wrapped
+}
+
+// tailBuffer keeps the last bytes written to it
+type tailBuffer struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if len(b.data) > maxHarnessOutput {
+		b.data = append(b.data[:0], b.data[len(b.data)-maxHarnessOutput:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.data)
+}
+
+// harnessProcess is one running child
+type harnessProcess struct {
+	cmd       *exec.Cmd
+	requests  *os.File
+	responses *bufio.Reader
+	pipe      *os.File // Read end of the responses, closed with the child
+	output    *tailBuffer
+	capped    bool // The kernel enforces the memory limit on the child's address space
+}
+
+// Harness runs inputs in a child process, restarting it after every crash,
+// hang or OOM. It is used by one worker at a time.
+type Harness struct {
+	config   HarnessConfig
+	proc     *harnessProcess
+	failures int  // Consecutive failed starts
+	polling  bool // Warned that the memory limit falls back to polling
+}
+
+// NewHarness creates a harness. The child is started on first use.
+func NewHarness(config HarnessConfig) *Harness {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultExecTimeout
+	}
+	return &Harness{config: config}
+}
+
+func (h *Harness) start() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	args := []string{"harness", "-target", h.config.TargetPath, "-module", h.config.ModuleName,
+		"-memory-limit", fmt.Sprint(h.config.MemoryLimit)}
+	if h.config.SDKPanics {
+		args = append(args, "-sdk-panics")
+	}
+	if h.config.Perturb {
+		args = append(args, "-perturb", "-noise", fmt.Sprint(h.config.Noise))
+	}
+	cmd := exec.Command(executable, args...)
+	if h.config.GOMAXPROCS > 0 {
+		cmd.Env = append(os.Environ(), fmt.Sprintf("GOMAXPROCS=%d", h.config.GOMAXPROCS))
+	}
+
+	requestsRead, requestsWrite, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	responsesRead, responsesWrite, err := os.Pipe()
+	if err != nil {
+		requestsRead.Close()
+		requestsWrite.Close()
+		return err
+	}
+
+	output := &tailBuffer{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.ExtraFiles = []*os.File{requestsRead, responsesWrite}
+	// Ctrl-C is for the supervisor, which drains its in-flight inputs and
+	// stops the children itself
+	detach(cmd)
+	err = cmd.Start()
+
+	// The child holds its own copies of these ends
+	requestsRead.Close()
+	responsesWrite.Close()
+	if err != nil {
+		requestsWrite.Close()
+		responsesRead.Close()
+		return fmt.Errorf("failed to start harness: %v", err)
+	}
+
+	proc := &harnessProcess{
+		cmd:       cmd,
+		requests:  requestsWrite,
+		responses: bufio.NewReader(responsesRead),
+		pipe:      responsesRead,
+		output:    output,
+	}
+
+	var ready harnessResponse
+	if err := proc.exchange(nil, &ready, nil, harnessStartTimeout, 0); err != nil || !ready.Ready {
+		proc.kill()
+		return fmt.Errorf("harness did not start: %v\n%s", err, lastLines(output.String(), 5))
+	}
+
+	if h.config.MemoryLimit > 0 {
+		err := capAddressSpace(cmd.Process.Pid, uint64(h.config.MemoryLimit)<<20)
+		proc.capped = err == nil
+		if err != nil && !h.polling {
+			log.Printf("Warning: Polling the harness's resident memory, its address space cannot be capped: %v", err)
+			h.polling = true
+		}
+	}
+
+	h.proc = proc
+	return nil
+}
+
+// exchange sends req, unless it is nil, and waits up to timeout for the
+// response, answering the child's reads of store on the way. A child that
+// does not answer in time, or whose resident memory grows past memoryLimit
+// bytes, is killed.
+func (p *harnessProcess) exchange(req *harnessRequest, resp *harnessResponse, store cosmossdk.Store, timeout time.Duration, memoryLimit uint64) error {
+	done := make(chan error, 1)
+	go func() {
+		if req != nil {
+			if err := writeFrame(p.requests, req); err != nil {
+				done <- err
+				return
+			}
+		}
+		for {
+			if err := readFrame(p.responses, resp); err != nil || resp.Get == "" {
+				done <- err
+				return
+			}
+			var answer harnessRequest
+			if store != nil {
+				answer.Value, answer.Found = store.Get(resp.Get)
+			}
+			if err := writeFrame(p.requests, &answer); err != nil {
+				done <- err
+				return
+			}
+			*resp = harnessResponse{}
+		}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var poll <-chan time.Time
+	if memoryLimit > 0 {
+		ticker := time.NewTicker(memoryPollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-timer.C:
+			p.kill()
+			<-done
+			return ErrHang
+		case <-poll:
+			resident, err := residentMemory(p.cmd.Process.Pid)
+			if err != nil {
+				// Not measurable here, or the child already exited
+				poll = nil
+				continue
+			}
+			if resident > memoryLimit {
+				p.kill()
+				<-done
+				return ErrOOM
+			}
+		}
+	}
+}
+
+// kill stops the child and waits for it to exit
+func (p *harnessProcess) kill() {
+	p.requests.Close()
+	if p.cmd.Process != nil {
+		p.cmd.Process.Kill()
+	}
+	p.cmd.Wait()
+	p.pipe.Close()
+}
+
+// ExecuteOn runs input in the child on top of store. Hangs, OOMs and
+// crashes of the child come back as results, the next input gets a fresh
+// child.
+func (h *Harness) ExecuteOn(input []byte, store cosmossdk.Store) *cosmossdk.ExecResult {
+	if h.proc == nil {
+		if err := h.start(); err != nil {
+			h.failures++
+			if h.failures >= maxPluginRestarts {
+				log.Fatalf("Harness failed to start %d times in a row: %v", h.failures, err)
+			}
+			log.Printf("Warning: %v", err)
+			return &cosmossdk.ExecResult{Err: err}
+		}
+		h.failures = 0
+	}
+
+	var resp harnessResponse
+	var memoryLimit uint64
+	if !h.proc.capped {
+		memoryLimit = uint64(h.config.MemoryLimit) << 20
+	}
+	err := h.proc.exchange(&harnessRequest{Input: input}, &resp, store, h.config.Timeout, memoryLimit)
+	if err == nil {
+		return resp.result()
+	}
+
+	proc := h.proc
+	h.proc = nil
+	switch {
+	case errors.Is(err, ErrHang):
+		return &cosmossdk.ExecResult{Err: fmt.Errorf("%w after %s", ErrHang, h.config.Timeout)}
+	case errors.Is(err, ErrOOM):
+		return &cosmossdk.ExecResult{Err: fmt.Errorf("%w after exceeding %d MB", ErrOOM, h.config.MemoryLimit)}
+	}
+
+	// The child died on this input
+	proc.kill()
+	output := proc.output.String()
+	result := &cosmossdk.ExecResult{
+		Panic: &cosmossdk.PanicInfo{
+			Value:   fatalError(output, proc.cmd.ProcessState),
+			Stack:   output,
+			Runtime: true,
+		},
+	}
+	switch {
+	case outOfMemory(output) && proc.capped:
+		result.Err = fmt.Errorf("%w after exceeding %d MB", ErrOOM, h.config.MemoryLimit)
+	case outOfMemory(output):
+		result.Err = ErrOOM
+	default:
+		result.Err = fmt.Errorf("harness exited: %s", result.Panic.Value)
+	}
+	return result
+}
+
+// Close stops the child
+func (h *Harness) Close() error {
+	if h.proc != nil {
+		h.proc.kill()
+		h.proc = nil
+	}
+	return nil
+}
+
+// result rebuilds the child's ExecResult
+func (r *harnessResponse) result() *cosmossdk.ExecResult {
+	result := &cosmossdk.ExecResult{
+		Output:  r.Output,
+		Handler: r.Handler,
+		Edges:   r.Edges,
+		Delta:   r.Delta,
+		Events:  r.Events,
+		GasUsed: r.GasUsed,
+		Panic:   r.Panic,
+	}
+	if r.Error != nil {
+		err := &remoteError{message: *r.Error}
+		if r.ErrPanic {
+			err.wrapped = cosmossdk.ErrPanic
+		}
+		result.Err = err
+	}
+	return result
+}
+
+// fatalError picks the line that says why the child died out of its output
+func fatalError(output string, state *os.ProcessState) string {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "fatal error: ") {
+			return line
+		}
+		if value, ok := strings.CutPrefix(line, "panic: "); ok {
+			return value
+		}
+	}
+	if state != nil {
+		return state.String()
+	}
+	return "unknown exit"
+}
+
+// outOfMemory reports whether the child died of an allocation the kernel
+// refused, which under the address space cap is the cap being hit. Signals
+// say nothing about memory, whoever sent them.
+func outOfMemory(output string) bool {
+	return strings.Contains(output, "fatal error: out of memory") ||
+		strings.Contains(output, "runtime: out of memory") ||
+		strings.Contains(output, "cannot allocate memory")
+}
+
+// lastLines returns the last n lines of s
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// harnessStore is the child's side of the supervisor's store, which it
+// reads one key at a time over the pipes
+type harnessStore struct {
+	in   *bufio.Reader
+	send func(resp harnessResponse)
+}
+
+func (s *harnessStore) Get(key string) ([]byte, bool) {
+	s.send(harnessResponse{Get: key})
+	var answer harnessRequest
+	if err := readFrame(s.in, &answer); err != nil {
+		log.Fatalf("Failed to read the store: %v", err)
+	}
+	return answer.Value, answer.Found
+}
+
+// runHarness implements the child side, `statestinger harness`
+func runHarness(args []string) {
+	fs := flag.NewFlagSet("harness", flag.ExitOnError)
+	targetPath := fs.String("target", "", "Path to the Cosmos SDK module directory")
+	moduleName := fs.String("module", "", "Name of the module")
+	memoryLimit := fs.Int("memory-limit", 0, "Soft memory limit in MB (0 for none)")
+	sdkPanics := fs.Bool("sdk-panics", false, "Recover panics into ErrPanic errors as baseapp does")
+	perturbed := fs.Bool("perturb", false, "Perturb the scheduling of every execution")
+	noise := fs.Int("noise", 0, "Goroutines yielding alongside a perturbed execution")
+	fs.Parse(args)
+
+	if *memoryLimit > 0 {
+		debug.SetMemoryLimit(int64(*memoryLimit) << 20)
+	}
+
+	module, err := cosmossdk.LoadCosmosModule(*targetPath, *moduleName)
+	if err != nil {
+		log.Fatalf("Failed to load target module: %v", err)
+	}
+	module.SDKPanics = *sdkPanics
+
+	in := bufio.NewReader(os.NewFile(3, "requests"))
+	out := bufio.NewWriter(os.NewFile(4, "responses"))
+	send := func(resp harnessResponse) {
+		if err := writeFrame(out, resp); err != nil {
+			log.Fatalf("Failed to answer: %v", err)
+		}
+		if err := out.Flush(); err != nil {
+			log.Fatalf("Failed to answer: %v", err)
+		}
+	}
+
+	send(harnessResponse{Ready: true})
+	store := &harnessStore{in: in, send: send}
+	for {
+		var req harnessRequest
+		if err := readFrame(in, &req); err != nil {
+			// The supervisor closing the pipe is the normal way out
+			return
+		}
+
+		var result *cosmossdk.ExecResult
+		if *perturbed {
+			result = perturb(req.Input, *noise, func() *cosmossdk.ExecResult {
+				return module.ExecuteOn(req.Input, store)
+			})
+		} else {
+			result = module.ExecuteOn(req.Input, store)
+		}
+		resp := harnessResponse{
+			Output:  result.Output,
+			Handler: result.Handler,
+			Edges:   result.Edges,
+			Delta:   result.Delta,
+			Events:  result.Events,
+			GasUsed: result.GasUsed,
+			Panic:   result.Panic,
+		}
+		if result.Err != nil {
+			message := result.Err.Error()
+			resp.Error = &message
+			resp.ErrPanic = errors.Is(result.Err, cosmossdk.ErrPanic)
+		}
+		send(resp)
+	}
+}
@@ -0,0 +1,61 @@
+//go:build linux
+
+package engine
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// detach starts the child in a process group of its own, out of reach of
+// the signals the terminal sends the supervisor's
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// residentMemory returns the resident set size of a process in bytes
+func residentMemory(pid int) (uint64, error) {
+	_, resident, err := statm(pid)
+	return resident, err
+}
+
+// statm returns the address space and resident set sizes of a process in bytes
+func statm(pid int) (uint64, uint64, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/statm", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	var size, resident uint64
+	if _, err := fmt.Sscan(string(data), &size, &resident); err != nil {
+		return 0, 0, err
+	}
+	pageSize := uint64(os.Getpagesize())
+	return size * pageSize, resident * pageSize, nil
+}
+
+// rlimit64 is the kernel's struct rlimit64, the same on every architecture
+type rlimit64 struct {
+	Cur uint64
+	Max uint64
+}
+
+// capAddressSpace sets the RLIMIT_AS of a started child to the address space
+// it reserved while starting up plus limit bytes, so the kernel fails any
+// allocation past the limit. It runs after the child is ready because the Go
+// runtime reserves far more address space than it touches.
+func capAddressSpace(pid int, limit uint64) error {
+	size, _, err := statm(pid)
+	if err != nil {
+		return err
+	}
+	rlimit := rlimit64{Cur: size + limit, Max: size + limit}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), syscall.RLIMIT_AS,
+		uintptr(unsafe.Pointer(&rlimit)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package engine
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// detach leaves the child in the supervisor's process group on this platform
+func detach(cmd *exec.Cmd) {}
+
+// residentMemory is only available on linux, elsewhere the child's soft
+// memory limit is all there is
+func residentMemory(pid int) (uint64, error) {
+	return 0, errors.New("resident memory is not available on this platform")
+}
+
+// capAddressSpace is only available on linux, elsewhere the supervisor falls
+// back to polling the child's resident memory
+func capAddressSpace(pid int, limit uint64) error {
+	return errors.New("address space limits are not available on this platform")
+}
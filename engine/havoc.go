@@ -0,0 +1,350 @@
+package engine
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math/rand"
+	"sort"
+)
+
+// FeedbackMutator is implemented by mutators that want to know whether the
+// input they generated last was interesting, i.e. reached new coverage or
+// produced a finding. It is called from the worker that owns the mutator.
+type FeedbackMutator interface {
+	Feedback(interesting bool)
+}
+
+// OpStats is how often a mutation operation was applied and how often the
+// inputs it touched turned out to be interesting
+type OpStats struct {
+	Op      string
+	Applied int
+	Hits    int
+	HitRate float64
+}
+
+// havocOps names the havoc operations in the order they are drawn
+var havocOps = []string{
+	"bitflip",
+	"arith",
+	"interesting",
+	"block_insert",
+	"block_delete",
+	"block_duplicate",
+	"splice",
+	"dictionary",
+}
+
+const (
+	opBitflip = iota
+	opArith
+	opInteresting
+	opBlockInsert
+	opBlockDelete
+	opBlockDuplicate
+	opSplice
+	opDictionary
+)
+
+// havocMaxLength keeps stacked insertions from growing inputs without bound
+const havocMaxLength = 1 << 16
+
+// havocMaxBlock is the largest block inserted, deleted or duplicated at once
+const havocMaxBlock = 64
+
+// havocMaxDelta is the largest amount added to or subtracted from an integer
+const havocMaxDelta = 35
+
+// Interesting values per integer width, as in AFL. Wider integers also draw
+// from the narrower lists.
+var (
+	interesting8  = []int64{-128, -1, 0, 1, 16, 32, 64, 100, 127}
+	interesting16 = []int64{-32768, -129, 128, 255, 256, 512, 1000, 1024, 4096, 32767}
+	interesting32 = []int64{-2147483648, -100663046, -32769, 32768, 65535, 65536, 100663045, 2147483647}
+	interesting64 = []int64{-9223372036854775808, -4294967297, 4294967295, 4294967296, 9223372036854775807}
+)
+
+// HavocMutator stacks random mutations on top of corpus entries and
+// special cases, occasionally splicing two of them together
+type HavocMutator struct {
+	BaseMutator
+	corpus       *Corpus // nil unless coverage guidance is enabled
+	cases        [][]byte
+	dictionary   [][]byte
+	maxMutations int
+	lastOps      []bool // Operations applied to the last input
+	applied      []int
+	hits         []int
+}
+
+func NewHavocMutator(r *rand.Rand, corpus *Corpus, dictionary [][]byte) *HavocMutator {
+	return &HavocMutator{
+		BaseMutator: BaseMutator{
+			rand: r,
+			name: "HavocMutator",
+		},
+		corpus:       corpus,
+		cases:        specialCaseInputs(),
+		dictionary:   dictionary,
+		maxMutations: 16,
+		lastOps:      make([]bool, len(havocOps)),
+		applied:      make([]int, len(havocOps)),
+		hits:         make([]int, len(havocOps)),
+	}
+}
+
+// parent returns a copy of a corpus entry or, while the corpus is empty or
+// one in four times, of a special case
+func (m *HavocMutator) parent() []byte {
+	if m.corpus != nil && m.corpus.Size() > 0 && m.rand.Intn(4) != 0 {
+		if input := m.corpus.Pick(m.rand); len(input) > 0 {
+			return input
+		}
+	}
+
+	c := m.cases[m.rand.Intn(len(m.cases))]
+	input := make([]byte, len(c))
+	copy(input, c)
+	return input
+}
+
+func (m *HavocMutator) GenerateFuzzInput() []byte {
+	for i := range m.lastOps {
+		m.lastOps[i] = false
+	}
+
+	ops := opDictionary
+	if len(m.dictionary) > 0 {
+		ops++
+	}
+
+	input := m.parent()
+	mutations := 1 + m.rand.Intn(m.maxMutations)
+	for i := 0; i < mutations; i++ {
+		op := m.rand.Intn(ops)
+		input = m.apply(op, input)
+		m.lastOps[op] = true
+	}
+
+	if len(input) > havocMaxLength {
+		input = input[:havocMaxLength]
+	}
+	return input
+}
+
+// apply performs one havoc operation on input
+func (m *HavocMutator) apply(op int, input []byte) []byte {
+	switch op {
+	case opBitflip:
+		if len(input) > 0 {
+			input[m.rand.Intn(len(input))] ^= 1 << uint(m.rand.Intn(8))
+		}
+
+	case opArith:
+		width, order, pos, ok := m.integerSlot(input)
+		if !ok {
+			break
+		}
+		delta := uint64(1 + m.rand.Intn(havocMaxDelta))
+		value := readUint(input[pos:], width, order)
+		if m.rand.Intn(2) == 0 {
+			value += delta
+		} else {
+			value -= delta
+		}
+		writeUint(input[pos:], width, order, value)
+
+	case opInteresting:
+		width, order, pos, ok := m.integerSlot(input)
+		if !ok {
+			break
+		}
+		values := interesting8
+		if width >= 2 {
+			values = append(append([]int64{}, values...), interesting16...)
+		}
+		if width >= 4 {
+			values = append(values, interesting32...)
+		}
+		if width == 8 {
+			values = append(values, interesting64...)
+		}
+		writeUint(input[pos:], width, order, uint64(values[m.rand.Intn(len(values))]))
+
+	case opBlockInsert:
+		block := make([]byte, 1+m.rand.Intn(havocMaxBlock))
+		if m.rand.Intn(2) == 0 {
+			m.rand.Read(block)
+		} else {
+			// A run of one byte value, like AFL's memset block
+			value := byte(m.rand.Intn(256))
+			for i := range block {
+				block[i] = value
+			}
+		}
+		input = insertBlock(input, m.rand.Intn(len(input)+1), block)
+
+	case opBlockDelete:
+		// Always leave the handler selector in place
+		if len(input) > 1 {
+			size := 1 + m.rand.Intn(min(havocMaxBlock, len(input)-1))
+			pos := 1 + m.rand.Intn(len(input)-size)
+			input = append(input[:pos], input[pos+size:]...)
+		}
+
+	case opBlockDuplicate:
+		if len(input) > 0 {
+			size := 1 + m.rand.Intn(min(havocMaxBlock, len(input)))
+			from := m.rand.Intn(len(input) - size + 1)
+			block := append([]byte{}, input[from:from+size]...)
+			input = insertBlock(input, m.rand.Intn(len(input)+1), block)
+		}
+
+	case opSplice:
+		// Head of this input joined to the tail of another parent
+		other := m.parent()
+		if len(input) > 1 && len(other) > 1 {
+			head := 1 + m.rand.Intn(len(input)-1)
+			tail := 1 + m.rand.Intn(len(other)-1)
+			input = append(input[:head], other[tail:]...)
+		}
+
+	case opDictionary:
+		input = spliceToken(m.rand, input, m.dictionary[m.rand.Intn(len(m.dictionary))])
+	}
+
+	return input
+}
+
+// integerSlot picks a width, byte order and offset for an integer operation,
+// or reports false if the input is too short for any width
+func (m *HavocMutator) integerSlot(input []byte) (int, binary.ByteOrder, int, bool) {
+	widths := []int{1, 2, 4, 8}
+	width := widths[m.rand.Intn(len(widths))]
+	for width > len(input) {
+		width /= 2
+	}
+	if width == 0 {
+		return 0, nil, 0, false
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if m.rand.Intn(2) == 0 {
+		order = binary.BigEndian
+	}
+	return width, order, m.rand.Intn(len(input) - width + 1), true
+}
+
+func readUint(b []byte, width int, order binary.ByteOrder) uint64 {
+	switch width {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(order.Uint16(b))
+	case 4:
+		return uint64(order.Uint32(b))
+	default:
+		return order.Uint64(b)
+	}
+}
+
+func writeUint(b []byte, width int, order binary.ByteOrder, value uint64) {
+	switch width {
+	case 1:
+		b[0] = byte(value)
+	case 2:
+		order.PutUint16(b, uint16(value))
+	case 4:
+		order.PutUint32(b, uint32(value))
+	default:
+		order.PutUint64(b, value)
+	}
+}
+
+func insertBlock(input []byte, pos int, block []byte) []byte {
+	result := make([]byte, 0, len(input)+len(block))
+	result = append(result, input[:pos]...)
+	result = append(result, block...)
+	return append(result, input[pos:]...)
+}
+
+// Feedback credits the operations applied to the last input
+func (m *HavocMutator) Feedback(interesting bool) {
+	for op, used := range m.lastOps {
+		if !used {
+			continue
+		}
+		m.applied[op]++
+		if interesting {
+			m.hits[op]++
+		}
+	}
+}
+
+// OpStats reports the per-operation counters of this mutator
+func (m *HavocMutator) OpStats() []OpStats {
+	stats := make([]OpStats, 0, len(havocOps))
+	for op, name := range havocOps {
+		stats = append(stats, OpStats{Op: name, Applied: m.applied[op], Hits: m.hits[op]})
+	}
+	return stats
+}
+
+// havocState is the part of a HavocMutator kept across checkpoints
+type havocState struct {
+	Applied []int
+	Hits    []int
+}
+
+func (m *HavocMutator) MarshalState() ([]byte, error) {
+	return json.Marshal(havocState{Applied: m.applied, Hits: m.hits})
+}
+
+func (m *HavocMutator) UnmarshalState(data []byte) error {
+	var state havocState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	copy(m.applied, state.Applied)
+	copy(m.hits, state.Hits)
+	return nil
+}
+
+func (m *HavocMutator) ValidateOutput(output []byte, err error) *FuzzResult {
+	return nil
+}
+
+// opStatsReporter is implemented by mutators that count their operations
+type opStatsReporter interface {
+	OpStats() []OpStats
+}
+
+// mergeOpStats adds up the operation counters of all workers' mutators
+func mergeOpStats(workers []*fuzzWorker) []OpStats {
+	totals := make(map[string]*OpStats)
+	for _, w := range workers {
+		for _, mutator := range w.mutators {
+			reporter, ok := mutator.(opStatsReporter)
+			if !ok {
+				continue
+			}
+			for _, s := range reporter.OpStats() {
+				if totals[s.Op] == nil {
+					totals[s.Op] = &OpStats{Op: s.Op}
+				}
+				totals[s.Op].Applied += s.Applied
+				totals[s.Op].Hits += s.Hits
+			}
+		}
+	}
+
+	stats := make([]OpStats, 0, len(totals))
+	for _, s := range totals {
+		if s.Applied > 0 {
+			s.HitRate = float64(s.Hits) / float64(s.Applied)
+		}
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Op < stats[j].Op })
+	return stats
+}
@@ -0,0 +1,518 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Invariant oracles read the store the workers build from execution deltas.
+They expect the target to report writes under the key layout below, which
+follows the SDK's own stores and is what the simulated bank, staking and
+distribution modules write. Amounts are decimal strings, validators are
+JSON objects.
+
+	bank/balances/<address>/<denom>             math.Int
+	bank/supply/<denom>                         math.Int
+	staking/params/bond_denom                   denom
+	staking/validators/<valoper>                {"tokens": "<math.Int>", "status": "bonded|unbonding|unbonded"}
+	staking/pool/bonded                         math.Int
+	staking/pool/not_bonded                     math.Int
+	distribution/outstanding/<valoper>          math.LegacyDec
+	distribution/rewards/<delegator>/<valoper>  math.LegacyDec
+
+Each oracle keeps running totals of its store, such as the sum of the
+balances of every denom, which it moves along with the keys an execution
+writes. A check only looks at the keys written since the last one and the
+totals they take part in, so it costs as much as the writes, not the store.
+*/
+
+// defaultModuleAccounts are the module accounts of a standard SDK chain,
+// whose balances back the bookkeeping of their modules
+var defaultModuleAccounts = []string{
+	"fee_collector", "distribution", "bonded_tokens_pool", "not_bonded_tokens_pool", "gov", "mint",
+}
+
+// invariants is one group of invariants with the running totals it needs
+type invariants interface {
+	// update moves the totals from the value a key had to the one it has
+	// now, nil if the key did not or does not exist
+	update(key string, before, after []byte)
+	// check verifies the invariants that the keys written since the last
+	// check, sorted, take part in
+	check(view *StateView, written []string) (invariant string, message string)
+}
+
+// invariantOracle checks one group of invariants whenever an execution, or
+// a block of them, wrote to the store the invariants cover. Violations are
+// state inconsistencies naming the broken invariant.
+type invariantOracle struct {
+	name       string
+	store      string // Key prefix of the store the invariants read
+	every      int    // Executions per check, 1 to check after each one
+	executions int
+	written    map[string]bool // Keys written since the last check
+	build      func() invariants
+	totals     invariants // nil until built from the store
+}
+
+func (o *invariantOracle) Name() string {
+	return o.name
+}
+
+func (o *invariantOracle) Check(exec Execution) *FuzzResult {
+	if exec.State == nil {
+		return nil
+	}
+	o.Track(exec)
+	if len(o.written) == 0 || o.executions%o.every != 0 {
+		return nil
+	}
+	written := slices.Sorted(maps.Keys(o.written))
+	clear(o.written)
+
+	invariant, message := o.totals.check(exec.State, written)
+	if invariant == "" {
+		return nil
+	}
+	return &FuzzResult{
+		ID:                 fmt.Sprintf("invariant_%d", time.Now().UnixNano()),
+		Failed:             true,
+		StateInconsistency: true,
+		Invariant:          invariant,
+		ErrorMessage:       fmt.Sprintf("Invariant %s broken: %s", invariant, message),
+	}
+}
+
+// Track moves the totals along with the writes of an execution. The first
+// execution an oracle sees builds them from the store as that execution
+// found it, which is how a resumed or rebuilt oracle catches up.
+func (o *invariantOracle) Track(exec Execution) {
+	view := exec.State
+	if view == nil {
+		return
+	}
+	o.executions++
+
+	var keys []string
+	seen := make(map[string]bool)
+	for _, change := range view.Delta {
+		if strings.HasPrefix(change.Key, o.store) && !seen[change.Key] {
+			seen[change.Key] = true
+			keys = append(keys, change.Key)
+		}
+	}
+
+	if o.totals == nil {
+		o.totals = o.build()
+		for _, key := range view.Keys(o.store) {
+			if before, ok := view.Before(key); ok {
+				o.totals.update(key, nil, before)
+			}
+		}
+		// Keys the execution deleted are gone from the store already
+		for _, key := range keys {
+			if _, ok := view.After(key); ok {
+				continue
+			}
+			if before, ok := view.Before(key); ok {
+				o.totals.update(key, nil, before)
+			}
+		}
+	}
+
+	for _, key := range keys {
+		before, _ := view.Before(key)
+		after, _ := view.After(key)
+		o.totals.update(key, before, after)
+		o.written[key] = true
+	}
+}
+
+// invariantState is the checkpointed form of an invariantOracle. The totals
+// are rebuilt from the restored store.
+type invariantState struct {
+	Executions int
+	Written    []string
+}
+
+func (o *invariantOracle) MarshalState() ([]byte, error) {
+	return json.Marshal(invariantState{Executions: o.executions, Written: slices.Sorted(maps.Keys(o.written))})
+}
+
+func (o *invariantOracle) UnmarshalState(data []byte) error {
+	var state invariantState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	o.executions = state.Executions
+	o.written = make(map[string]bool, len(state.Written))
+	for _, key := range state.Written {
+		o.written[key] = true
+	}
+	o.totals = nil
+	return nil
+}
+
+// parseAmount parses a math.Int store value
+func parseAmount(value []byte) (*big.Int, bool) {
+	return new(big.Int).SetString(string(value), 10)
+}
+
+// parseDecAmount parses a math.LegacyDec store value, scaled by 10^18
+func parseDecAmount(value []byte) (*big.Int, bool) {
+	s := string(value)
+	if !decPattern.MatchString(s) {
+		return nil, false
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	return new(big.Int).SetString(whole+frac+strings.Repeat("0", 18-len(frac)), 10)
+}
+
+// addTo adds amount to the total of name, creating it if needed
+func addTo(totals map[string]*big.Int, name string, amount *big.Int) {
+	if totals[name] == nil {
+		totals[name] = new(big.Int)
+	}
+	totals[name].Add(totals[name], amount)
+}
+
+// bankInvariants verifies that no balance is negative and that the supply
+// of every denom equals the sum of its balances
+type bankInvariants struct {
+	sums map[string]*big.Int // Sum of the well-formed balances of each denom
+}
+
+func (b *bankInvariants) update(key string, before, after []byte) {
+	parts := strings.SplitN(key, "/", 4)
+	if len(parts) < 4 || parts[1] != "balances" {
+		return
+	}
+	if amount, ok := parseAmount(before); ok {
+		addTo(b.sums, parts[3], amount.Neg(amount))
+	}
+	if amount, ok := parseAmount(after); ok {
+		addTo(b.sums, parts[3], amount)
+	}
+}
+
+func (b *bankInvariants) check(view *StateView, written []string) (string, string) {
+	var denoms []string
+	for _, key := range written {
+		parts := strings.SplitN(key, "/", 4)
+		value, exists := view.After(key)
+		switch {
+		case len(parts) == 4 && parts[1] == "balances":
+			denoms = append(denoms, parts[3])
+			if !exists {
+				continue
+			}
+			amount, ok := parseAmount(value)
+			if !ok {
+				return "bank/balance-format", fmt.Sprintf("balance %s is %q", key, value)
+			}
+			if amount.Sign() < 0 {
+				return "bank/nonnegative-balance", fmt.Sprintf("%s holds %s%s", parts[2], amount, parts[3])
+			}
+		case len(parts) == 3 && parts[1] == "supply":
+			denoms = append(denoms, parts[2])
+			if !exists {
+				continue
+			}
+			if _, ok := parseAmount(value); !ok {
+				return "bank/supply-format", fmt.Sprintf("supply of %s is %q", parts[2], value)
+			}
+		}
+	}
+
+	// Every denom with balances needs a supply and vice versa
+	slices.Sort(denoms)
+	for _, denom := range slices.Compact(denoms) {
+		sum := b.sums[denom]
+		if sum == nil {
+			sum = new(big.Int)
+		}
+		value, ok := view.After("bank/supply/" + denom)
+		if !ok {
+			if sum.Sign() != 0 {
+				return "bank/total-supply", fmt.Sprintf("%s has balances summing to %s but no supply", denom, sum)
+			}
+			continue
+		}
+		if supply, ok := parseAmount(value); ok && supply.Cmp(sum) != 0 {
+			return "bank/total-supply", fmt.Sprintf("supply of %s is %s but balances sum to %s", denom, supply, sum)
+		}
+	}
+	return "", ""
+}
+
+// stakingValidator is a validator as the staking invariants read it
+type stakingValidator struct {
+	Tokens string `json:"tokens"`
+	Status string `json:"status"`
+}
+
+// parseValidator reads the tokens and status of a stored validator
+func parseValidator(value []byte) (*big.Int, string, error) {
+	var validator stakingValidator
+	if err := json.Unmarshal(value, &validator); err != nil {
+		return nil, "", err
+	}
+	tokens, ok := parseAmount([]byte(validator.Tokens))
+	if !ok || tokens.Sign() < 0 {
+		return nil, "", fmt.Errorf("tokens %q", validator.Tokens)
+	}
+	return tokens, validator.Status, nil
+}
+
+// stakingInvariants verifies that the bonded pool holds the tokens of
+// bonded validators and the not-bonded pool those of all others, both as
+// recorded by staking and as balances of the pool module accounts
+type stakingInvariants struct {
+	bonded, notBonded *big.Int // Tokens of the well-formed validators
+}
+
+func (s *stakingInvariants) pool(status string) *big.Int {
+	if status == "bonded" {
+		return s.bonded
+	}
+	return s.notBonded
+}
+
+func (s *stakingInvariants) update(key string, before, after []byte) {
+	if !strings.HasPrefix(key, "staking/validators/") {
+		return
+	}
+	if tokens, status, err := parseValidator(before); err == nil {
+		s.pool(status).Sub(s.pool(status), tokens)
+	}
+	if tokens, status, err := parseValidator(after); err == nil {
+		s.pool(status).Add(s.pool(status), tokens)
+	}
+}
+
+func (s *stakingInvariants) check(view *StateView, written []string) (string, string) {
+	for _, key := range written {
+		if !strings.HasPrefix(key, "staking/validators/") {
+			continue
+		}
+		value, ok := view.After(key)
+		if !ok {
+			continue
+		}
+		var validator stakingValidator
+		if err := json.Unmarshal(value, &validator); err != nil {
+			return "staking/validator-format", fmt.Sprintf("validator %s: %v", key, err)
+		}
+		if _, _, err := parseValidator(value); err != nil {
+			return "staking/validator-tokens", fmt.Sprintf("validator %s has tokens %q", key, validator.Tokens)
+		}
+	}
+
+	pools := []struct {
+		name    string
+		account string
+		want    *big.Int
+	}{
+		{"bonded", "bonded_tokens_pool", s.bonded},
+		{"not_bonded", "not_bonded_tokens_pool", s.notBonded},
+	}
+	bondDenom, hasDenom := view.After("staking/params/bond_denom")
+	for _, pool := range pools {
+		value, ok := view.After("staking/pool/" + pool.name)
+		if !ok {
+			value = []byte("0")
+		}
+		amount, ok := parseAmount(value)
+		if !ok {
+			return "staking/pool-format", fmt.Sprintf("%s pool is %q", pool.name, value)
+		}
+		if amount.Cmp(pool.want) != 0 {
+			return "staking/" + pool.name + "-pool", fmt.Sprintf("%s pool holds %s but validators have %s tokens", pool.name, amount, pool.want)
+		}
+
+		// Only comparable to the bank balance when the bond denom is known
+		if !hasDenom {
+			continue
+		}
+		value, ok = view.After("bank/balances/" + pool.account + "/" + string(bondDenom))
+		if !ok {
+			value = []byte("0")
+		}
+		balance, ok := parseAmount(value)
+		if ok && balance.Cmp(pool.want) != 0 {
+			return "staking/" + pool.name + "-pool-balance", fmt.Sprintf("%s holds %s%s but validators have %s tokens",
+				pool.account, balance, bondDenom, pool.want)
+		}
+	}
+	return "", ""
+}
+
+// distributionInvariants verifies that no outstanding rewards are negative
+// and that every validator's cover what its delegators can claim
+type distributionInvariants struct {
+	claimable map[string]*big.Int // Well-formed rewards claimable from each validator
+}
+
+func (d *distributionInvariants) update(key string, before, after []byte) {
+	parts := strings.SplitN(key, "/", 4)
+	if len(parts) < 4 || parts[1] != "rewards" {
+		return
+	}
+	if amount, ok := parseDecAmount(before); ok {
+		addTo(d.claimable, parts[3], amount.Neg(amount))
+	}
+	if amount, ok := parseDecAmount(after); ok {
+		addTo(d.claimable, parts[3], amount)
+	}
+}
+
+func (d *distributionInvariants) check(view *StateView, written []string) (string, string) {
+	var validators []string
+	for _, key := range written {
+		parts := strings.SplitN(key, "/", 4)
+		value, exists := view.After(key)
+		switch {
+		case len(parts) == 4 && parts[1] == "rewards":
+			validators = append(validators, parts[3])
+			if !exists {
+				continue
+			}
+			amount, ok := parseDecAmount(value)
+			if !ok {
+				return "distribution/rewards-format", fmt.Sprintf("rewards %s are %q", key, value)
+			}
+			if amount.Sign() < 0 {
+				return "distribution/nonnegative-rewards", fmt.Sprintf("%s has %s rewards from %s", parts[2], value, parts[3])
+			}
+		case len(parts) == 3 && parts[1] == "outstanding":
+			validators = append(validators, parts[2])
+			if !exists {
+				continue
+			}
+			outstanding, ok := parseDecAmount(value)
+			if !ok {
+				return "distribution/outstanding-format", fmt.Sprintf("outstanding rewards of %s are %q", parts[2], value)
+			}
+			if outstanding.Sign() < 0 {
+				return "distribution/nonnegative-outstanding", fmt.Sprintf("%s has %s outstanding", parts[2], value)
+			}
+		}
+	}
+
+	slices.Sort(validators)
+	for _, validator := range slices.Compact(validators) {
+		claim := d.claimable[validator]
+		if claim == nil || claim.Sign() <= 0 {
+			continue
+		}
+		value, ok := view.After("distribution/outstanding/" + validator)
+		if !ok {
+			return "distribution/outstanding-rewards", fmt.Sprintf("%s has no outstanding rewards but %s claimable",
+				validator, formatDec(claim))
+		}
+		if outstanding, ok := parseDecAmount(value); ok && outstanding.Cmp(claim) < 0 {
+			return "distribution/outstanding-rewards", fmt.Sprintf("%s has %s outstanding but %s claimable",
+				validator, value, formatDec(claim))
+		}
+	}
+	return "", ""
+}
+
+// moduleAccountInvariants flags executions emptying a balance of a module
+// account, which needs no totals
+type moduleAccountInvariants struct {
+	accounts []string
+}
+
+func (m moduleAccountInvariants) update(key string, before, after []byte) {}
+
+func (m moduleAccountInvariants) check(view *StateView, written []string) (string, string) {
+	for _, key := range written {
+		parts := strings.SplitN(key, "/", 4)
+		if len(parts) < 4 || parts[0] != "bank" || parts[1] != "balances" || !slices.Contains(m.accounts, parts[2]) {
+			continue
+		}
+
+		before, _ := view.Before(key)
+		after, _ := view.After(key)
+		was, ok := parseAmount(before)
+		if !ok || was.Sign() <= 0 {
+			continue
+		}
+		if now, ok := parseAmount(after); !ok || now.Sign() <= 0 {
+			return "bank/module-account-drained", fmt.Sprintf("%s went from %s%s to %q", parts[2], was, parts[3], after)
+		}
+	}
+	return "", ""
+}
+
+// newInvariantOracle reads the parameters shared by the invariant oracles
+func newInvariantOracle(name, store string, params map[string]string, build func() invariants) (Oracle, error) {
+	o := &invariantOracle{name: name, store: store, every: 1, written: make(map[string]bool), build: build}
+	if value, ok := params["every"]; ok {
+		every, err := strconv.Atoi(value)
+		if err != nil || every < 1 {
+			return nil, fmt.Errorf("oracle %s needs every >= 1, got %q", name, value)
+		}
+		o.every = every
+	}
+	return o, nil
+}
+
+func init() {
+	everyParam := "Executions per check, e.g. the size of a block (default 1)"
+
+	RegisterOracle(OracleInfo{
+		Name:        "bank",
+		Description: "Total supply equals the sum of balances, no balance is negative",
+		Params:      map[string]string{"every": everyParam},
+	}, func(env OracleEnv) (Oracle, error) {
+		return newInvariantOracle("bank", "bank/", env.Params, func() invariants {
+			return &bankInvariants{sums: make(map[string]*big.Int)}
+		})
+	})
+
+	RegisterOracle(OracleInfo{
+		Name:        "staking",
+		Description: "Bonded and not-bonded pools match the tokens of their validators",
+		Params:      map[string]string{"every": everyParam},
+	}, func(env OracleEnv) (Oracle, error) {
+		return newInvariantOracle("staking", "staking/", env.Params, func() invariants {
+			return &stakingInvariants{bonded: new(big.Int), notBonded: new(big.Int)}
+		})
+	})
+
+	RegisterOracle(OracleInfo{
+		Name:        "distribution",
+		Description: "Outstanding rewards are not negative and cover the rewards delegators can claim",
+		Params:      map[string]string{"every": everyParam},
+	}, func(env OracleEnv) (Oracle, error) {
+		return newInvariantOracle("distribution", "distribution/", env.Params, func() invariants {
+			return &distributionInvariants{claimable: make(map[string]*big.Int)}
+		})
+	})
+
+	RegisterOracle(OracleInfo{
+		Name:        "module-accounts",
+		Description: "No execution empties a balance of a module account",
+		Params: map[string]string{
+			"accounts": "Module account addresses, separated by '|' (default the standard SDK module accounts)",
+		},
+	}, func(env OracleEnv) (Oracle, error) {
+		accounts := defaultModuleAccounts
+		if value, ok := env.Params["accounts"]; ok {
+			accounts = strings.Split(value, "|")
+		}
+		// Draining is a property of a single execution, so check every one
+		return newInvariantOracle("module-accounts", "bank/balances/", nil, func() invariants {
+			return moduleAccountInvariants{accounts: accounts}
+		})
+	})
+}
@@ -0,0 +1,534 @@
+package engine
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// blockFunctions are the ABCI entry points that run on every block, where
+// an unbounded loop stalls the chain
+var blockFunctions = []string{"PreBlock", "PreBlocker", "BeginBlock", "BeginBlocker", "EndBlock", "EndBlocker"}
+
+// writeVerbs start the names of calls that write state or emit events
+var writeVerbs = []string{"set", "delete", "remove", "insert", "emit", "send", "mint", "burn", "transfer", "store", "save", "write", "update"}
+
+// Linter looks for consensus hazards in the source of a module: code that
+// can make validators compute different results from the same block. Its
+// findings are FuzzResults like those of a campaign.
+type Linter struct {
+	module   string
+	root     string
+	fset     *token.FileSet
+	importer types.Importer
+	funcs    map[string][]lintFunc // Declared functions by name, across packages
+	findings []lintFinding
+	files    int
+}
+
+// lintFinding is a finding along with its position, to sort findings by
+type lintFinding struct {
+	pos    token.Pos
+	result FuzzResult
+}
+
+// lintFunc is a function declaration along with the package it belongs to
+type lintFunc struct {
+	decl   *ast.FuncDecl
+	info   *types.Info
+	report bool // Declared in a file the linter reports on
+}
+
+// NewLinter creates a linter for the named module
+func NewLinter(module string) *Linter {
+	fset := token.NewFileSet()
+	return &Linter{
+		module:   module,
+		fset:     fset,
+		importer: lintImporter{std: importer.ForCompiler(fset, "source", nil), fake: make(map[string]*types.Package)},
+		funcs:    make(map[string][]lintFunc),
+	}
+}
+
+// lintImporter type checks against the standard library and stands in an
+// empty package for everything else, as the module's dependencies are
+// usually not available. Uses of those packages then fail to resolve, which
+// the linter tolerates.
+type lintImporter struct {
+	std  types.Importer
+	fake map[string]*types.Package
+}
+
+func (i lintImporter) Import(importPath string) (*types.Package, error) {
+	if first, _, _ := strings.Cut(importPath, "/"); !strings.Contains(first, ".") {
+		if pkg, err := i.std.Import(importPath); err == nil {
+			return pkg, nil
+		}
+	}
+	if pkg, ok := i.fake[importPath]; ok {
+		return pkg, nil
+	}
+
+	name := path.Base(importPath)
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = path.Base(path.Dir(importPath))
+	}
+	pkg := types.NewPackage(importPath, strings.ReplaceAll(name, "-", "_"))
+	pkg.MarkComplete()
+	i.fake[importPath] = pkg
+	return pkg, nil
+}
+
+// Lint analyzes the keeper/ package and the abci.go and module.go files of
+// the module at root, also looking in a module/ subdirectory for the latter.
+// Findings are returned in source order.
+func (l *Linter) Lint(root string) ([]FuzzResult, error) {
+	l.root = root
+	if _, err := os.Stat(root); err != nil {
+		return nil, err
+	}
+
+	if err := l.loadPackage(filepath.Join(root, "keeper"), func(string) bool { return true }); err != nil {
+		return nil, err
+	}
+	entryFiles := func(name string) bool { return name == "abci.go" || name == "module.go" }
+	for _, dir := range []string{root, filepath.Join(root, "module")} {
+		if err := l.loadPackage(dir, entryFiles); err != nil {
+			return nil, err
+		}
+	}
+	if l.files == 0 {
+		return nil, fmt.Errorf("no keeper/, abci.go or module.go sources in %s", root)
+	}
+
+	names := make([]string, 0, len(l.funcs))
+	for name := range l.funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, fn := range l.funcs[name] {
+			if fn.report && fn.decl.Body != nil {
+				l.checkBody(fn.info, funcName(fn.decl), fn.decl.Body, hasRecover(fn.decl.Body))
+			}
+		}
+	}
+	l.checkBlockLoops()
+
+	sort.SliceStable(l.findings, func(i, j int) bool {
+		if l.findings[i].pos != l.findings[j].pos {
+			return l.findings[i].pos < l.findings[j].pos
+		}
+		return l.findings[i].result.Rule < l.findings[j].result.Rule
+	})
+	results := make([]FuzzResult, len(l.findings))
+	for i, finding := range l.findings {
+		results[i] = finding.result
+		results[i].Iteration = i
+	}
+	return results, nil
+}
+
+// Files returns the number of files the linter reported on
+func (l *Linter) Files() int {
+	return l.files
+}
+
+// loadPackage parses and type checks the package in dir, if there is one,
+// and indexes its functions. Only files for which report returns true are
+// checked, the others only contribute types.
+func (l *Linter) loadPackage(dir string, report func(name string) bool) error {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return err
+	}
+
+	byPackage := make(map[string][]*ast.File)
+	reported := make(map[*ast.File]bool)
+	for _, filename := range matches {
+		if strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(l.fset, filename, nil, 0)
+		if err != nil {
+			log.Printf("Warning: Skipping %s: %v", filename, err)
+			continue
+		}
+		byPackage[file.Name.Name] = append(byPackage[file.Name.Name], file)
+		if report(filepath.Base(filename)) {
+			reported[file] = true
+			l.files++
+		}
+	}
+
+	for name, files := range byPackage {
+		info := &types.Info{
+			Types: make(map[ast.Expr]types.TypeAndValue),
+			Defs:  make(map[*ast.Ident]types.Object),
+			Uses:  make(map[*ast.Ident]types.Object),
+		}
+		conf := types.Config{
+			Importer:    l.importer,
+			FakeImportC: true,
+			Error:       func(error) {}, // Unresolved dependencies are expected
+		}
+		conf.Check(name, l.fset, files, info)
+
+		for _, file := range files {
+			for _, decl := range file.Decls {
+				if fn, ok := decl.(*ast.FuncDecl); ok {
+					l.funcs[fn.Name.Name] = append(l.funcs[fn.Name.Name], lintFunc{decl: fn, info: info, report: reported[file]})
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// report records a hazard found at pos inside function
+func (l *Linter) report(pos token.Pos, function, rule, message string) {
+	position := l.fset.Position(pos)
+	filename := position.Filename
+	if rel, err := filepath.Rel(l.root, filename); err == nil {
+		filename = rel
+	}
+	l.findings = append(l.findings, lintFinding{pos: pos, result: FuzzResult{
+		Module:       l.module,
+		Rule:         rule,
+		Handler:      function,
+		Location:     fmt.Sprintf("%s:%d:%d", filename, position.Line, position.Column),
+		ErrorMessage: message,
+		Failed:       true,
+		Hazard:       true,
+	}})
+}
+
+// checkBody reports the hazards in one function body. recovered is whether
+// a deferred recover covers panics raised in it.
+func (l *Linter) checkBody(info *types.Info, function string, body *ast.BlockStmt, recovered bool) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// Closures run in the caller, so its recover covers them
+			l.checkBody(info, function, n.Body, recovered || hasRecover(n.Body))
+			return false
+
+		case *ast.GoStmt:
+			l.report(n.Pos(), function, "goroutine", "Goroutine started in handler")
+			if lit, ok := n.Call.Fun.(*ast.FuncLit); ok {
+				// A goroutine's panics escape every recover but its own
+				l.checkBody(info, function, lit.Body, hasRecover(lit.Body))
+				return false
+			}
+
+		case *ast.SelectStmt:
+			l.report(n.Pos(), function, "select", "Select statement in handler")
+
+		case *ast.RangeStmt:
+			if t := info.TypeOf(n.X); t != nil {
+				if _, ok := t.Underlying().(*types.Map); ok {
+					if call := findWrite(n.Body); call != "" {
+						l.report(n.Pos(), function, "map-iteration",
+							"Map iteration order feeds state writes through "+call)
+					}
+				}
+			}
+
+		case *ast.BinaryExpr:
+			switch n.Op {
+			case token.ADD, token.SUB, token.MUL, token.QUO:
+				if isFloatExpr(info, n) {
+					l.report(n.Pos(), function, "float", "Floating-point arithmetic")
+					return false
+				}
+			}
+
+		case *ast.AssignStmt:
+			switch n.Tok {
+			case token.ADD_ASSIGN, token.SUB_ASSIGN, token.MUL_ASSIGN, token.QUO_ASSIGN:
+				if len(n.Lhs) == 1 && isFloatExpr(info, n.Lhs[0]) {
+					l.report(n.Pos(), function, "float", "Floating-point arithmetic")
+				}
+			}
+
+		case *ast.CallExpr:
+			if ident, ok := n.Fun.(*ast.Ident); ok && ident.Name == "panic" && !recovered {
+				if _, builtin := info.Uses[ident].(*types.Builtin); builtin || info.Uses[ident] == nil {
+					l.report(n.Pos(), function, "panic", "Panic without a deferred recover")
+				}
+			}
+			if pkg, _ := qualifiedName(info, n.Fun); pkg == "math" && isFloatExpr(info, n) {
+				l.report(n.Pos(), function, "float", "Floating-point arithmetic")
+				return false
+			}
+
+		case *ast.SelectorExpr:
+			switch pkg, name := qualifiedName(info, n); pkg {
+			case "time":
+				if name == "Now" || name == "Since" || name == "Until" {
+					l.report(n.Pos(), function, "time", "Wall-clock time from time."+name)
+				}
+			case "math/rand", "math/rand/v2":
+				l.report(n.Pos(), function, "rand", "Use of "+pkg)
+			}
+		}
+		return true
+	})
+}
+
+// checkBlockLoops reports unbounded loops in the block entry points and in
+// the functions they call, which run on every block however large the
+// store has grown
+func (l *Linter) checkBlockLoops() {
+	reached := make(map[*ast.FuncDecl]string)
+	var queue []*ast.FuncDecl
+	for _, entry := range blockFunctions {
+		for _, fn := range l.funcs[entry] {
+			if fn.decl.Body != nil && reached[fn.decl] == "" {
+				reached[fn.decl] = entry
+				queue = append(queue, fn.decl)
+			}
+		}
+	}
+
+	// Calls are matched by name, which over-approximates method calls
+	for len(queue) > 0 {
+		decl := queue[0]
+		queue = queue[1:]
+		ast.Inspect(decl.Body, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				for _, fn := range l.funcs[calleeName(call)] {
+					if fn.decl.Body != nil && reached[fn.decl] == "" {
+						reached[fn.decl] = reached[decl]
+						queue = append(queue, fn.decl)
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	for _, fns := range l.funcs {
+		for _, fn := range fns {
+			if entry := reached[fn.decl]; entry != "" && fn.report {
+				l.checkLoops(fn.info, funcName(fn.decl), entry, fn.decl.Body)
+			}
+		}
+	}
+}
+
+// checkLoops reports the loops in body that no bound limits
+func (l *Linter) checkLoops(info *types.Info, function, entry string, body *ast.BlockStmt) {
+	message := "Unbounded loop reachable from " + entry + ": "
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ForStmt:
+			if n.Cond == nil {
+				l.report(n.Pos(), function, "block-loop", message+"loop without a condition")
+			} else if findCall(n.Cond, "Valid") {
+				l.report(n.Pos(), function, "block-loop", message+"store iterator")
+			}
+
+		case *ast.RangeStmt:
+			if t := info.TypeOf(n.X); t != nil {
+				switch t.Underlying().(type) {
+				case *types.Chan:
+					l.report(n.Pos(), function, "block-loop", message+"range over a channel")
+				case *types.Signature:
+					l.report(n.Pos(), function, "block-loop", message+"range over an iterator")
+				}
+			}
+
+		case *ast.CallExpr:
+			name := calleeName(n)
+			if strings.HasPrefix(name, "Iterate") || strings.HasPrefix(name, "Walk") {
+				l.report(n.Pos(), function, "block-loop", message+"store iteration")
+			}
+		}
+		return true
+	})
+}
+
+// funcName names a declared function, prefixed with its receiver type
+func funcName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	t := fn.Recv.List[0].Type
+	for {
+		switch expr := t.(type) {
+		case *ast.StarExpr:
+			t = expr.X
+			continue
+		case *ast.IndexExpr:
+			t = expr.X
+			continue
+		case *ast.IndexListExpr:
+			t = expr.X
+			continue
+		case *ast.Ident:
+			return expr.Name + "." + fn.Name.Name
+		}
+		return fn.Name.Name
+	}
+}
+
+// calleeName returns the name of the function or method a call invokes
+func calleeName(call *ast.CallExpr) string {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		return fun.Name
+	case *ast.SelectorExpr:
+		return fun.Sel.Name
+	}
+	return ""
+}
+
+// qualifiedName resolves pkg.Name selectors to the import path of pkg
+func qualifiedName(info *types.Info, expr ast.Expr) (string, string) {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return "", ""
+	}
+	ident, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return "", ""
+	}
+	if pkg, ok := info.Uses[ident].(*types.PkgName); ok {
+		return pkg.Imported().Path(), sel.Sel.Name
+	}
+	return "", ""
+}
+
+// isFloatExpr reports whether expr computes a floating-point value at run
+// time. Constant expressions are folded by the compiler and are exempt.
+func isFloatExpr(info *types.Info, expr ast.Expr) bool {
+	tv, ok := info.Types[expr]
+	if !ok || tv.Value != nil || tv.Type == nil {
+		return false
+	}
+	basic, ok := tv.Type.Underlying().(*types.Basic)
+	return ok && basic.Info()&(types.IsFloat|types.IsComplex) != 0
+}
+
+// isWriteCall reports whether a call name starts with a verb that writes
+// state, such as SetBalance or k.Delete
+func isWriteCall(name string) bool {
+	word := name
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) {
+			word = name[:i]
+			break
+		}
+	}
+	word = strings.ToLower(word)
+	for _, verb := range writeVerbs {
+		if word == verb {
+			return true
+		}
+	}
+	return false
+}
+
+// findWrite returns the name of the first state-writing call in node
+func findWrite(node ast.Node) string {
+	found := ""
+	ast.Inspect(node, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && found == "" && isWriteCall(calleeName(call)) {
+			found = calleeName(call)
+		}
+		return found == ""
+	})
+	return found
+}
+
+// findCall reports whether node calls a function or method with the name
+func findCall(node ast.Node, name string) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && calleeName(call) == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// hasRecover reports whether body defers a call that recovers panics
+func hasRecover(body *ast.BlockStmt) bool {
+	for _, stmt := range body.List {
+		if deferred, ok := stmt.(*ast.DeferStmt); ok && findCall(deferred.Call, "recover") {
+			return true
+		}
+	}
+	return false
+}
+
+// runLint implements `statestinger lint [flags] <module>`
+func runLint(args []string) {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	outputDir := fs.String("output", "./lint_results", "Directory to store findings")
+	moduleName := fs.String("module", "", "Name of the module (default: the directory name)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: statestinger lint [flags] <module>\n")
+		fmt.Fprintf(fs.Output(), "Exits with status 1 if any hazard is found.\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	root := fs.Arg(0)
+	if *moduleName == "" {
+		*moduleName = filepath.Base(root)
+	}
+
+	linter := NewLinter(*moduleName)
+	findings, err := linter.Lint(root)
+	if err != nil {
+		log.Fatalf("Failed to lint module: %v", err)
+	}
+
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		log.Fatalf("Error creating output directory: %v", err)
+	}
+
+	// Findings share the campaign format, one file per bucket
+	dedup := NewDeduplicator()
+	for _, finding := range findings {
+		fmt.Printf("%s: %s: %s [%s]\n", finding.Location, finding.Handler, finding.ErrorMessage, finding.Rule)
+		bucket, changed := dedup.Add(finding)
+		if changed {
+			finding.ID = bucket.ID
+			filename := filepath.Join(*outputDir, fmt.Sprintf("failure_%s.json", finding.ID))
+			if err := saveResult(filename, finding); err != nil {
+				log.Printf("Warning: Failed to save finding: %v", err)
+			}
+		}
+	}
+	if err := dedup.Save(filepath.Join(*outputDir, "findings.json")); err != nil {
+		log.Printf("Warning: Failed to save findings index: %v", err)
+	}
+
+	fmt.Printf("\n=== StateStinger Lint Results ===\n")
+	fmt.Printf("Files analyzed: %d\n", linter.Files())
+	fmt.Printf("Hazards found: %d\n", len(findings))
+	fmt.Printf("Unique findings: %d\n", dedup.Len())
+	if len(findings) > 0 {
+		fmt.Printf("\nDetailed hazard reports saved to: %s\n", *outputDir)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,196 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeModule creates a module from a map of file paths to sources
+func writeModule(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, src := range files {
+		filename := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		require.NoError(t, os.WriteFile(filename, []byte(src), 0644))
+	}
+	return root
+}
+
+func TestLinter(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		rules []string // Rules of the findings in source order
+	}{
+		{
+			name: "clean handler",
+			files: map[string]string{"keeper/msg_server.go": `package keeper
+
+import "context"
+
+func (k msgServer) Send(ctx context.Context, msg *MsgSend) error {
+	for _, coin := range msg.Amount {
+		k.SetBalance(ctx, msg.To, coin)
+	}
+	return nil
+}
+`},
+		},
+		{
+			name: "wall clock and randomness",
+			files: map[string]string{"keeper/msg_server.go": `package keeper
+
+import (
+	"math/rand"
+	"time"
+)
+
+func (k msgServer) Send() int64 {
+	seed := time.Now().Unix()
+	return seed + rand.Int63()
+}
+`},
+			rules: []string{"time", "rand"},
+		},
+		{
+			name: "goroutine with a panic of its own",
+			files: map[string]string{"keeper/msg_server.go": `package keeper
+
+func (k msgServer) Send(done chan bool) {
+	defer func() { recover() }()
+	panic("recovered")
+	go func() {
+		panic("escapes")
+	}()
+	select {
+	case <-done:
+	}
+}
+`},
+			rules: []string{"goroutine", "panic", "select"},
+		},
+		{
+			name: "map iteration",
+			files: map[string]string{"keeper/keeper.go": `package keeper
+
+func (k Keeper) Payout(shares map[string]int64) {
+	for addr, amount := range shares {
+		k.SendCoins(addr, amount)
+	}
+	for addr := range shares {
+		_ = addr
+	}
+}
+`},
+			rules: []string{"map-iteration"},
+		},
+		{
+			name: "floating point",
+			files: map[string]string{"keeper/keeper.go": `package keeper
+
+import "math"
+
+func (k Keeper) Reward(stake int64) int64 {
+	rate := 0.05
+	reward := float64(stake) * rate
+	reward += 1
+	return int64(math.Floor(reward))
+}
+`},
+			rules: []string{"float", "float", "float"},
+		},
+		{
+			name: "unbounded loops reached from EndBlocker",
+			files: map[string]string{
+				"abci.go": `package module
+
+func EndBlocker(ctx Context, k Keeper) {
+	k.ProcessQueue(ctx)
+}
+`,
+				"keeper/queue.go": `package keeper
+
+func (k Keeper) ProcessQueue(ctx Context) {
+	iterator := k.QueueIterator(ctx)
+	for ; iterator.Valid(); iterator.Next() {
+	}
+	for {
+		break
+	}
+	k.IterateValidators(ctx, nil)
+}
+
+func (k Keeper) NotReached() {
+	for {
+		break
+	}
+}
+`,
+			},
+			rules: []string{"block-loop", "block-loop", "block-loop"},
+		},
+		{
+			name: "module.go in module/",
+			files: map[string]string{
+				"keeper/keeper.go": "package keeper\n",
+				"module/module.go": `package module
+
+import "time"
+
+func (am AppModule) BeginBlock() {
+	_ = time.Since(start)
+}
+`,
+			},
+			rules: []string{"time"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeModule(t, tt.files)
+			findings, err := NewLinter("x").Lint(root)
+			require.NoError(t, err)
+
+			rules := []string{}
+			for i, finding := range findings {
+				rules = append(rules, finding.Rule)
+				assert.True(t, finding.Hazard)
+				assert.True(t, finding.Failed)
+				assert.Equal(t, "x", finding.Module)
+				assert.Equal(t, i, finding.Iteration)
+				assert.NotEmpty(t, finding.Location)
+			}
+			if tt.rules == nil {
+				tt.rules = []string{}
+			}
+			assert.Equal(t, tt.rules, rules)
+		})
+	}
+}
+
+func TestLinterLocation(t *testing.T) {
+	root := writeModule(t, map[string]string{"keeper/msg_server.go": `package keeper
+
+import "time"
+
+func (k msgServer) Send() {
+	_ = time.Now()
+}
+`})
+	findings, err := NewLinter("x").Lint(root)
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	assert.Equal(t, filepath.Join("keeper", "msg_server.go")+":6:6", findings[0].Location)
+	assert.Equal(t, "msgServer.Send", findings[0].Handler)
+}
+
+func TestLinterWithoutSources(t *testing.T) {
+	root := writeModule(t, map[string]string{"types/types.go": "package types\n"})
+	_, err := NewLinter("x").Lint(root)
+	assert.Error(t, err)
+}
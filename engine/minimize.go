@@ -0,0 +1,310 @@
+package engine
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoSec-Labs/StateStinger/utils/target/cosmossdk"
+)
+
+// maxMinimizeExecs bounds the number of target executions spent on one input
+const maxMinimizeExecs = 20000
+
+// Minimizer shrinks failing inputs with delta debugging while the oracle
+// that reported the failure still reports the same finding
+type Minimizer struct {
+	target    *cosmossdk.CosmosModule
+	diff      *cosmossdk.CosmosModule // Second module of a differential run, nil otherwise
+	execs     int
+	limits    HarnessConfig // Timeout and memory limit of harness children the oracle starts
+	result    FuzzResult    // Finding being minimized
+	signature string        // Dedup signature candidates must keep
+	mutator   StateMutator  // Rebuilt mutator of the finding, nil if it cannot be
+	oracle    Oracle        // Rebuilt oracle of the finding
+	initial   []byte        // State of the oracle before the first candidate, nil if it keeps none
+}
+
+// NewMinimizer creates a minimizer that reproduces failures against target,
+// and against diff for divergences
+func NewMinimizer(target, diff *cosmossdk.CosmosModule, limits HarnessConfig) *Minimizer {
+	return &Minimizer{target: target, diff: diff, limits: limits}
+}
+
+// failureClass names the kind of failure a result represents
+func failureClass(result FuzzResult) string {
+	switch {
+	case result.ConsensusFailure:
+		return "consensus_failure"
+	case result.StateInconsistency:
+		return "state_inconsistency"
+	case result.Crashed:
+		return "crash"
+	case result.AcceptedInvalid:
+		return "accepted_invalid"
+	case result.Divergence:
+		return "divergence"
+	case result.Hazard:
+		return "hazard"
+	case result.Hang:
+		return "hang"
+	case result.OOM:
+		return "oom"
+	}
+	return "failure"
+}
+
+// Minimize returns a copy of result with the smallest input found that the
+// finding's oracle still reports as the same finding. Stateful results are
+// reduced over their steps first, then over the bytes of the final step.
+func (m *Minimizer) Minimize(result FuzzResult) FuzzResult {
+	minimized := result
+	minimized.Decoded = nil // Describes the original input, not the minimized one
+	m.execs = 0
+	m.result = result
+	m.signature, _ = signature(result)
+
+	// Hangs and OOMs would take the minimizer down with them in process
+	if result.Hang || result.OOM {
+		return minimized
+	}
+	if result.Divergence && m.diff == nil {
+		log.Printf("Warning: Not minimizing %s, divergences need the -diff-target module", result.ID)
+		return minimized
+	}
+	oracle, err := findingOracle(result, m.target, m.diff, m.limits)
+	if err != nil {
+		log.Printf("Warning: Not minimizing %s: %v", result.ID, err)
+		return minimized
+	}
+	if closer, ok := oracle.(io.Closer); ok {
+		defer closer.Close()
+	}
+	m.oracle = oracle
+	m.initial = nil
+	if checkpointable, ok := oracle.(CheckpointableMutator); ok {
+		if m.initial, err = checkpointable.MarshalState(); err != nil {
+			log.Printf("Warning: Not minimizing %s: %v", result.ID, err)
+			return minimized
+		}
+	}
+
+	mutator, err := findingMutator(result, m.target)
+	if err != nil && result.Oracle == "mutator" {
+		log.Printf("Warning: Not minimizing %s, mutator %s cannot be rebuilt: %v", result.ID, result.Mutator, err)
+		return minimized
+	}
+	if closer, ok := mutator.(io.Closer); ok {
+		defer closer.Close()
+	}
+	m.mutator = mutator
+
+	if len(result.Steps) > 0 {
+		steps := ddmin(result.Steps, m.reproducesSequence)
+
+		// Shrink the last step, which is the one that triggers the failure
+		prefix := steps[:len(steps)-1]
+		last := ddmin(steps[len(steps)-1], func(candidate []byte) bool {
+			return m.reproducesSequence(append(prefix[:len(prefix):len(prefix)], candidate))
+		})
+
+		minimized.Steps = append(prefix[:len(prefix):len(prefix)], last)
+		minimized.Input = last
+		return minimized
+	}
+
+	if len(result.Input) > 0 {
+		minimized.Input = ddmin(result.Input, m.reproduces)
+	}
+
+	return minimized
+}
+
+// reproduces reports whether a single input is reported as the same finding
+func (m *Minimizer) reproduces(input []byte) bool {
+	return m.reproducesSequence([][]byte{input})
+}
+
+// reproducesSequence reports whether the oracle reports the last step of
+// the sequence as the same finding after the steps before it were executed
+// in order against one store, starting from the store of the finding
+func (m *Minimizer) reproducesSequence(steps [][]byte) bool {
+	if m.execs+len(steps) > maxMinimizeExecs {
+		return false
+	}
+	m.execs += len(steps)
+
+	state, diffState := NewStateStore(), NewStateStore()
+	state.Restore(m.result.State)
+	diffState.Restore(m.result.DiffState)
+	var exec Execution
+	for _, step := range steps {
+		execution := m.target.ExecuteOn(step, state)
+		exec = Execution{
+			Input:   step,
+			Output:  execution.Output,
+			Err:     execution.Err,
+			Handler: execution.Handler,
+			Events:  execution.Events,
+			GasUsed: execution.GasUsed,
+			Panic:   execution.Panic,
+			State:   state.Apply(execution.Delta),
+			Mutator: m.mutator,
+		}
+		if m.diff != nil {
+			other := m.diff.ExecuteOn(step, diffState)
+			exec.Diff = &Execution{
+				Input:   step,
+				Output:  other.Output,
+				Err:     other.Err,
+				Handler: other.Handler,
+				Events:  other.Events,
+				GasUsed: other.GasUsed,
+				Panic:   other.Panic,
+				State:   diffState.Apply(other.Delta),
+				Mutator: m.mutator,
+			}
+		}
+	}
+
+	// Every candidate starts from the same oracle state, some count the
+	// executions they see
+	if m.initial != nil {
+		if err := m.oracle.(CheckpointableMutator).UnmarshalState(m.initial); err != nil {
+			return false
+		}
+	}
+	found := m.oracle.Check(exec)
+	if found == nil {
+		return false
+	}
+
+	// Record the finding as the worker would, then require the same bucket
+	// so the input does not shrink into an unrelated failure such as a
+	// short-input rejection
+	found.Mutator = m.result.Mutator
+	found.Handler = exec.Handler
+	if exec.Err != nil {
+		found.TargetError = exec.Err.Error()
+	}
+	attachPanic(found, exec.Panic)
+	sig, _ := signature(*found)
+	return sig == m.signature
+}
+
+// ddmin is Zeller's delta debugging algorithm. It returns a 1-minimal subset
+// of items for which test still holds; test(items) is assumed to hold.
+func ddmin[T any](items []T, test func([]T) bool) []T {
+	n := 2
+	for len(items) >= 2 {
+		chunk := (len(items) + n - 1) / n
+		reduced := false
+
+		// Try each chunk on its own
+		for start := 0; start < len(items); start += chunk {
+			end := min(start+chunk, len(items))
+			subset := append([]T(nil), items[start:end]...)
+			if test(subset) {
+				items = subset
+				n = 2
+				reduced = true
+				break
+			}
+		}
+
+		// Try removing each chunk
+		if !reduced {
+			for start := 0; start < len(items); start += chunk {
+				end := min(start+chunk, len(items))
+				complement := append(append([]T(nil), items[:start]...), items[end:]...)
+				if len(complement) > 0 && test(complement) {
+					items = complement
+					n = max(n-1, 2)
+					reduced = true
+					break
+				}
+			}
+		}
+
+		if !reduced {
+			if n >= len(items) {
+				break
+			}
+			n = min(n*2, len(items))
+		}
+	}
+
+	return items
+}
+
+// runMinimize implements `statestinger minimize [flags] failure.json...`
+func runMinimize(args []string) {
+	fs := flag.NewFlagSet("minimize", flag.ExitOnError)
+	targetPath := fs.String("target", "", "Path to the Cosmos SDK module directory the failure was found in")
+	moduleName := fs.String("module", "", "Name of the module to target")
+	diffPath := fs.String("diff-target", "", "Second module directory, needed to minimize divergences")
+	execTimeout := fs.Duration("exec-timeout", defaultExecTimeout, "Time limit per input in a harness the oracle starts to rerun inputs")
+	memoryLimit := fs.Int("memory-limit", defaultMemoryLimit, "Memory limit in MB of a harness the oracle starts to rerun inputs")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: statestinger minimize -target <module> failure.json...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *targetPath == "" || fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *moduleName == "" {
+		*moduleName = filepath.Base(*targetPath)
+	}
+
+	targetModule, err := cosmossdk.LoadCosmosModule(*targetPath, *moduleName)
+	if err != nil {
+		log.Fatalf("Failed to load target module: %v", err)
+	}
+
+	var diffModule *cosmossdk.CosmosModule
+	if *diffPath != "" {
+		diffModule, err = cosmossdk.LoadCosmosModule(*diffPath, *moduleName)
+		if err != nil {
+			log.Fatalf("Failed to load diff target module: %v", err)
+		}
+	}
+
+	minimizer := NewMinimizer(targetModule, diffModule, HarnessConfig{Timeout: *execTimeout, MemoryLimit: *memoryLimit})
+	for _, filename := range fs.Args() {
+		result, err := loadResult(filename)
+		if err != nil {
+			log.Fatalf("Failed to load failure: %v", err)
+		}
+		if result.Hazard {
+			log.Printf("Warning: Skipping %s, static hazards have no input to minimize", filename)
+			continue
+		}
+		if result.FuzzTest != "" {
+			log.Printf("Warning: Skipping %s, go test -fuzz already minimized it", filename)
+			continue
+		}
+
+		// Run the modules as the campaign did
+		targetModule.SDKPanics = result.SDKPanics
+		if diffModule != nil {
+			diffModule.SDKPanics = result.SDKPanics
+		}
+
+		minimized := minimizer.Minimize(result)
+		outName := strings.TrimSuffix(filename, ".json") + ".min.json"
+		if err := saveResult(outName, minimized); err != nil {
+			log.Fatalf("Failed to save minimized failure: %v", err)
+		}
+
+		fmt.Printf("%s: %d -> %d bytes, %d -> %d steps, saved to %s\n", filename,
+			len(result.Input), len(minimized.Input), len(result.Steps), len(minimized.Steps), outName)
+	}
+}
@@ -2,9 +2,10 @@ package engine
 
 import (
 	"encoding/binary"
-	"fmt"
+	"encoding/json"
 	"math/rand"
-	"time"
+
+	"github.com/GoSec-Labs/StateStinger/utils/target/cosmossdk"
 )
 
 // Base mutator that others can embed
@@ -20,6 +21,8 @@ func (b BaseMutator) Name() string {
 // RandomTxMutator generates completely random transaction data
 type RandomTxMutator struct {
 	BaseMutator
+	minLen int
+	maxLen int
 }
 
 func NewRandomTxMutator(r *rand.Rand) *RandomTxMutator {
@@ -28,12 +31,14 @@ func NewRandomTxMutator(r *rand.Rand) *RandomTxMutator {
 			rand: r,
 			name: "RandomTxMutator",
 		},
+		minLen: 16,
+		maxLen: 4096,
 	}
 }
 
 func (m *RandomTxMutator) GenerateFuzzInput() []byte {
-	// Generate random length between 16 and 4096 bytes
-	length := 16 + m.rand.Intn(4080)
+	// Generate random length between minLen and maxLen bytes
+	length := m.minLen + m.rand.Intn(m.maxLen-m.minLen)
 	input := make([]byte, length)
 
 	// Fill with random bytes
@@ -43,43 +48,13 @@ func (m *RandomTxMutator) GenerateFuzzInput() []byte {
 }
 
 func (m *RandomTxMutator) ValidateOutput(output []byte, err error) *FuzzResult {
-	if err != nil {
-		// Only report specific errors (not general validation failures)
-		if err.Error() != "invalid arguments" && err.Error() != "permission denied" {
-			return &FuzzResult{
-				ID:           fmt.Sprintf("random_tx_%d", time.Now().UnixNano()),
-				Failed:       true,
-				ErrorMessage: err.Error(),
-				Crashed:      true,
-			}
-		}
-	}
-
-	if output != nil && len(output) > 0 {
-		// Check for specific error indicators in output
-		if string(output) == "state_inconsistent" {
-			return &FuzzResult{
-				ID:                 fmt.Sprintf("random_tx_%d", time.Now().UnixNano()),
-				Failed:             true,
-				StateInconsistency: true,
-				ErrorMessage:       "State inconsistency detected",
-			}
-		} else if string(output) == "consensus_failure" {
-			return &FuzzResult{
-				ID:               fmt.Sprintf("random_tx_%d", time.Now().UnixNano()),
-				Failed:           true,
-				ConsensusFailure: true,
-				ErrorMessage:     "Consensus failure detected",
-			}
-		}
-	}
-
 	return nil
 }
 
 // BoundaryValueMutator focuses on edge cases and boundary values
 type BoundaryValueMutator struct {
 	BaseMutator
+	maxBufferMB int
 }
 
 func NewBoundaryValueMutator(r *rand.Rand) *BoundaryValueMutator {
@@ -88,6 +63,7 @@ func NewBoundaryValueMutator(r *rand.Rand) *BoundaryValueMutator {
 			rand: r,
 			name: "BoundaryValueMutator",
 		},
+		maxBufferMB: 5,
 	}
 }
 
@@ -120,7 +96,7 @@ func (m *BoundaryValueMutator) GenerateFuzzInput() []byte {
 		binary.LittleEndian.PutUint64(input[0:8], 0x8000000000000000) // -2^63
 	case 5:
 		// Very large buffer
-		length := 1024 * 1024 * (1 + m.rand.Intn(5)) // 1-5 MB
+		length := 1024 * 1024 * (1 + m.rand.Intn(m.maxBufferMB)) // 1-5 MB by default
 		input = make([]byte, length)
 		m.rand.Read(input)
 	}
@@ -129,36 +105,6 @@ func (m *BoundaryValueMutator) GenerateFuzzInput() []byte {
 }
 
 func (m *BoundaryValueMutator) ValidateOutput(output []byte, err error) *FuzzResult {
-	// Similar validation logic as RandomTxMutator
-	if err != nil {
-		if err.Error() != "invalid arguments" && err.Error() != "permission denied" {
-			return &FuzzResult{
-				ID:           fmt.Sprintf("boundary_%d", time.Now().UnixNano()),
-				Failed:       true,
-				ErrorMessage: err.Error(),
-				Crashed:      true,
-			}
-		}
-	}
-
-	if output != nil && len(output) > 0 {
-		if string(output) == "state_inconsistent" {
-			return &FuzzResult{
-				ID:                 fmt.Sprintf("boundary_%d", time.Now().UnixNano()),
-				Failed:             true,
-				StateInconsistency: true,
-				ErrorMessage:       "State inconsistency detected with boundary value",
-			}
-		} else if string(output) == "consensus_failure" {
-			return &FuzzResult{
-				ID:               fmt.Sprintf("boundary_%d", time.Now().UnixNano()),
-				Failed:           true,
-				ConsensusFailure: true,
-				ErrorMessage:     "Consensus failure detected with boundary value",
-			}
-		}
-	}
-
 	return nil
 }
 
@@ -166,6 +112,15 @@ func (m *BoundaryValueMutator) ValidateOutput(output []byte, err error) *FuzzRes
 type StatefulMutator struct {
 	BaseMutator
 	currentState []byte
+	history      [][]byte      // Inputs generated so far, the steps of the sequence
+	objects      []knownObject // Objects the target created, oldest first
+	depth        int           // Number of past operations carried forward
+}
+
+// knownObject is a store object a stateful sequence has created
+type knownObject struct {
+	Kind string
+	ID   string
 }
 
 func NewStatefulMutator(r *rand.Rand) *StatefulMutator {
@@ -175,6 +130,8 @@ func NewStatefulMutator(r *rand.Rand) *StatefulMutator {
 			name: "StatefulMutator",
 		},
 		currentState: make([]byte, 0),
+		history:      make([][]byte, 0),
+		depth:        64,
 	}
 }
 
@@ -194,6 +151,11 @@ func (m *StatefulMutator) GenerateFuzzInput() []byte {
 	m.rand.Read(header)
 	input = append(input, header...)
 
+	// Reference an object an earlier operation created
+	if len(m.objects) > 0 && m.rand.Intn(2) == 0 {
+		input = append(input, m.objects[m.rand.Intn(len(m.objects))].ID...)
+	}
+
 	// Add current state if we have any
 	if len(m.currentState) > 0 {
 		input = append(input, m.currentState...)
@@ -205,63 +167,105 @@ func (m *StatefulMutator) GenerateFuzzInput() []byte {
 	m.rand.Read(extra)
 	input = append(input, extra...)
 
-	// Update the current state based on this input
-	// In a real implementation, this would be more sophisticated
-	// based on the actual response from the module
-	m.currentState = append(m.currentState, input[0])
-	if len(m.currentState) > 64 {
-		// Prevent state from growing too large
-		m.currentState = m.currentState[len(m.currentState)-64:]
+	// Remember the full step so failures can be replayed as a sequence
+	m.history = append(m.history, input)
+	if len(m.history) > m.depth {
+		m.history = m.history[len(m.history)-m.depth:]
 	}
 
 	return input
 }
 
-func (m *StatefulMutator) ValidateOutput(output []byte, err error) *FuzzResult {
-	// For stateful tests, focus on state inconsistencies
-	if output != nil && len(output) > 0 {
-		if string(output) == "state_inconsistent" {
-			return &FuzzResult{
-				ID:                 fmt.Sprintf("stateful_%d", time.Now().UnixNano()),
-				Failed:             true,
-				StateInconsistency: true,
-				ErrorMessage:       "State transition inconsistency detected",
-				Input:              m.currentState, // Save the state that caused the issue
-			}
-		} else if string(output) == "consensus_failure" {
-			return &FuzzResult{
-				ID:               fmt.Sprintf("stateful_%d", time.Now().UnixNano()),
-				Failed:           true,
-				ConsensusFailure: true,
-				ErrorMessage:     "Consensus failure in state transition sequence",
-				Input:            m.currentState,
+// Observe updates the sequence state with what the target did. Only
+// operations that went through advance the state, and the objects they
+// created become available to later operations.
+func (m *StatefulMutator) Observe(obs Observation) {
+	if obs.Err != nil || len(obs.Input) == 0 {
+		return
+	}
+
+	m.currentState = append(m.currentState, obs.Input[0])
+	if len(m.currentState) > m.depth {
+		// Prevent state from growing too large
+		m.currentState = m.currentState[len(m.currentState)-m.depth:]
+	}
+
+	for _, change := range obs.Delta {
+		m.track(change)
+	}
+}
+
+// track records an object the target created, or forgets one it deleted
+func (m *StatefulMutator) track(change cosmossdk.StateChange) {
+	object := knownObject{Kind: change.Kind(), ID: change.ID()}
+	for i, known := range m.objects {
+		if known == object {
+			if change.Value == nil {
+				m.objects = append(m.objects[:i], m.objects[i+1:]...)
 			}
+			return
 		}
 	}
+	if change.Value == nil {
+		return
+	}
 
-	if err != nil && err.Error() != "invalid arguments" && err.Error() != "permission denied" {
-		return &FuzzResult{
-			ID:           fmt.Sprintf("stateful_%d", time.Now().UnixNano()),
-			Failed:       true,
-			ErrorMessage: fmt.Sprintf("Unexpected error in state sequence: %s", err.Error()),
-			Crashed:      true,
-			Input:        m.currentState,
-		}
+	m.objects = append(m.objects, object)
+	if len(m.objects) > m.depth {
+		m.objects = m.objects[len(m.objects)-m.depth:]
 	}
+}
+
+func (m *StatefulMutator) ValidateOutput(output []byte, err error) *FuzzResult {
+	return nil
+}
+
+// statefulMutatorState is the checkpointed form of a StatefulMutator
+type statefulMutatorState struct {
+	CurrentState []byte
+	History      [][]byte
+	Objects      []knownObject `json:",omitempty"`
+}
+
+func (m *StatefulMutator) MarshalState() ([]byte, error) {
+	return json.Marshal(statefulMutatorState{
+		CurrentState: m.currentState,
+		History:      m.history,
+		Objects:      m.objects,
+	})
+}
 
+func (m *StatefulMutator) UnmarshalState(data []byte) error {
+	var state statefulMutatorState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	m.currentState = state.CurrentState
+	m.history = state.History
+	m.objects = state.Objects
 	return nil
 }
 
+// lastInput returns the most recently generated step
+func (m *StatefulMutator) lastInput() []byte {
+	if len(m.history) == 0 {
+		return nil
+	}
+	return m.history[len(m.history)-1]
+}
+
 // SpecialCasesMutator implements known edge cases for Cosmos SDK
 type SpecialCasesMutator struct {
 	BaseMutator
-	cases [][]byte
-	index int
+	cases      [][]byte
+	index      int
+	rawPercent int
 }
 
-func NewSpecialCasesMutator(r *rand.Rand) *SpecialCasesMutator {
+// specialCaseInputs returns fresh copies of the predefined special cases
+func specialCaseInputs() [][]byte {
 	// Predefined test cases for common Cosmos SDK issues
-	specialCases := [][]byte{
+	return [][]byte{
 		// Empty message with valid signature
 		{0x1, 0x0, 0x0, 0x0, 0xAA, 0xBB, 0xCC, 0xDD},
 
@@ -283,20 +287,23 @@ func NewSpecialCasesMutator(r *rand.Rand) *SpecialCasesMutator {
 		// Module account misuse
 		{0x7, 0xA, 0xB, 0xC, 0xD, 0x1, 0x0, 0x0, 0x0},
 	}
+}
 
+func NewSpecialCasesMutator(r *rand.Rand) *SpecialCasesMutator {
 	return &SpecialCasesMutator{
 		BaseMutator: BaseMutator{
 			rand: r,
 			name: "SpecialCasesMutator",
 		},
-		cases: specialCases,
-		index: 0,
+		cases:      specialCaseInputs(),
+		index:      0,
+		rawPercent: 75,
 	}
 }
 
 func (m *SpecialCasesMutator) GenerateFuzzInput() []byte {
 	// Either use a predefined case or mutate one
-	useRaw := m.rand.Intn(100) < 75 // 75% chance to use raw special case
+	useRaw := m.rand.Intn(100) < m.rawPercent // 75% chance to use raw special case by default
 
 	var baseCase []byte
 	if useRaw {
@@ -324,37 +331,76 @@ func (m *SpecialCasesMutator) GenerateFuzzInput() []byte {
 }
 
 func (m *SpecialCasesMutator) ValidateOutput(output []byte, err error) *FuzzResult {
-	// For special cases, we're particularly interested in crashes
-	if err != nil {
-		// Ignore expected validation errors
-		if err.Error() != "invalid arguments" && err.Error() != "permission denied" {
-			return &FuzzResult{
-				ID:           fmt.Sprintf("special_%d", time.Now().UnixNano()),
-				Failed:       true,
-				ErrorMessage: fmt.Sprintf("Special case triggered error: %s", err.Error()),
-				Crashed:      true,
-			}
-		}
+	return nil
+}
+
+// CorpusMutator mutates inputs drawn from the coverage corpus
+type CorpusMutator struct {
+	BaseMutator
+	corpus       *Corpus
+	dictionary   [][]byte
+	maxMutations int
+}
+
+func NewCorpusMutator(r *rand.Rand, corpus *Corpus) *CorpusMutator {
+	return &CorpusMutator{
+		BaseMutator: BaseMutator{
+			rand: r,
+			name: "CorpusMutator",
+		},
+		corpus:       corpus,
+		maxMutations: 8,
+	}
+}
+
+func (m *CorpusMutator) GenerateFuzzInput() []byte {
+	input := m.corpus.Pick(m.rand)
+	if len(input) == 0 {
+		// Nothing interesting found yet, start from a small random input
+		input = make([]byte, 16+m.rand.Intn(48))
+		m.rand.Read(input)
+		return input
 	}
 
-	if output != nil && len(output) > 0 {
-		if string(output) == "state_inconsistent" {
-			return &FuzzResult{
-				ID:                 fmt.Sprintf("special_%d", time.Now().UnixNano()),
-				Failed:             true,
-				StateInconsistency: true,
-				ErrorMessage:       "Special case triggered state inconsistency",
+	// Stack a few small mutations on top of the corpus entry
+	ops := 5
+	if len(m.dictionary) > 0 {
+		ops++
+	}
+	mutations := 1 + m.rand.Intn(m.maxMutations)
+	for i := 0; i < mutations; i++ {
+		switch m.rand.Intn(ops) {
+		case 0:
+			// Overwrite a byte
+			input[m.rand.Intn(len(input))] = byte(m.rand.Intn(256))
+		case 1:
+			// Flip a bit
+			input[m.rand.Intn(len(input))] ^= 1 << uint(m.rand.Intn(8))
+		case 2:
+			// Insert a random byte
+			pos := m.rand.Intn(len(input) + 1)
+			input = append(input[:pos], append([]byte{byte(m.rand.Intn(256))}, input[pos:]...)...)
+		case 3:
+			// Delete a byte
+			if len(input) > 1 {
+				pos := m.rand.Intn(len(input))
+				input = append(input[:pos], input[pos+1:]...)
 			}
-		} else if string(output) == "consensus_failure" {
-			return &FuzzResult{
-				ID:               fmt.Sprintf("special_%d", time.Now().UnixNano()),
-				Failed:           true,
-				ConsensusFailure: true,
-				ErrorMessage:     "Special case triggered consensus failure",
+		case 4:
+			// Truncate the tail
+			if len(input) > 4 {
+				input = input[:4+m.rand.Intn(len(input)-4)]
 			}
+		case 5:
+			// Insert or overwrite with a dictionary token
+			input = spliceToken(m.rand, input, m.dictionary[m.rand.Intn(len(m.dictionary))])
 		}
 	}
 
+	return input
+}
+
+func (m *CorpusMutator) ValidateOutput(output []byte, err error) *FuzzResult {
 	return nil
 }
 
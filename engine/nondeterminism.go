@@ -0,0 +1,228 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoSec-Labs/StateStinger/utils/target/cosmossdk"
+)
+
+// Trace is one execution of an input as the nondeterminism oracle saw it
+type Trace struct {
+	GOMAXPROCS int
+	Output     []byte
+	Error      string
+	Events     []string
+	GasUsed    uint64
+	StateHash  string // Hash of a fresh store after the execution's writes
+}
+
+// nondeterminismOracle executes inputs again on the state they first ran
+// on, each rerun in a harness child of its own with another GOMAXPROCS and
+// goroutines competing for the scheduler, and reports any difference
+// between the runs as a consensus failure. The validators of a chain would
+// disagree on such an input.
+type nondeterminismOracle struct {
+	reruns     []*Harness // One per GOMAXPROCS setting
+	every      int        // Executions per check, 1 to check each one
+	executions int
+}
+
+func (o *nondeterminismOracle) Name() string {
+	return "nondeterminism"
+}
+
+func (o *nondeterminismOracle) Check(exec Execution) *FuzzResult {
+	o.executions++
+	if o.executions%o.every != 0 {
+		return nil
+	}
+	// A harness that gave up on the input has nothing to compare
+	if errors.Is(exec.Err, ErrHang) || errors.Is(exec.Err, ErrOOM) {
+		return nil
+	}
+
+	var delta []cosmossdk.StateChange
+	var prior cosmossdk.Store
+	if exec.State != nil {
+		delta = exec.State.Delta
+		prior = exec.State.Prior()
+	}
+	first := Trace{
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Output:     exec.Output,
+		Events:     exec.Events,
+		GasUsed:    exec.GasUsed,
+		StateHash:  freshStateHash(delta),
+	}
+	if exec.Err != nil {
+		first.Error = exec.Err.Error()
+	}
+
+	for _, harness := range o.reruns {
+		result := harness.ExecuteOn(exec.Input, prior)
+		if errors.Is(result.Err, ErrHang) || errors.Is(result.Err, ErrOOM) {
+			continue
+		}
+		trace := Trace{
+			GOMAXPROCS: harness.config.GOMAXPROCS,
+			Output:     result.Output,
+			Events:     result.Events,
+			GasUsed:    result.GasUsed,
+			StateHash:  freshStateHash(result.Delta),
+		}
+		if result.Err != nil {
+			trace.Error = result.Err.Error()
+		}
+		if what := traceDiff(first, trace); what != "" {
+			return &FuzzResult{
+				ID:               fmt.Sprintf("nondeterminism_%d", time.Now().UnixNano()),
+				Failed:           true,
+				ConsensusFailure: true,
+				ErrorMessage:     "Nondeterministic execution: " + what + " differs between runs",
+				Traces:           []Trace{first, trace},
+			}
+		}
+	}
+	return nil
+}
+
+// Close stops the rerun children
+func (o *nondeterminismOracle) Close() error {
+	for _, harness := range o.reruns {
+		harness.Close()
+	}
+	return nil
+}
+
+// perturb runs execute on a fresh goroutine after a number of yields while
+// noise goroutines keep yielding to the scheduler. The yields depend on the
+// input alone, so replays of a finding get the same runs.
+func perturb(input []byte, noise int, execute func() *cosmossdk.ExecResult) *cosmossdk.ExecResult {
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < noise; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+
+	yields := rand.New(rand.NewSource(int64(crc32.ChecksumIEEE(input)))).Intn(16)
+	done := make(chan *cosmossdk.ExecResult)
+	go func() {
+		for i := 0; i < yields; i++ {
+			runtime.Gosched()
+		}
+		done <- execute()
+	}()
+	result := <-done
+	close(stop)
+	wg.Wait()
+	return result
+}
+
+// freshStateHash returns the hash of an empty store after delta is applied
+func freshStateHash(delta []cosmossdk.StateChange) string {
+	store := NewStateStore()
+	store.Apply(delta)
+	return store.Hash()
+}
+
+// traceDiff names the first thing two traces disagree on, or returns ""
+func traceDiff(a, b Trace) string {
+	switch {
+	case string(a.Output) != string(b.Output):
+		return "output"
+	case a.Error != b.Error:
+		return "error"
+	case !slices.Equal(a.Events, b.Events):
+		return "events"
+	case a.GasUsed != b.GasUsed:
+		return "gas used"
+	case a.StateHash != b.StateHash:
+		return "state"
+	}
+	return ""
+}
+
+func (o *nondeterminismOracle) MarshalState() ([]byte, error) {
+	return json.Marshal(o.executions)
+}
+
+func (o *nondeterminismOracle) UnmarshalState(data []byte) error {
+	return json.Unmarshal(data, &o.executions)
+}
+
+func init() {
+	RegisterOracle(OracleInfo{
+		Name:        "nondeterminism",
+		Description: "Inputs that execute differently under other GOMAXPROCS settings and scheduling",
+		Params: map[string]string{
+			"procs": "GOMAXPROCS of the harness child of each rerun, separated by '|' (default \"1|<number of CPUs>\")",
+			"noise": "Goroutines competing for the scheduler during a rerun (default 4)",
+			"every": "Executions per check, to sample the inputs (default 100)",
+		},
+	}, func(env OracleEnv) (Oracle, error) {
+		if env.Target == nil {
+			return nil, fmt.Errorf("oracle nondeterminism needs a target")
+		}
+		o := &nondeterminismOracle{every: 100}
+		procs := []int{1, runtime.NumCPU()}
+		noise := 4
+		if value, ok := env.Params["procs"]; ok {
+			procs = nil
+			for _, field := range strings.Split(value, "|") {
+				n, err := strconv.Atoi(field)
+				if err != nil || n < 1 {
+					return nil, fmt.Errorf("oracle nondeterminism needs procs >= 1, got %q", field)
+				}
+				procs = append(procs, n)
+			}
+		}
+		if value, ok := env.Params["noise"]; ok {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("oracle nondeterminism needs noise >= 0, got %q", value)
+			}
+			noise = n
+		}
+		if value, ok := env.Params["every"]; ok {
+			every, err := strconv.Atoi(value)
+			if err != nil || every < 1 {
+				return nil, fmt.Errorf("oracle nondeterminism needs every >= 1, got %q", value)
+			}
+			o.every = every
+		}
+		for _, n := range procs {
+			o.reruns = append(o.reruns, NewHarness(HarnessConfig{
+				TargetPath:  env.Target.Path,
+				ModuleName:  env.Target.Name,
+				Timeout:     env.Limits.Timeout,
+				MemoryLimit: env.Limits.MemoryLimit,
+				SDKPanics:   env.Target.SDKPanics,
+				GOMAXPROCS:  n,
+				Perturb:     true,
+				Noise:       noise,
+			}))
+		}
+		return o, nil
+	})
+}
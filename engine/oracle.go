@@ -0,0 +1,321 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoSec-Labs/StateStinger/utils/target/cosmossdk"
+)
+
+// Execution is everything an oracle can judge one execution by
+type Execution struct {
+	Input    []byte
+	Output   []byte
+	Err      error
+	Handler  string               // Handler the input was dispatched to, empty if none
+	Events   []string             // Events the target emitted
+	GasUsed  uint64               // Gas the target consumed
+	Panic    *cosmossdk.PanicInfo // Set if the target panicked
+	Duration time.Duration        // Time the target took
+	State    *StateView           // Store before and after the execution
+	Mutator  StateMutator         // Mutator that generated the input, nil when replaying raw inputs
+	Diff     *Execution           // The same input on the -diff-target module, nil if there is none
+}
+
+// Oracle decides whether an execution is a finding. Oracles are independent
+// of the mutators and several of them judge every execution, the first one
+// to report a finding wins. Oracles that keep state across executions also
+// implement CheckpointableMutator, oracles that hold processes io.Closer.
+type Oracle interface {
+	Name() string
+	Check(exec Execution) *FuzzResult
+}
+
+// Tracker is implemented by oracles that keep running state of the store,
+// which must see every execution, including the ones an oracle before them
+// already reported
+type Tracker interface {
+	Track(exec Execution)
+}
+
+// OracleEnv is what an oracle constructor can draw on
+type OracleEnv struct {
+	Target     *cosmossdk.CosmosModule
+	DiffTarget *cosmossdk.CosmosModule // nil unless -diff-target is set
+	Params     map[string]string
+	Limits     HarnessConfig // Timeout and memory limit of harness children the oracle starts
+}
+
+// OracleFactory builds one oracle instance for a worker
+type OracleFactory func(env OracleEnv) (Oracle, error)
+
+// OracleInfo describes a registered oracle
+type OracleInfo struct {
+	Name        string
+	Description string
+	Params      map[string]string // Parameter name to description
+}
+
+type registeredOracle struct {
+	info    OracleInfo
+	factory OracleFactory
+}
+
+var oracleRegistry = make(map[string]registeredOracle)
+
+// RegisterOracle makes an oracle selectable by name. It panics if the name
+// is already taken, as registration happens from init functions.
+func RegisterOracle(info OracleInfo, factory OracleFactory) {
+	if _, ok := oracleRegistry[info.Name]; ok {
+		panic("engine: oracle registered twice: " + info.Name)
+	}
+	oracleRegistry[info.Name] = registeredOracle{info: info, factory: factory}
+}
+
+// RegisteredOracles lists the available oracles sorted by name
+func RegisteredOracles() []OracleInfo {
+	infos := make([]OracleInfo, 0, len(oracleRegistry))
+	for _, entry := range oracleRegistry {
+		infos = append(infos, entry.info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// NewOracle builds the named oracle, rejecting parameters it does not know
+func NewOracle(name string, env OracleEnv) (Oracle, error) {
+	entry, ok := oracleRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown oracle: %s", name)
+	}
+
+	for param := range env.Params {
+		if _, ok := entry.info.Params[param]; !ok {
+			return nil, fmt.Errorf("oracle %s has no parameter %q", name, param)
+		}
+	}
+
+	return entry.factory(env)
+}
+
+// defaultOracles is the selection used when Config.Oracles is empty. The
+// mutator oracle comes last so mutator-specific checks still run.
+func defaultOracles() []string {
+	return []string{"crash", "state", "consensus", "coins", "mutator"}
+}
+
+// checkOracles runs the oracles in order and returns the first finding,
+// tagged with the oracle that reported it
+func checkOracles(oracles []Oracle, exec Execution) *FuzzResult {
+	for i, oracle := range oracles {
+		if result := oracle.Check(exec); result != nil {
+			result.Oracle = oracle.Name()
+			for _, rest := range oracles[i+1:] {
+				if tracker, ok := rest.(Tracker); ok {
+					tracker.Track(exec)
+				}
+			}
+			return result
+		}
+	}
+	return nil
+}
+
+// crashOracle reports hangs and OOMs of the harness, every panic and every
+// error the target returns except the ones it is expected to reject inputs
+// with
+type crashOracle struct {
+	expected []string
+}
+
+func (o *crashOracle) Name() string {
+	return "crash"
+}
+
+func (o *crashOracle) Check(exec Execution) *FuzzResult {
+	switch {
+	case errors.Is(exec.Err, ErrHang):
+		return &FuzzResult{
+			ID:           fmt.Sprintf("hang_%d", time.Now().UnixNano()),
+			Failed:       true,
+			ErrorMessage: "Target hung: " + exec.Err.Error(),
+			Hang:         true,
+		}
+	case errors.Is(exec.Err, ErrOOM):
+		return &FuzzResult{
+			ID:           fmt.Sprintf("oom_%d", time.Now().UnixNano()),
+			Failed:       true,
+			ErrorMessage: "Target ran " + exec.Err.Error(),
+			OOM:          true,
+		}
+	}
+	if exec.Panic != nil {
+		return &FuzzResult{
+			ID:           fmt.Sprintf("crash_%d", time.Now().UnixNano()),
+			Failed:       true,
+			ErrorMessage: panicMessage(exec),
+			Crashed:      true,
+		}
+	}
+	if exec.Err == nil {
+		return nil
+	}
+	for _, expected := range o.expected {
+		if exec.Err.Error() == expected {
+			return nil
+		}
+	}
+	return &FuzzResult{
+		ID:           fmt.Sprintf("crash_%d", time.Now().UnixNano()),
+		Failed:       true,
+		ErrorMessage: exec.Err.Error(),
+		Crashed:      true,
+	}
+}
+
+// panicMessage describes a panic, telling runtime errors apart from
+// panics recovered into ErrPanic with -sdk-panics
+func panicMessage(exec Execution) string {
+	switch {
+	case exec.Panic.Runtime:
+		return "Runtime panic: " + exec.Panic.Value
+	case errors.Is(exec.Err, cosmossdk.ErrPanic):
+		return "Panic recovered as ErrPanic: " + exec.Panic.Value
+	}
+	return "Handler panicked: " + exec.Panic.Value
+}
+
+// attachPanic records the panic of an execution with its finding,
+// whichever oracle reported it
+func attachPanic(result *FuzzResult, info *cosmossdk.PanicInfo) {
+	if info == nil {
+		return
+	}
+	result.PanicValue = info.Value
+	result.Stack = info.Stack
+	result.RuntimePanic = info.Runtime
+}
+
+// markerOracle reports executions whose output is the marker the target
+// signals a failure class with
+type markerOracle struct {
+	name    string
+	marker  string
+	message string
+	class   func(result *FuzzResult)
+}
+
+func (o *markerOracle) Name() string {
+	return o.name
+}
+
+func (o *markerOracle) Check(exec Execution) *FuzzResult {
+	if len(exec.Output) == 0 || string(exec.Output) != o.marker {
+		return nil
+	}
+	result := &FuzzResult{
+		ID:           fmt.Sprintf("%s_%d", o.name, time.Now().UnixNano()),
+		Failed:       true,
+		ErrorMessage: o.message,
+	}
+	o.class(result)
+	return result
+}
+
+// mutatorOracle defers to the validation of the mutator that generated the
+// input, which covers plugins and mutators with checks of their own
+type mutatorOracle struct{}
+
+func (o mutatorOracle) Name() string {
+	return "mutator"
+}
+
+func (o mutatorOracle) Check(exec Execution) *FuzzResult {
+	if exec.Mutator == nil {
+		return nil
+	}
+	return validate(exec.Mutator, exec.Input, exec.Output, exec.Err)
+}
+
+// coinsOracle reports accepted inputs that carry a Coin or Coins value the
+// SDK would have rejected, whichever mutator built them
+type coinsOracle struct{}
+
+func (o coinsOracle) Name() string {
+	return "coins"
+}
+
+func (o coinsOracle) Check(exec Execution) *FuzzResult {
+	return acceptedMalformedCoins(exec.Input, exec.Err)
+}
+
+func init() {
+	RegisterOracle(OracleInfo{
+		Name:        "crash",
+		Description: "Errors other than the expected rejections",
+		Params: map[string]string{
+			"expected": "Errors that are not crashes, separated by '|' (default \"invalid arguments|permission denied\")",
+		},
+	}, func(env OracleEnv) (Oracle, error) {
+		expected := "invalid arguments|permission denied"
+		if value, ok := env.Params["expected"]; ok {
+			expected = value
+		}
+		return &crashOracle{expected: strings.Split(expected, "|")}, nil
+	})
+
+	RegisterOracle(OracleInfo{
+		Name:        "state",
+		Description: "Outputs reporting a state inconsistency",
+		Params: map[string]string{
+			"marker": "Output that signals the failure (default \"state_inconsistent\")",
+		},
+	}, func(env OracleEnv) (Oracle, error) {
+		o := &markerOracle{
+			name:    "state",
+			marker:  "state_inconsistent",
+			message: "State inconsistency detected",
+			class:   func(result *FuzzResult) { result.StateInconsistency = true },
+		}
+		if value, ok := env.Params["marker"]; ok {
+			o.marker = value
+		}
+		return o, nil
+	})
+
+	RegisterOracle(OracleInfo{
+		Name:        "consensus",
+		Description: "Outputs reporting a consensus failure",
+		Params: map[string]string{
+			"marker": "Output that signals the failure (default \"consensus_failure\")",
+		},
+	}, func(env OracleEnv) (Oracle, error) {
+		o := &markerOracle{
+			name:    "consensus",
+			marker:  "consensus_failure",
+			message: "Consensus failure detected",
+			class:   func(result *FuzzResult) { result.ConsensusFailure = true },
+		}
+		if value, ok := env.Params["marker"]; ok {
+			o.marker = value
+		}
+		return o, nil
+	})
+
+	RegisterOracle(OracleInfo{
+		Name:        "mutator",
+		Description: "The checks of the mutator that generated the input",
+	}, func(env OracleEnv) (Oracle, error) {
+		return mutatorOracle{}, nil
+	})
+
+	RegisterOracle(OracleInfo{
+		Name:        "coins",
+		Description: "Accepted inputs with malformed Coin or Coins values",
+	}, func(env OracleEnv) (Oracle, error) {
+		return coinsOracle{}, nil
+	})
+}
@@ -0,0 +1,375 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"os/exec"
+	"time"
+)
+
+/*
+Out-of-process mutators talk to the engine over their stdin and stdout.
+Every message is a 4-byte big-endian length followed by that many bytes of
+JSON. The engine sends a pluginRequest and the plugin answers each one with
+a pluginResponse, in order:
+
+	describe  -> Info      name, description and parameters of the mutator
+	init      -> (empty)   Seed and Params for this instance
+	generate  -> Input     the next fuzz input
+	validate  -> Result    FuzzResult for Output/Error, or null
+
+Plugins must write their logs to stderr. Go plugins can use ServePlugin.
+*/
+
+// maxFrameSize guards against a confused peer sending a garbage length
+const maxFrameSize = 64 << 20
+
+// maxPluginRestarts is how many times in a row a plugin may fail before it is given up on
+const maxPluginRestarts = 5
+
+// defaultPluginTimeout is how long a plugin may take to answer by default
+const defaultPluginTimeout = 5 * time.Second
+
+type pluginRequest struct {
+	Method string
+	Seed   int64             `json:",omitempty"`
+	Params map[string]string `json:",omitempty"`
+	Output []byte            `json:",omitempty"`
+	Error  *string           `json:",omitempty"`
+}
+
+type pluginResponse struct {
+	Info   *MutatorInfo `json:",omitempty"`
+	Input  []byte       `json:",omitempty"`
+	Result *FuzzResult  `json:",omitempty"`
+	Error  string       `json:",omitempty"`
+}
+
+// writeFrame sends v as one length-prefixed JSON message
+func writeFrame(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(data)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// readFrame receives one length-prefixed JSON message into v
+func readFrame(r io.Reader, v interface{}) error {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxFrameSize {
+		return fmt.Errorf("frame of %d bytes exceeds limit", size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// pluginProcess is one running plugin executable
+type pluginProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+func startPluginProcess(path string) (*pluginProcess, error) {
+	cmd := exec.Command(path)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %v", path, err)
+	}
+
+	return &pluginProcess{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+	}, nil
+}
+
+// roundTrip sends a request and waits up to timeout for the answer. An
+// unresponsive plugin is killed, which also ends the pending read.
+func (p *pluginProcess) roundTrip(req pluginRequest, timeout time.Duration) (pluginResponse, error) {
+	type reply struct {
+		resp pluginResponse
+		err  error
+	}
+
+	done := make(chan reply, 1)
+	go func() {
+		var r reply
+		if r.err = writeFrame(p.stdin, req); r.err == nil {
+			r.err = readFrame(p.stdout, &r.resp)
+		}
+		done <- r
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		if r.err == nil && r.resp.Error != "" {
+			r.err = errors.New(r.resp.Error)
+		}
+		return r.resp, r.err
+	case <-timer.C:
+		// Wait must not run before the pending read returns, which it does
+		// once the plugin is gone
+		p.terminate()
+		<-done
+		p.cmd.Wait()
+		return pluginResponse{}, fmt.Errorf("plugin did not answer %s within %s", req.Method, timeout)
+	}
+}
+
+// terminate closes the plugin's stdin and kills it without waiting for it
+func (p *pluginProcess) terminate() {
+	p.stdin.Close()
+	if p.cmd.Process != nil {
+		p.cmd.Process.Kill()
+	}
+}
+
+// kill stops a plugin that has no request in flight and waits for it to exit
+func (p *pluginProcess) kill() {
+	p.terminate()
+	p.cmd.Wait()
+}
+
+// describePlugin starts a plugin just long enough to ask what it provides
+func describePlugin(path string, timeout time.Duration) (MutatorInfo, error) {
+	proc, err := startPluginProcess(path)
+	if err != nil {
+		return MutatorInfo{}, err
+	}
+	defer proc.kill()
+
+	resp, err := proc.roundTrip(pluginRequest{Method: "describe"}, timeout)
+	if err != nil {
+		return MutatorInfo{}, fmt.Errorf("plugin %s: %v", path, err)
+	}
+	if resp.Info == nil || resp.Info.Name == "" {
+		return MutatorInfo{}, fmt.Errorf("plugin %s did not report a name", path)
+	}
+	return *resp.Info, nil
+}
+
+// RegisterPlugin adds the mutator served by the executable at path to the
+// registry and returns its name
+func RegisterPlugin(path string, timeout time.Duration) (string, error) {
+	info, err := describePlugin(path, timeout)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := mutatorRegistry[info.Name]; ok {
+		return "", fmt.Errorf("plugin %s: mutator %s already exists", path, info.Name)
+	}
+
+	RegisterMutator(info, func(env MutatorEnv) (StateMutator, error) {
+		m := &PluginMutator{
+			path:    path,
+			name:    info.Name,
+			seed:    env.Rand.Int63(),
+			params:  env.Params,
+			timeout: timeout,
+		}
+		if err := m.start(); err != nil {
+			return nil, err
+		}
+		return m, nil
+	})
+
+	return info.Name, nil
+}
+
+// PluginMutator runs a mutator implemented by a separate executable. The
+// process is restarted when it crashes or stops answering.
+type PluginMutator struct {
+	path     string
+	name     string
+	seed     int64
+	params   map[string]string
+	timeout  time.Duration
+	proc     *pluginProcess
+	restarts int // Consecutive failures, reset by every successful call
+	total    int // Restarts over the lifetime of the mutator
+}
+
+func (m *PluginMutator) Name() string {
+	return m.name
+}
+
+// start launches the plugin process and initializes it. Each restart gets
+// a different seed so the plugin does not walk into the same crash again.
+func (m *PluginMutator) start() error {
+	proc, err := startPluginProcess(m.path)
+	if err != nil {
+		return err
+	}
+
+	init := pluginRequest{Method: "init", Seed: m.seed + int64(m.total), Params: m.params}
+	if _, err := proc.roundTrip(init, m.timeout); err != nil {
+		proc.kill()
+		return fmt.Errorf("plugin %s failed to initialize: %v", m.name, err)
+	}
+
+	m.proc = proc
+	return nil
+}
+
+// call performs a request, restarting the plugin and retrying on failure.
+// After too many failures in a row the plugin is disabled for good.
+func (m *PluginMutator) call(req pluginRequest) (pluginResponse, bool) {
+	for m.restarts < maxPluginRestarts {
+		if m.proc == nil {
+			m.restarts++
+			m.total++
+			if err := m.start(); err != nil {
+				log.Printf("Warning: %v", err)
+				continue
+			}
+		}
+
+		resp, err := m.proc.roundTrip(req, m.timeout)
+		if err == nil {
+			m.restarts = 0
+			return resp, true
+		}
+
+		log.Printf("Warning: Plugin %s failed on %s, restarting: %v", m.name, req.Method, err)
+		m.proc.kill()
+		m.proc = nil
+	}
+
+	if m.restarts == maxPluginRestarts {
+		log.Printf("Warning: Plugin %s failed %d times in a row, disabling it", m.name, m.restarts)
+		m.restarts++
+	}
+	return pluginResponse{}, false
+}
+
+// Disabled reports whether the plugin failed too many times in a row to be
+// used again
+func (m *PluginMutator) Disabled() bool {
+	return m.restarts > maxPluginRestarts
+}
+
+func (m *PluginMutator) GenerateFuzzInput() []byte {
+	resp, ok := m.call(pluginRequest{Method: "generate"})
+	if !ok {
+		return []byte{}
+	}
+	return resp.Input
+}
+
+func (m *PluginMutator) ValidateOutput(output []byte, err error) *FuzzResult {
+	req := pluginRequest{Method: "validate", Output: output}
+	if err != nil {
+		message := err.Error()
+		req.Error = &message
+	}
+
+	resp, ok := m.call(req)
+	if !ok {
+		return nil
+	}
+	return resp.Result
+}
+
+// Close stops the plugin process
+func (m *PluginMutator) Close() error {
+	if m.proc != nil {
+		m.proc.kill()
+		m.proc = nil
+	}
+	return nil
+}
+
+// PluginFactory builds the mutator a plugin process serves
+type PluginFactory func(r *rand.Rand, params map[string]string) (StateMutator, error)
+
+// ServePlugin implements the plugin side of the protocol on stdin and
+// stdout, so a Go program can expose a StateMutator as a plugin. It returns
+// when the engine closes the connection.
+func ServePlugin(info MutatorInfo, factory PluginFactory) error {
+	in := bufio.NewReader(os.Stdin)
+	out := bufio.NewWriter(os.Stdout)
+	var mutator StateMutator
+
+	for {
+		var req pluginRequest
+		if err := readFrame(in, &req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		var resp pluginResponse
+		switch req.Method {
+		case "describe":
+			resp.Info = &info
+		case "init":
+			m, err := factory(rand.New(rand.NewSource(req.Seed)), req.Params)
+			if err != nil {
+				resp.Error = err.Error()
+			}
+			mutator = m
+		case "generate", "validate":
+			if mutator == nil {
+				resp.Error = "plugin not initialized"
+				break
+			}
+			if req.Method == "generate" {
+				resp.Input = mutator.GenerateFuzzInput()
+				break
+			}
+			var err error
+			if req.Error != nil {
+				err = errors.New(*req.Error)
+			}
+			resp.Result = mutator.ValidateOutput(req.Output, err)
+		default:
+			resp.Error = fmt.Sprintf("unknown method %q", req.Method)
+		}
+
+		if err := writeFrame(out, resp); err != nil {
+			return err
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	message := "bad denom"
+	tests := []struct {
+		name  string
+		value interface{}
+		into  func() interface{}
+	}{
+		{"info request", &pluginRequest{Method: "info"}, func() interface{} { return &pluginRequest{} }},
+		{"init request", &pluginRequest{Method: "init", Seed: -7, Params: map[string]string{"depth": "3"}},
+			func() interface{} { return &pluginRequest{} }},
+		{"validate request", &pluginRequest{Method: "validate", Output: []byte{0, 1, 0xff}, Error: &message},
+			func() interface{} { return &pluginRequest{} }},
+		{"input response", &pluginResponse{Input: []byte("\x00\x03{}")}, func() interface{} { return &pluginResponse{} }},
+		{"result response", &pluginResponse{Result: &FuzzResult{ID: "plugin_1", Failed: true, Input: []byte{1}}},
+			func() interface{} { return &pluginResponse{} }},
+		{"error response", &pluginResponse{Error: "no such method"}, func() interface{} { return &pluginResponse{} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, writeFrame(&buf, tt.value))
+			assert.Equal(t, uint32(buf.Len()-4), binary.BigEndian.Uint32(buf.Bytes()[:4]))
+
+			got := tt.into()
+			require.NoError(t, readFrame(&buf, got))
+			assert.Equal(t, tt.value, got)
+			assert.Zero(t, buf.Len(), "frame not read to the end")
+		})
+	}
+}
+
+func TestReadFrameErrors(t *testing.T) {
+	header := func(size uint32) []byte {
+		return binary.BigEndian.AppendUint32(nil, size)
+	}
+
+	tests := []struct {
+		name  string
+		input []byte
+		err   error // Exact error expected, nil to only require one
+	}{
+		{"closed stream", nil, io.EOF},
+		{"truncated header", []byte{0, 0}, io.ErrUnexpectedEOF},
+		{"truncated body", append(header(10), `{"Input"`...), io.ErrUnexpectedEOF},
+		{"over the limit", header(maxFrameSize + 1), nil},
+		{"largest length", header(1<<32 - 1), nil},
+		{"not JSON", append(header(3), "abc"...), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var response pluginResponse
+			err := readFrame(bytes.NewReader(tt.input), &response)
+			require.Error(t, err)
+			if tt.err != nil {
+				assert.Equal(t, tt.err, err)
+			}
+		})
+	}
+}
+
+// TestReadFrameLimit checks that a length over the limit is rejected before
+// any of the body is read
+func TestReadFrameLimit(t *testing.T) {
+	reader := bytes.NewReader(binary.BigEndian.AppendUint32(nil, maxFrameSize+1))
+	err := readFrame(io.MultiReader(reader, neverReader{t}), &pluginResponse{})
+	assert.ErrorContains(t, err, "exceeds limit")
+}
+
+// neverReader fails the test if anything reads from it
+type neverReader struct {
+	t *testing.T
+}
+
+func (r neverReader) Read([]byte) (int, error) {
+	r.t.Fatal("read past the frame header")
+	return 0, io.EOF
+}
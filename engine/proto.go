@@ -0,0 +1,440 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/big"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/GoSec-Labs/StateStinger/utils/protowire"
+	"github.com/GoSec-Labs/StateStinger/utils/target/cosmossdk"
+)
+
+// Wire-level mutations applied on top of a valid encoding
+var protoOps = []string{
+	"varint_overflow",
+	"wrong_wire_type",
+	"duplicate_field",
+	"unknown_field",
+	"truncate",
+	"repack",
+	"nested",
+}
+
+// maxProtoDepth limits how deep nested messages are encoded
+const maxProtoDepth = 4
+
+// ProtoMutator encodes valid protobuf messages from the module's .proto
+// files and then breaks them at the wire level. Inputs are laid out like
+// structured ones: handler byte, outcome byte, then the encoded message.
+type ProtoMutator struct {
+	BaseMutator
+	target       *cosmossdk.CosmosModule
+	schema       *cosmossdk.ProtoSchema
+	dictionary   [][]byte
+	addresses    *AddressGenerator
+	maxMutations int
+	validPercent int
+	lastMessage  string // Full name of the message in the last input
+}
+
+func NewProtoMutator(r *rand.Rand, target *cosmossdk.CosmosModule, dictionary [][]byte, prefix string) *ProtoMutator {
+	return &ProtoMutator{
+		BaseMutator: BaseMutator{
+			rand: r,
+			name: "ProtoMutator",
+		},
+		target:       target,
+		schema:       target.Proto,
+		dictionary:   dictionary,
+		addresses:    NewAddressGenerator(r, prefix),
+		maxMutations: 3,
+		validPercent: 20,
+	}
+}
+
+// message picks the handler to target and the message to send it
+func (m *ProtoMutator) message() (int, *cosmossdk.ProtoMessage) {
+	handler := 0
+	if len(m.target.Handlers) > 0 {
+		handler = m.rand.Intn(len(m.target.Handlers))
+		if msg := m.target.ProtoMessageFor(m.target.Handlers[handler]); msg != nil {
+			return handler, msg
+		}
+	}
+
+	// The handler's message is unknown, send one of the Msg service's
+	if len(m.schema.Msgs) > 0 {
+		if msg, ok := m.schema.Messages[m.schema.Msgs[m.rand.Intn(len(m.schema.Msgs))]]; ok {
+			return handler, msg
+		}
+	}
+	return handler, nil
+}
+
+func (m *ProtoMutator) GenerateFuzzInput() []byte {
+	handler, msg := m.message()
+	input := []byte{byte(handler), byte(m.rand.Intn(256))}
+	if msg == nil {
+		// Without a schema still send a well-formed message, a single
+		// varint field the decoder skips as unknown, so the input is not
+		// rejected as too short
+		m.lastMessage = ""
+		payload := protowire.AppendTag(nil, int32(1+m.rand.Intn(15)), protowire.VarintType)
+		payload = protowire.AppendVarint(payload, m.integer("uint64"))
+		return append(input, payload...)
+	}
+	m.lastMessage = msg.Name
+
+	payload := m.encodeMessage(msg, 0)
+	if m.rand.Intn(100) >= m.validPercent {
+		mutations := 1 + m.rand.Intn(m.maxMutations)
+		for i := 0; i < mutations; i++ {
+			payload = m.mutate(msg, payload, 0)
+		}
+	}
+
+	return append(input, payload...)
+}
+
+// encodeMessage encodes a valid message with every field set
+func (m *ProtoMutator) encodeMessage(msg *cosmossdk.ProtoMessage, depth int) []byte {
+	var b []byte
+	for _, field := range msg.Fields {
+		count := 1
+		if field.Repeated {
+			count = m.rand.Intn(4)
+		}
+		if count == 0 {
+			continue
+		}
+
+		wireType := m.schema.WireType(field.Type)
+		if field.Repeated && field.Packed {
+			var packed []byte
+			for i := 0; i < count; i++ {
+				packed = m.appendValue(packed, field, depth)
+			}
+			b = protowire.AppendTag(b, field.Number, protowire.BytesType)
+			b = protowire.AppendBytes(b, packed)
+			continue
+		}
+
+		for i := 0; i < count; i++ {
+			b = protowire.AppendTag(b, field.Number, wireType)
+			b = m.appendValue(b, field, depth)
+		}
+	}
+	return b
+}
+
+// appendValue appends one valid value of the field's type
+func (m *ProtoMutator) appendValue(b []byte, field cosmossdk.ProtoField, depth int) []byte {
+	switch field.Type {
+	case "int32", "int64", "uint32", "uint64":
+		return protowire.AppendVarint(b, m.integer(field.Type))
+	case "sint32", "sint64":
+		return protowire.AppendVarint(b, protowire.EncodeZigZag(int64(m.integer(field.Type))))
+	case "bool":
+		return protowire.AppendVarint(b, uint64(m.rand.Intn(2)))
+	case "fixed32", "sfixed32":
+		return protowire.AppendFixed32(b, m.rand.Uint32())
+	case "float":
+		return protowire.AppendFixed32(b, math.Float32bits(float32(m.rand.NormFloat64()*1e3)))
+	case "fixed64", "sfixed64":
+		return protowire.AppendFixed64(b, m.rand.Uint64())
+	case "double":
+		return protowire.AppendFixed64(b, math.Float64bits(m.rand.NormFloat64()*1e6))
+	case "string":
+		return protowire.AppendBytes(b, []byte(m.stringValue(field)))
+	case "bytes":
+		value := make([]byte, m.rand.Intn(33))
+		m.rand.Read(value)
+		return protowire.AppendBytes(b, value)
+	}
+
+	if enum, ok := m.schema.Enums[field.Type]; ok {
+		values := make([]int32, 0, len(enum.Values))
+		for v := range enum.Values {
+			values = append(values, v)
+		}
+		if len(values) == 0 {
+			return protowire.AppendVarint(b, 0)
+		}
+		// Map order is random, pick by rank to stay reproducible
+		pick := m.rand.Intn(len(values))
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		return protowire.AppendVarint(b, uint64(int64(values[pick])))
+	}
+
+	nested, ok := m.schema.Messages[field.Type]
+	if !ok || depth >= maxProtoDepth {
+		// Types from imports that are not in the proto tree, such as Any
+		return protowire.AppendBytes(b, nil)
+	}
+	return protowire.AppendBytes(b, m.encodeMessage(nested, depth+1))
+}
+
+// integer returns a valid value for an integer type, mostly small
+func (m *ProtoMutator) integer(t string) uint64 {
+	if m.rand.Intn(2) == 0 {
+		return uint64(m.rand.Intn(1000))
+	}
+	switch t {
+	case "int32", "sint32":
+		return uint64(int64(int32(m.rand.Uint32())))
+	case "uint32":
+		return uint64(m.rand.Uint32())
+	}
+	return m.rand.Uint64()
+}
+
+// stringValue fills string fields, taking their gogoproto custom type and
+// cosmos_proto scalar annotations into account
+func (m *ProtoMutator) stringValue(field cosmossdk.ProtoField) string {
+	role, ok := scalarRole(field.Options["(cosmos_proto.scalar)"])
+	if !ok {
+		role, ok = addressFieldRole(field.Name)
+	}
+	if ok {
+		return m.addresses.Generate(role, 70)
+	}
+
+	customType := field.Options["(gogoproto.customtype)"]
+	switch {
+	case strings.HasSuffix(customType, "Int"):
+		return new(big.Int).Rand(m.rand, maxUint256).String()
+	case strings.HasSuffix(customType, "Dec"):
+		// LegacyDec travels as its integer value scaled by 10^18
+		return new(big.Int).Rand(m.rand, big.NewInt(math.MaxInt64)).String()
+	}
+
+	if len(m.dictionary) > 0 && m.rand.Intn(4) == 0 {
+		return string(m.dictionary[m.rand.Intn(len(m.dictionary))])
+	}
+
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	s := make([]byte, 1+m.rand.Intn(32))
+	for i := range s {
+		s[i] = alphabet[m.rand.Intn(len(alphabet))]
+	}
+	return string(s)
+}
+
+// mutate applies one wire-level mutation to an encoded message
+func (m *ProtoMutator) mutate(msg *cosmossdk.ProtoMessage, payload []byte, depth int) []byte {
+	fields, err := protowire.ParseFields(payload)
+	if err != nil || len(fields) == 0 {
+		// Already broken or empty, all that is left is adding to it
+		return m.appendUnknown(payload, msg)
+	}
+
+	i := m.rand.Intn(len(fields))
+	f := fields[i]
+
+	switch protoOps[m.rand.Intn(len(protoOps))] {
+	case "varint_overflow":
+		if f.Type != protowire.VarintType {
+			break
+		}
+		switch m.rand.Intn(3) {
+		case 0:
+			// Eleven bytes, more than any 64-bit value needs
+			f.Body = append(bytes.Repeat([]byte{0xFF}, 10), 0x01)
+		case 1:
+			// Non-minimal encoding of the same value, padded with a zero group
+			if len(f.Body) < 10 {
+				f.Body = append(append([]byte{}, f.Body...), 0x00)
+				f.Body[len(f.Body)-2] |= 0x80
+			}
+		default:
+			f.Body = protowire.AppendVarint(nil, math.MaxUint64)
+		}
+		fields[i] = f
+
+	case "wrong_wire_type":
+		var others []protowire.Type
+		for _, t := range []protowire.Type{protowire.VarintType, protowire.Fixed64Type, protowire.BytesType, protowire.Fixed32Type} {
+			if t != f.Type {
+				others = append(others, t)
+			}
+		}
+		t := others[m.rand.Intn(len(others))]
+		fields[i].Tag = protowire.AppendTag(nil, f.Number, t)
+
+	case "duplicate_field":
+		at := m.rand.Intn(len(fields) + 1)
+		fields = append(fields[:at], append([]protowire.Field{f}, fields[at:]...)...)
+
+	case "unknown_field":
+		return m.appendUnknown(protowire.AppendFields(nil, fields), msg)
+
+	case "truncate":
+		if f.Type == protowire.BytesType && m.rand.Intn(2) == 0 {
+			// Claim more bytes than follow
+			content := f.Content()
+			f.Body = protowire.AppendVarint(nil, uint64(len(content)+1+m.rand.Intn(64)))
+			f.Body = append(f.Body, content...)
+			fields = append(fields[:i], f)
+		} else {
+			encoded := protowire.AppendFields(nil, fields)
+			return encoded[:m.rand.Intn(len(encoded))]
+		}
+
+	case "repack":
+		fields = m.repack(msg, fields)
+
+	case "nested":
+		field := msg.FieldByNumber(f.Number)
+		if field == nil || f.Type != protowire.BytesType || depth >= maxProtoDepth {
+			break
+		}
+		if nested, ok := m.schema.Messages[field.Type]; ok {
+			fields[i].Body = protowire.AppendBytes(nil, m.mutate(nested, f.Content(), depth+1))
+		}
+	}
+
+	return protowire.AppendFields(nil, fields)
+}
+
+// appendUnknown adds a field with a number the message does not declare
+func (m *ProtoMutator) appendUnknown(payload []byte, msg *cosmossdk.ProtoMessage) []byte {
+	number := int32(1)
+	for _, field := range msg.Fields {
+		if field.Number >= number {
+			number = field.Number + 1
+		}
+	}
+	if m.rand.Intn(4) == 0 {
+		number = protowire.MaxFieldNumber
+	} else {
+		number += int32(m.rand.Intn(16))
+	}
+
+	switch m.rand.Intn(4) {
+	case 0:
+		payload = protowire.AppendTag(payload, number, protowire.VarintType)
+		return protowire.AppendVarint(payload, m.rand.Uint64())
+	case 1:
+		payload = protowire.AppendTag(payload, number, protowire.Fixed32Type)
+		return protowire.AppendFixed32(payload, m.rand.Uint32())
+	case 2:
+		payload = protowire.AppendTag(payload, number, protowire.Fixed64Type)
+		return protowire.AppendFixed64(payload, m.rand.Uint64())
+	}
+	value := make([]byte, m.rand.Intn(33))
+	m.rand.Read(value)
+	payload = protowire.AppendTag(payload, number, protowire.BytesType)
+	return protowire.AppendBytes(payload, value)
+}
+
+// repack switches a repeated scalar field between packed and unpacked
+// encoding, both of which decoders must accept
+func (m *ProtoMutator) repack(msg *cosmossdk.ProtoMessage, fields []protowire.Field) []protowire.Field {
+	for _, field := range msg.Fields {
+		if !field.Repeated || !m.schema.Packable(field.Type) {
+			continue
+		}
+		elemType := m.schema.WireType(field.Type)
+
+		for i, f := range fields {
+			if f.Number != field.Number {
+				continue
+			}
+
+			if f.Type == protowire.BytesType {
+				// Packed: split into one field per element
+				var unpacked []protowire.Field
+				content := f.Content()
+				for len(content) > 0 {
+					n := elementLength(elemType, content)
+					if n == 0 {
+						break
+					}
+					unpacked = append(unpacked, protowire.Field{
+						Number: f.Number,
+						Type:   elemType,
+						Tag:    protowire.AppendTag(nil, f.Number, elemType),
+						Body:   content[:n],
+					})
+					content = content[n:]
+				}
+				rest := append(unpacked, fields[i+1:]...)
+				return append(fields[:i:i], rest...)
+			}
+
+			// Unpacked: gather every element into one packed field
+			var packed []byte
+			kept := fields[:i:i]
+			for _, g := range fields[i:] {
+				if g.Number == field.Number && g.Type == elemType {
+					packed = append(packed, g.Body...)
+				} else {
+					kept = append(kept, g)
+				}
+			}
+			merged := protowire.Field{
+				Number: field.Number,
+				Type:   protowire.BytesType,
+				Tag:    protowire.AppendTag(nil, field.Number, protowire.BytesType),
+				Body:   protowire.AppendBytes(nil, packed),
+			}
+			return append(kept[:i:i], append([]protowire.Field{merged}, kept[i:]...)...)
+		}
+	}
+	return fields
+}
+
+// elementLength is the size of the next packed element, 0 if it is cut off
+func elementLength(t protowire.Type, b []byte) int {
+	switch t {
+	case protowire.VarintType:
+		_, n, err := protowire.ConsumeVarint(b)
+		if err != nil {
+			return 0
+		}
+		return n
+	case protowire.Fixed32Type:
+		if len(b) >= 4 {
+			return 4
+		}
+	case protowire.Fixed64Type:
+		if len(b) >= 8 {
+			return 8
+		}
+	}
+	return 0
+}
+
+// DecodeInput shows the message in an input by field name
+func (m *ProtoMutator) DecodeInput(input []byte) json.RawMessage {
+	if len(input) < 2 {
+		return nil
+	}
+
+	name := m.lastMessage
+	if index := int(input[0]) % (len(m.target.Handlers) + 1); index < len(m.target.Handlers) {
+		if msg := m.target.ProtoMessageFor(m.target.Handlers[index]); msg != nil {
+			name = msg.Name
+		}
+	}
+	if name == "" {
+		return nil
+	}
+
+	decoded, err := json.Marshal(map[string]interface{}{
+		"message": name,
+		"fields":  m.schema.Decode(name, input[2:]),
+	})
+	if err != nil {
+		return nil
+	}
+	return decoded
+}
+
+func (m *ProtoMutator) ValidateOutput(output []byte, err error) *FuzzResult {
+	return nil
+}
@@ -0,0 +1,358 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GoSec-Labs/StateStinger/utils/target/cosmossdk"
+)
+
+// MutatorEnv is what a mutator constructor can draw on
+type MutatorEnv struct {
+	Rand       *rand.Rand
+	Corpus     *Corpus  // nil unless coverage guidance is enabled
+	Dictionary [][]byte // Tokens from -dict files and the target, shared read-only
+	Target     *cosmossdk.CosmosModule
+	Prefix     string // Bech32 account prefix of the target chain
+	Params     map[string]string
+}
+
+// MutatorFactory builds one mutator instance for a worker
+type MutatorFactory func(env MutatorEnv) (StateMutator, error)
+
+// MutatorInfo describes a registered mutator
+type MutatorInfo struct {
+	Name        string
+	Description string
+	Params      map[string]string // Parameter name to description
+}
+
+type registeredMutator struct {
+	info    MutatorInfo
+	factory MutatorFactory
+}
+
+var mutatorRegistry = make(map[string]registeredMutator)
+
+// RegisterMutator makes a mutator selectable by name. It panics if the name
+// is already taken, as registration happens from init functions.
+func RegisterMutator(info MutatorInfo, factory MutatorFactory) {
+	if _, ok := mutatorRegistry[info.Name]; ok {
+		panic("engine: mutator registered twice: " + info.Name)
+	}
+	mutatorRegistry[info.Name] = registeredMutator{info: info, factory: factory}
+}
+
+// RegisteredMutators lists the available mutators sorted by name
+func RegisteredMutators() []MutatorInfo {
+	infos := make([]MutatorInfo, 0, len(mutatorRegistry))
+	for _, entry := range mutatorRegistry {
+		infos = append(infos, entry.info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// NewMutator builds the named mutator, rejecting parameters it does not know
+func NewMutator(name string, env MutatorEnv) (StateMutator, error) {
+	entry, ok := mutatorRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown mutator: %s", name)
+	}
+
+	for param := range env.Params {
+		if _, ok := entry.info.Params[param]; !ok {
+			return nil, fmt.Errorf("mutator %s has no parameter %q", name, param)
+		}
+	}
+
+	return entry.factory(env)
+}
+
+// defaultMutators is the selection used when Config.StateMutator is empty
+func defaultMutators(config Config) []string {
+	names := []string{"RandomTxMutator", "BoundaryValueMutator", "StatefulMutator", "CoinMutator"}
+	if config.SpecialCases {
+		names = append(names, "SpecialCasesMutator")
+	}
+	if config.Coverage {
+		names = append(names, "CorpusMutator", "HavocMutator")
+	}
+	return names
+}
+
+// parseMutatorSpec parses a -mutators value such as
+// "RandomTxMutator:maxlen=1024,StatefulMutator" into names and parameters.
+// -oracles values have the same form.
+func parseMutatorSpec(value string) ([]string, map[string]map[string]string, error) {
+	names := make([]string, 0)
+	params := make(map[string]map[string]string)
+
+	for _, spec := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(spec), ":")
+		name := parts[0]
+		if name == "" {
+			return nil, nil, fmt.Errorf("empty name in %q", value)
+		}
+		names = append(names, name)
+
+		for _, param := range parts[1:] {
+			key, val, ok := strings.Cut(param, "=")
+			if !ok {
+				return nil, nil, fmt.Errorf("expected key=value for %s, got %q", name, param)
+			}
+			if params[name] == nil {
+				params[name] = make(map[string]string)
+			}
+			params[name][key] = val
+		}
+	}
+
+	return names, params, nil
+}
+
+// paramInt reads an integer parameter, falling back to def when unset
+func paramInt(params map[string]string, key string, def int) (int, error) {
+	value, ok := params[key]
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("parameter %s: %v", key, err)
+	}
+	return n, nil
+}
+
+func init() {
+	RegisterMutator(MutatorInfo{
+		Name:        "RandomTxMutator",
+		Description: "Completely random transaction bytes",
+		Params: map[string]string{
+			"minlen": "Minimum input length in bytes (default 16)",
+			"maxlen": "Maximum input length in bytes, exclusive (default 4096)",
+		},
+	}, func(env MutatorEnv) (StateMutator, error) {
+		m := NewRandomTxMutator(env.Rand)
+		var err error
+		if m.minLen, err = paramInt(env.Params, "minlen", m.minLen); err != nil {
+			return nil, err
+		}
+		if m.maxLen, err = paramInt(env.Params, "maxlen", m.maxLen); err != nil {
+			return nil, err
+		}
+		if m.minLen < 0 || m.maxLen <= m.minLen {
+			return nil, fmt.Errorf("RandomTxMutator needs 0 <= minlen < maxlen")
+		}
+		return m, nil
+	})
+
+	RegisterMutator(MutatorInfo{
+		Name:        "BoundaryValueMutator",
+		Description: "Empty, single-byte, extreme integer and very large inputs",
+		Params: map[string]string{
+			"maxmb": "Upper bound of the large buffer case in MB (default 5)",
+		},
+	}, func(env MutatorEnv) (StateMutator, error) {
+		m := NewBoundaryValueMutator(env.Rand)
+		var err error
+		if m.maxBufferMB, err = paramInt(env.Params, "maxmb", m.maxBufferMB); err != nil {
+			return nil, err
+		}
+		if m.maxBufferMB < 1 {
+			return nil, fmt.Errorf("BoundaryValueMutator needs maxmb >= 1")
+		}
+		return m, nil
+	})
+
+	RegisterMutator(MutatorInfo{
+		Name:        "StatefulMutator",
+		Description: "Sequences of operations that carry earlier operations forward",
+		Params: map[string]string{
+			"depth": "Number of past operations kept in the sequence (default 64)",
+		},
+	}, func(env MutatorEnv) (StateMutator, error) {
+		m := NewStatefulMutator(env.Rand)
+		var err error
+		if m.depth, err = paramInt(env.Params, "depth", m.depth); err != nil {
+			return nil, err
+		}
+		if m.depth < 1 {
+			return nil, fmt.Errorf("StatefulMutator needs depth >= 1")
+		}
+		return m, nil
+	})
+
+	RegisterMutator(MutatorInfo{
+		Name:        "SpecialCasesMutator",
+		Description: "Known Cosmos SDK edge cases, used raw or lightly mutated",
+		Params: map[string]string{
+			"raw": "Percentage of cases used without mutation (default 75)",
+		},
+	}, func(env MutatorEnv) (StateMutator, error) {
+		m := NewSpecialCasesMutator(env.Rand)
+		var err error
+		if m.rawPercent, err = paramInt(env.Params, "raw", m.rawPercent); err != nil {
+			return nil, err
+		}
+		if m.rawPercent < 0 || m.rawPercent > 100 {
+			return nil, fmt.Errorf("SpecialCasesMutator needs 0 <= raw <= 100")
+		}
+		return m, nil
+	})
+
+	RegisterMutator(MutatorInfo{
+		Name:        "CorpusMutator",
+		Description: "Small mutations of coverage corpus entries (needs -coverage)",
+		Params: map[string]string{
+			"mutations": "Maximum mutations stacked per input (default 8)",
+		},
+	}, func(env MutatorEnv) (StateMutator, error) {
+		if env.Corpus == nil {
+			return nil, fmt.Errorf("CorpusMutator requires coverage-guided mode")
+		}
+		m := NewCorpusMutator(env.Rand, env.Corpus)
+		m.dictionary = env.Dictionary
+		var err error
+		if m.maxMutations, err = paramInt(env.Params, "mutations", m.maxMutations); err != nil {
+			return nil, err
+		}
+		if m.maxMutations < 1 {
+			return nil, fmt.Errorf("CorpusMutator needs mutations >= 1")
+		}
+		return m, nil
+	})
+
+	RegisterMutator(MutatorInfo{
+		Name:        "DictionaryMutator",
+		Description: "Random inputs with dictionary tokens spliced in (needs -dict or target tokens)",
+		Params: map[string]string{
+			"tokens": "Maximum tokens spliced into one input (default 4)",
+		},
+	}, func(env MutatorEnv) (StateMutator, error) {
+		m := NewDictionaryMutator(env.Rand, env.Dictionary)
+		var err error
+		if m.maxTokens, err = paramInt(env.Params, "tokens", m.maxTokens); err != nil {
+			return nil, err
+		}
+		if m.maxTokens < 1 {
+			return nil, fmt.Errorf("DictionaryMutator needs tokens >= 1")
+		}
+		return m, nil
+	})
+
+	RegisterMutator(MutatorInfo{
+		Name:        "HavocMutator",
+		Description: "Stacked bit, integer, block and splice mutations of corpus entries and special cases",
+		Params: map[string]string{
+			"mutations": "Maximum mutations stacked per input (default 16)",
+		},
+	}, func(env MutatorEnv) (StateMutator, error) {
+		m := NewHavocMutator(env.Rand, env.Corpus, env.Dictionary)
+		var err error
+		if m.maxMutations, err = paramInt(env.Params, "mutations", m.maxMutations); err != nil {
+			return nil, err
+		}
+		if m.maxMutations < 1 {
+			return nil, fmt.Errorf("HavocMutator needs mutations >= 1")
+		}
+		return m, nil
+	})
+
+	RegisterMutator(MutatorInfo{
+		Name:        "StructMutator",
+		Description: "Typed messages built from the struct schemas in the target's types/",
+		Params: map[string]string{
+			"maxslice": "Length of the longest slice boundary value (default 256)",
+			"boundary": "Percentage of fields given a boundary value (default 30)",
+		},
+	}, func(env MutatorEnv) (StateMutator, error) {
+		if env.Target == nil {
+			return nil, fmt.Errorf("StructMutator requires a target module")
+		}
+		m := NewStructMutator(env.Rand, env.Target, env.Dictionary, env.Prefix)
+		var err error
+		if m.maxSlice, err = paramInt(env.Params, "maxslice", m.maxSlice); err != nil {
+			return nil, err
+		}
+		if m.boundaryPercent, err = paramInt(env.Params, "boundary", m.boundaryPercent); err != nil {
+			return nil, err
+		}
+		if m.maxSlice < 1 || m.boundaryPercent < 0 || m.boundaryPercent > 100 {
+			return nil, fmt.Errorf("StructMutator needs maxslice >= 1 and 0 <= boundary <= 100")
+		}
+		return m, nil
+	})
+
+	RegisterMutator(MutatorInfo{
+		Name:        "ProtoMutator",
+		Description: "Protobuf messages from the module's .proto files, broken at the wire level",
+		Params: map[string]string{
+			"mutations": "Maximum wire mutations stacked per input (default 3)",
+			"valid":     "Percentage of inputs sent without wire mutations (default 20)",
+		},
+	}, func(env MutatorEnv) (StateMutator, error) {
+		if env.Target == nil || env.Target.Proto == nil {
+			return nil, fmt.Errorf("ProtoMutator requires .proto files in the target's proto/ directory")
+		}
+		m := NewProtoMutator(env.Rand, env.Target, env.Dictionary, env.Prefix)
+		var err error
+		if m.maxMutations, err = paramInt(env.Params, "mutations", m.maxMutations); err != nil {
+			return nil, err
+		}
+		if m.validPercent, err = paramInt(env.Params, "valid", m.validPercent); err != nil {
+			return nil, err
+		}
+		if m.maxMutations < 1 || m.validPercent < 0 || m.validPercent > 100 {
+			return nil, fmt.Errorf("ProtoMutator needs mutations >= 1 and 0 <= valid <= 100")
+		}
+		return m, nil
+	})
+
+	RegisterMutator(MutatorInfo{
+		Name:        "AddressMutator",
+		Description: "Valid, malformed and cross-prefix bech32 addresses",
+		Params: map[string]string{
+			"prefix": "Account prefix, valoper and valcons are derived from it (default -bech32-prefix)",
+			"valid":  "Percentage of well-formed addresses (default 30)",
+		},
+	}, func(env MutatorEnv) (StateMutator, error) {
+		prefix := env.Prefix
+		if p, ok := env.Params["prefix"]; ok {
+			prefix = p
+		}
+		m := NewAddressMutator(env.Rand, prefix)
+		var err error
+		if m.validPercent, err = paramInt(env.Params, "valid", m.validPercent); err != nil {
+			return nil, err
+		}
+		if m.validPercent < 0 || m.validPercent > 100 {
+			return nil, fmt.Errorf("AddressMutator needs 0 <= valid <= 100")
+		}
+		return m, nil
+	})
+
+	RegisterMutator(MutatorInfo{
+		Name:        "CoinMutator",
+		Description: "Boundary and malformed Coin and DecCoin values, flags handlers that accept them",
+		Params: map[string]string{
+			"valid": "Percentage of well-formed coin values (default 40)",
+		},
+	}, func(env MutatorEnv) (StateMutator, error) {
+		if env.Target == nil {
+			return nil, fmt.Errorf("CoinMutator requires a target module")
+		}
+		m := NewCoinMutator(env.Rand, env.Target, env.Dictionary, env.Prefix)
+		var err error
+		if m.validPercent, err = paramInt(env.Params, "valid", m.validPercent); err != nil {
+			return nil, err
+		}
+		if m.validPercent < 0 || m.validPercent > 100 {
+			return nil, fmt.Errorf("CoinMutator needs 0 <= valid <= 100")
+		}
+		return m, nil
+	})
+}
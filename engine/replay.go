@@ -0,0 +1,276 @@
+package engine
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoSec-Labs/StateStinger/utils/target/cosmossdk"
+)
+
+// Replay feeds a recorded finding back into the target and reports whether
+// the oracle that reported it still fails it with the same class. diff is
+// the second module of a differential run, nil otherwise. Findings that
+// need a harness, and oracles that start one, get the timeout and memory
+// limit of limits.
+func Replay(target, diff *cosmossdk.CosmosModule, result FuzzResult, limits HarnessConfig) (bool, error) {
+	if result.Hazard {
+		return false, fmt.Errorf("finding is a static hazard, it has no input to replay")
+	}
+	if result.FuzzTest != "" {
+		return false, fmt.Errorf("finding came from go test -fuzz, rerun it with %s", goFuzzCommand(result))
+	}
+	if result.Divergence && diff == nil {
+		return false, fmt.Errorf("finding is a divergence, replay it with -diff-target")
+	}
+
+	oracle, err := findingOracle(result, target, diff, limits)
+	if err != nil {
+		return false, err
+	}
+	if closer, ok := oracle.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	// Without its mutator the input itself can still be replayed, except to
+	// the mutator's own checks
+	mutator, err := findingMutator(result, target)
+	if err != nil {
+		if oracle.Name() == "mutator" {
+			return false, fmt.Errorf("finding came from the checks of mutator %s, which cannot be rebuilt: %v", result.Mutator, err)
+		}
+		log.Printf("Warning: Replaying the raw input, mutator %s cannot be rebuilt: %v", result.Mutator, err)
+	}
+	if closer, ok := mutator.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	steps := result.Steps
+	if len(steps) == 0 {
+		steps = [][]byte{result.Input}
+	}
+
+	// Hangs, OOMs and runtime panics, which may have killed the harness
+	// outright, only reproduce safely in one
+	var executor Executor = target
+	if result.Hang || result.OOM || result.RuntimePanic {
+		harness := NewHarness(HarnessConfig{
+			TargetPath:  target.Path,
+			ModuleName:  target.Name,
+			Timeout:     limits.Timeout,
+			MemoryLimit: limits.MemoryLimit,
+			SDKPanics:   target.SDKPanics,
+		})
+		defer harness.Close()
+		executor = harness
+	}
+
+	// The steps run on the store the worker had before the first of them,
+	// and only the last one has to be reported again
+	class := failureClass(result)
+	state, diffState := NewStateStore(), NewStateStore()
+	state.Restore(result.State)
+	diffState.Restore(result.DiffState)
+	var replayed *FuzzResult
+	for _, step := range steps {
+		execution := executor.ExecuteOn(step, state)
+		exec := Execution{
+			Input:   step,
+			Output:  execution.Output,
+			Err:     execution.Err,
+			Handler: execution.Handler,
+			Events:  execution.Events,
+			GasUsed: execution.GasUsed,
+			Panic:   execution.Panic,
+			State:   state.Apply(execution.Delta),
+			Mutator: mutator,
+		}
+		if diff != nil {
+			other := diff.ExecuteOn(step, diffState)
+			exec.Diff = &Execution{
+				Input:   step,
+				Output:  other.Output,
+				Err:     other.Err,
+				Handler: other.Handler,
+				Events:  other.Events,
+				GasUsed: other.GasUsed,
+				Panic:   other.Panic,
+				State:   diffState.Apply(other.Delta),
+				Mutator: mutator,
+			}
+		}
+
+		replayed = oracle.Check(exec)
+	}
+
+	return replayed != nil && failureClass(*replayed) == class, nil
+}
+
+// findingOracle rebuilds the oracle that reported a finding with the
+// parameters it was configured with, giving harness children it starts the
+// timeout and memory limit of limits
+func findingOracle(result FuzzResult, target, diff *cosmossdk.CosmosModule, limits HarnessConfig) (Oracle, error) {
+	// Findings from before oracles existed came from the mutator's checks
+	name := result.Oracle
+	if name == "" {
+		name = "mutator"
+	}
+
+	// A sampling oracle would skip the one execution being replayed
+	params := result.OracleParams
+	if entry, ok := oracleRegistry[name]; ok {
+		if _, samples := entry.info.Params["every"]; samples {
+			params = maps.Clone(params)
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params["every"] = "1"
+		}
+	}
+	return NewOracle(name, OracleEnv{Target: target, DiffTarget: diff, Params: params, Limits: limits})
+}
+
+// findingMutator rebuilds the mutator that generated a finding with the
+// parameters and prefix it ran with, starting the plugin that served it if
+// it is not registered yet
+func findingMutator(result FuzzResult, target *cosmossdk.CosmosModule) (StateMutator, error) {
+	if _, ok := mutatorRegistry[result.Mutator]; !ok && result.Plugin != "" {
+		if _, err := RegisterPlugin(result.Plugin, defaultPluginTimeout); err != nil {
+			return nil, err
+		}
+	}
+	// Findings from before the prefix was recorded ran with the default
+	prefix := result.Bech32Prefix
+	if prefix == "" {
+		prefix = defaultBech32Prefix
+	}
+	return NewMutator(result.Mutator, MutatorEnv{
+		Rand:       rand.New(rand.NewSource(result.Seed)),
+		Corpus:     NewCorpus(),
+		Dictionary: target.Dictionary,
+		Target:     target,
+		Prefix:     prefix,
+		Params:     result.MutatorParams,
+	})
+}
+
+// findingFiles expands a path into the recorded findings it refers to
+func findingFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(path, "failure_*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// runReplay implements `statestinger replay [flags] <failure.json|dir>...`
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	targetPath := fs.String("target", "", "Path to the Cosmos SDK module directory to replay against")
+	moduleName := fs.String("module", "", "Module name (default: the one recorded with each finding)")
+	diffPath := fs.String("diff-target", "", "Second module directory, needed to replay divergences")
+	execTimeout := fs.Duration("exec-timeout", defaultExecTimeout, "Time limit per input in a harness, when replaying hangs and OOMs or rerunning inputs")
+	memoryLimit := fs.Int("memory-limit", defaultMemoryLimit, "Memory limit in MB of a harness, when replaying hangs and OOMs or rerunning inputs")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: statestinger replay -target <module> <failure.json|dir>...\n")
+		fmt.Fprintf(fs.Output(), "Exits with status 1 if any finding still reproduces.\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *targetPath == "" || fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	var files []string
+	for _, arg := range fs.Args() {
+		found, err := findingFiles(arg)
+		if err != nil {
+			log.Fatalf("Failed to read findings: %v", err)
+		}
+		files = append(files, found...)
+	}
+
+	// Findings from one campaign share a module, load each one only once
+	targets := make(map[string]*cosmossdk.CosmosModule)
+	diffs := make(map[string]*cosmossdk.CosmosModule)
+	reproduced := 0
+
+	for _, filename := range files {
+		result, err := loadResult(filename)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+
+		name := *moduleName
+		if name == "" {
+			name = result.Module
+		}
+		if name == "" {
+			name = filepath.Base(*targetPath)
+		}
+
+		target, ok := targets[name]
+		if !ok {
+			target, err = cosmossdk.LoadCosmosModule(*targetPath, name)
+			if err != nil {
+				log.Fatalf("Failed to load target module: %v", err)
+			}
+			targets[name] = target
+		}
+
+		var diff *cosmossdk.CosmosModule
+		if *diffPath != "" {
+			if diff, ok = diffs[name]; !ok {
+				diff, err = cosmossdk.LoadCosmosModule(*diffPath, name)
+				if err != nil {
+					log.Fatalf("Failed to load diff target module: %v", err)
+				}
+				diffs[name] = diff
+			}
+		}
+
+		// Run the modules as the campaign did
+		target.SDKPanics = result.SDKPanics
+		if diff != nil {
+			diff.SDKPanics = result.SDKPanics
+		}
+
+		ok, err = Replay(target, diff, result, HarnessConfig{Timeout: *execTimeout, MemoryLimit: *memoryLimit})
+		if err != nil {
+			log.Printf("Warning: Cannot replay %s: %v", filename, err)
+			continue
+		}
+
+		status := "fixed"
+		if ok {
+			status = "REPRODUCED"
+			reproduced++
+		}
+		fmt.Printf("%-10s %s [%s, %s, iteration %d]\n", status, filepath.Base(filename),
+			result.Mutator, strings.ReplaceAll(failureClass(result), "_", " "), result.Iteration)
+	}
+
+	fmt.Printf("\n%d of %d findings reproduced\n", reproduced, len(files))
+	if reproduced > 0 {
+		os.Exit(1)
+	}
+}
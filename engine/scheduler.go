@@ -0,0 +1,292 @@
+package engine
+
+import (
+	"math/rand"
+	"slices"
+	"sync"
+)
+
+// explorationShare is the part of the probability mass the adaptive
+// scheduler spreads evenly, so no mutator is ever starved completely
+const explorationShare = 0.1
+
+// maxWeightSnapshots bounds the weight history kept for the run report
+const maxWeightSnapshots = 1000
+
+// WeightSnapshot records the mutator selection probabilities at one point in a run
+type WeightSnapshot struct {
+	Iteration int
+	Weights   map[string]float64
+}
+
+// Scheduler decides which mutator runs next. Without weights it picks
+// uniformly, static weights bias the choice, and in adaptive mode each
+// mutator's share also follows its yield of new coverage and unique findings.
+// It is shared by all workers, so every method is safe for concurrent use.
+// Workers pick through a ScheduleView of their own.
+type Scheduler struct {
+	mu       sync.Mutex
+	names    []string
+	static   []float64
+	disabled []bool // Mutators some worker took out of its selection
+	adaptive bool
+	pulls    []int
+	rewards  []int
+	history  []WeightSnapshot
+	interval int
+	next     int // Iteration of the next snapshot
+}
+
+// NewScheduler creates a scheduler for the mutators with the given names.
+// Mutators missing from static get a weight of 1.
+func NewScheduler(names []string, static map[string]float64, adaptive bool) *Scheduler {
+	s := &Scheduler{
+		names:    names,
+		static:   make([]float64, len(names)),
+		disabled: make([]bool, len(names)),
+		adaptive: adaptive,
+		pulls:    make([]int, len(names)),
+		rewards:  make([]int, len(names)),
+		interval: 1000,
+	}
+
+	for i, name := range names {
+		s.static[i] = 1
+		if weight, ok := static[name]; ok {
+			s.static[i] = weight
+		}
+	}
+
+	return s
+}
+
+// ScheduleView is a worker's copy of the scheduler's counters. It only
+// follows the scheduler when Sync is called at a round boundary, so the
+// mutators a worker picks never depend on how far the other workers got.
+type ScheduleView struct {
+	sched    *Scheduler
+	disabled []bool // Mutators this worker took out of its selection for good
+	pulls    []int
+	rewards  []int
+}
+
+// View creates a view of the scheduler for one worker
+func (s *Scheduler) View() *ScheduleView {
+	v := &ScheduleView{sched: s, disabled: make([]bool, len(s.names))}
+	v.Sync()
+	return v
+}
+
+// Sync brings the view up to date with the counters of the scheduler
+func (v *ScheduleView) Sync() {
+	v.sched.mu.Lock()
+	defer v.sched.mu.Unlock()
+
+	v.pulls = append(v.pulls[:0], v.sched.pulls...)
+	v.rewards = append(v.rewards[:0], v.sched.rewards...)
+}
+
+// uniform reports whether every mutator is equally likely at all times
+func (v *ScheduleView) uniform() bool {
+	if v.sched.adaptive {
+		return false
+	}
+	for i, weight := range v.sched.static {
+		if weight != v.sched.static[0] || v.disabled[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Disable takes a mutator out of the worker's selection, e.g. a plugin that
+// keeps crashing, so its iterations go to the other mutators
+func (v *ScheduleView) Disable(index int) {
+	v.disabled[index] = true
+
+	v.sched.mu.Lock()
+	defer v.sched.mu.Unlock()
+	v.sched.disabled[index] = true
+}
+
+// Pick chooses the index of the next mutator using the worker's RNG. It
+// returns -1 once every mutator is disabled.
+func (v *ScheduleView) Pick(r *rand.Rand) int {
+	// Keep the plain draw so runs without weights match earlier releases
+	if v.uniform() {
+		return r.Intn(len(v.sched.names))
+	}
+	if !slices.Contains(v.disabled, false) {
+		return -1
+	}
+
+	weights := v.sched.weights(v.disabled, v.pulls, v.rewards)
+	x := r.Float64()
+	last := -1
+	for i, weight := range weights {
+		if v.disabled[i] {
+			continue
+		}
+		x -= weight
+		if x < 0 {
+			return i
+		}
+		last = i
+	}
+	return last
+}
+
+// Update records one execution of a mutator and whether it paid off
+func (s *Scheduler) Update(index int, rewarded bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pulls[index]++
+	if rewarded {
+		s.rewards[index]++
+	}
+}
+
+// Reward records that an execution Update already counted paid off after all
+func (s *Scheduler) Reward(index int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rewards[index]++
+}
+
+// weightsLocked returns the normalized selection probabilities, zero for
+// mutators any worker disabled
+func (s *Scheduler) weightsLocked() []float64 {
+	return s.weights(s.disabled, s.pulls, s.rewards)
+}
+
+// weights returns the normalized selection probabilities for the given
+// counters, zero for disabled mutators
+func (s *Scheduler) weights(disabled []bool, pulls, rewards []int) []float64 {
+	weights := make([]float64, len(s.names))
+	active := 0
+	total := 0.0
+	for i := range weights {
+		if disabled[i] {
+			continue
+		}
+		active++
+		weights[i] = s.static[i]
+		if s.adaptive {
+			// Smoothed yield, so untried mutators start with an even chance
+			weights[i] *= float64(rewards[i]+1) / float64(pulls[i]+2)
+		}
+		total += weights[i]
+	}
+
+	if total <= 0 {
+		for i := range weights {
+			if !disabled[i] {
+				weights[i] = 1 / float64(active)
+			}
+		}
+		return weights
+	}
+
+	for i := range weights {
+		if disabled[i] {
+			continue
+		}
+		weights[i] /= total
+		if s.adaptive {
+			weights[i] = explorationShare/float64(active) + (1-explorationShare)*weights[i]
+		}
+	}
+	return weights
+}
+
+// Weights returns the current selection probability of each mutator by name
+func (s *Scheduler) Weights() map[string]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.weightMapLocked()
+}
+
+func (s *Scheduler) weightMapLocked() map[string]float64 {
+	weights := make(map[string]float64, len(s.names))
+	for i, weight := range s.weightsLocked() {
+		weights[s.names[i]] = weight
+	}
+	return weights
+}
+
+// Snapshot appends the current weights to the history if iteration has
+// reached the next snapshot point, or always if force is set. Once the
+// history is full every other snapshot is dropped and the interval doubles,
+// so long runs stay compact.
+func (s *Scheduler) Snapshot(iteration int, force bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if iteration < s.next && !force {
+		return
+	}
+	if n := len(s.history); n > 0 && s.history[n-1].Iteration == iteration {
+		return
+	}
+
+	s.history = append(s.history, WeightSnapshot{Iteration: iteration, Weights: s.weightMapLocked()})
+	if len(s.history) >= maxWeightSnapshots {
+		thinned := s.history[:0]
+		for i := 0; i < len(s.history); i += 2 {
+			thinned = append(thinned, s.history[i])
+		}
+		s.history = thinned
+		s.interval *= 2
+	}
+	s.next = iteration + s.interval
+}
+
+// History returns the recorded weight snapshots
+func (s *Scheduler) History() []WeightSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]WeightSnapshot(nil), s.history...)
+}
+
+// schedulerState is the checkpointed form of a Scheduler
+type schedulerState struct {
+	Pulls    []int
+	Rewards  []int
+	History  []WeightSnapshot
+	Interval int
+	Next     int
+}
+
+func (s *Scheduler) state() schedulerState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return schedulerState{
+		Pulls:    append([]int(nil), s.pulls...),
+		Rewards:  append([]int(nil), s.rewards...),
+		History:  append([]WeightSnapshot(nil), s.history...),
+		Interval: s.interval,
+		Next:     s.next,
+	}
+}
+
+func (s *Scheduler) restore(state schedulerState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A changed mutator set invalidates the counters, start over in that case
+	if len(state.Pulls) != len(s.names) || len(state.Rewards) != len(s.names) {
+		return
+	}
+	copy(s.pulls, state.Pulls)
+	copy(s.rewards, state.Rewards)
+	s.history = state.History
+	if state.Interval > 0 {
+		s.interval = state.Interval
+	}
+	s.next = state.Next
+}
@@ -0,0 +1,287 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/GoSec-Labs/StateStinger/utils/target/cosmossdk"
+)
+
+// StateStore is a worker's view of the target's store, built up from the
+// writes of the executions the worker has run
+type StateStore struct {
+	values map[string][]byte
+	hash   [sha256.Size]byte // XOR of the entry hashes, kept up to date on every write
+}
+
+func NewStateStore() *StateStore {
+	return &StateStore{values: make(map[string][]byte)}
+}
+
+// priorValue is the value a key had before an execution wrote to it
+type priorValue struct {
+	value []byte
+	ok    bool
+}
+
+// StateView shows the store before and after one execution. It is only
+// valid until the next execution is applied.
+type StateView struct {
+	store *StateStore
+	prior map[string]priorValue
+	Delta []cosmossdk.StateChange
+}
+
+// Apply writes delta to the store and returns the view around it
+func (s *StateStore) Apply(delta []cosmossdk.StateChange) *StateView {
+	view := &StateView{store: s, Delta: delta}
+	if len(delta) == 0 {
+		return view
+	}
+
+	view.prior = make(map[string]priorValue, len(delta))
+	for _, change := range delta {
+		if _, seen := view.prior[change.Key]; !seen {
+			value, ok := s.values[change.Key]
+			view.prior[change.Key] = priorValue{value: value, ok: ok}
+		}
+		if old, ok := s.values[change.Key]; ok {
+			s.mix(change.Key, old)
+		}
+		if change.Value == nil {
+			delete(s.values, change.Key)
+		} else {
+			s.values[change.Key] = change.Value
+			s.mix(change.Key, change.Value)
+		}
+	}
+	return view
+}
+
+// mix adds an entry to the store hash, or removes it if it was added before
+func (s *StateStore) mix(key string, value []byte) {
+	h := sha256.New()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write(value)
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	for i := range s.hash {
+		s.hash[i] ^= sum[i]
+	}
+}
+
+// Hash identifies the contents of the store independently of the order
+// they were written in
+func (s *StateStore) Hash() string {
+	return hex.EncodeToString(s.hash[:])
+}
+
+// Hash returns the hash of the store after the execution
+func (v *StateView) Hash() string {
+	return v.store.Hash()
+}
+
+// WritesHash identifies the writes of the execution alone, so two targets
+// can be compared even after their stores have drifted apart
+func (v *StateView) WritesHash() string {
+	h := sha256.New()
+	for _, change := range v.Delta {
+		h.Write([]byte(change.Key))
+		if change.Value == nil {
+			h.Write([]byte{1})
+			continue
+		}
+		h.Write([]byte{0})
+		h.Write(change.Value)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Before returns the value of key before the execution
+func (v *StateView) Before(key string) ([]byte, bool) {
+	if prior, ok := v.prior[key]; ok {
+		return prior.value, prior.ok
+	}
+	return v.After(key)
+}
+
+// After returns the value of key after the execution
+func (v *StateView) After(key string) ([]byte, bool) {
+	value, ok := v.store.values[key]
+	return value, ok
+}
+
+// Get returns the current value of key, which makes the store the one the
+// target's handlers read
+func (s *StateStore) Get(key string) ([]byte, bool) {
+	value, ok := s.values[key]
+	return value, ok
+}
+
+// priorStore reads the store of a view as it was before the execution
+type priorStore struct {
+	view *StateView
+}
+
+func (p priorStore) Get(key string) ([]byte, bool) {
+	return p.view.Before(key)
+}
+
+// Prior returns the store as the execution found it, to run an input again
+// on the same state
+func (v *StateView) Prior() cosmossdk.Store {
+	return priorStore{view: v}
+}
+
+// Touches reports whether the execution wrote any key with the given prefix
+func (v *StateView) Touches(prefix string) bool {
+	for _, change := range v.Delta {
+		if strings.HasPrefix(change.Key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Keys returns the keys with the given prefix after the execution, sorted
+func (v *StateView) Keys(prefix string) []string {
+	keys := make([]string, 0)
+	for key := range v.store.values {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Values returns a copy of the contents of the store, nil if it is empty
+func (s *StateStore) Values() map[string][]byte {
+	if len(s.values) == 0 {
+		return nil
+	}
+	values := make(map[string][]byte, len(s.values))
+	for key, value := range s.values {
+		values[key] = value
+	}
+	return values
+}
+
+// Restore replaces the contents of the store with a copy of values
+func (s *StateStore) Restore(values map[string][]byte) {
+	s.values = make(map[string][]byte, len(values))
+	s.hash = [sha256.Size]byte{}
+	for key, value := range values {
+		s.values[key] = value
+		s.mix(key, value)
+	}
+}
+
+func (s *StateStore) MarshalState() ([]byte, error) {
+	return json.Marshal(s.values)
+}
+
+func (s *StateStore) UnmarshalState(data []byte) error {
+	values := make(map[string][]byte)
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	s.Restore(values)
+	return nil
+}
+
+// historySteps bounds the number of executions a worker keeps to replay
+// its findings from
+const historySteps = 64
+
+// historyStep is one execution that wrote to a store
+type historyStep struct {
+	Input     []byte
+	Delta     []cosmossdk.StateChange
+	DiffDelta []cosmossdk.StateChange `json:",omitempty"`
+}
+
+// History keeps the last executions of a worker that wrote to its stores,
+// and the stores as they were before the first of them, so a finding can be
+// replayed on the state it was found on
+type History struct {
+	base     *StateStore
+	diffBase *StateStore
+	steps    []historyStep
+}
+
+func NewHistory() *History {
+	return &History{base: NewStateStore(), diffBase: NewStateStore()}
+}
+
+// Record adds an execution with its writes, and those of the -diff-target
+// module, to the history. Executions that wrote nothing left the stores as
+// they were and are not kept.
+func (h *History) Record(input []byte, delta, diffDelta []cosmossdk.StateChange) {
+	if len(delta) == 0 && len(diffDelta) == 0 {
+		return
+	}
+	h.steps = append(h.steps, historyStep{Input: input, Delta: delta, DiffDelta: diffDelta})
+	if len(h.steps) > historySteps {
+		oldest := h.steps[0]
+		h.base.Apply(oldest.Delta)
+		h.diffBase.Apply(oldest.DiffDelta)
+		h.steps = h.steps[1:]
+	}
+}
+
+// Steps returns the recorded inputs followed by input
+func (h *History) Steps(input []byte) [][]byte {
+	steps := make([][]byte, 0, len(h.steps)+1)
+	for _, step := range h.steps {
+		steps = append(steps, step.Input)
+	}
+	return append(steps, input)
+}
+
+// Len returns the number of recorded executions
+func (h *History) Len() int {
+	return len(h.steps)
+}
+
+// Base returns the store as it was before the first recorded execution
+func (h *History) Base() *StateStore {
+	return h.base
+}
+
+// DiffBase returns the store of the -diff-target module as it was before
+// the first recorded execution
+func (h *History) DiffBase() *StateStore {
+	return h.diffBase
+}
+
+// historyState is the checkpointed form of a History
+type historyState struct {
+	Base     map[string][]byte `json:",omitempty"`
+	DiffBase map[string][]byte `json:",omitempty"`
+	Steps    []historyStep
+}
+
+func (h *History) MarshalState() ([]byte, error) {
+	return json.Marshal(historyState{
+		Base:     h.base.values,
+		DiffBase: h.diffBase.values,
+		Steps:    h.steps,
+	})
+}
+
+func (h *History) UnmarshalState(data []byte) error {
+	var state historyState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	h.base.Restore(state.Base)
+	h.diffBase.Restore(state.DiffBase)
+	h.steps = state.Steps
+	return nil
+}
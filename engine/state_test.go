@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/GoSec-Labs/StateStinger/utils/target/cosmossdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setKey and deleteKey build store writes
+func setKey(key, value string) cosmossdk.StateChange {
+	return cosmossdk.StateChange{Key: key, Value: []byte(value)}
+}
+
+func deleteKey(key string) cosmossdk.StateChange {
+	return cosmossdk.StateChange{Key: key}
+}
+
+func TestStateStoreHash(t *testing.T) {
+	empty := NewStateStore().Hash()
+
+	tests := []struct {
+		name   string
+		a, b   [][]cosmossdk.StateChange // Deltas applied to two stores in order
+		equals bool
+	}{
+		{
+			name:   "write order",
+			a:      [][]cosmossdk.StateChange{{setKey("x", "1"), setKey("y", "2")}},
+			b:      [][]cosmossdk.StateChange{{setKey("y", "2")}, {setKey("x", "1")}},
+			equals: true,
+		},
+		{
+			name:   "overwrite",
+			a:      [][]cosmossdk.StateChange{{setKey("x", "1")}, {setKey("x", "2")}},
+			b:      [][]cosmossdk.StateChange{{setKey("x", "2")}},
+			equals: true,
+		},
+		{
+			name:   "delete",
+			a:      [][]cosmossdk.StateChange{{setKey("x", "1"), setKey("y", "2")}, {deleteKey("y")}},
+			b:      [][]cosmossdk.StateChange{{setKey("x", "1")}},
+			equals: true,
+		},
+		{
+			name:   "delete of a missing key",
+			a:      [][]cosmossdk.StateChange{{deleteKey("x")}},
+			b:      nil,
+			equals: true,
+		},
+		{
+			name: "different value",
+			a:    [][]cosmossdk.StateChange{{setKey("x", "1")}},
+			b:    [][]cosmossdk.StateChange{{setKey("x", "2")}},
+		},
+		{
+			name: "key and value boundary",
+			a:    [][]cosmossdk.StateChange{{setKey("ab", "c")}},
+			b:    [][]cosmossdk.StateChange{{setKey("a", "bc")}},
+		},
+		{
+			name: "empty value is not a delete",
+			a:    [][]cosmossdk.StateChange{{setKey("x", "")}},
+			b:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := NewStateStore(), NewStateStore()
+			for _, delta := range tt.a {
+				a.Apply(delta)
+			}
+			for _, delta := range tt.b {
+				b.Apply(delta)
+			}
+			if tt.equals {
+				assert.Equal(t, a.Hash(), b.Hash())
+			} else {
+				assert.NotEqual(t, a.Hash(), b.Hash())
+			}
+
+			// The hash is rebuilt from the contents alone on restore
+			restored := NewStateStore()
+			data, err := a.MarshalState()
+			require.NoError(t, err)
+			require.NoError(t, restored.UnmarshalState(data))
+			assert.Equal(t, a.Hash(), restored.Hash())
+		})
+	}
+
+	cleared := NewStateStore()
+	cleared.Apply([]cosmossdk.StateChange{setKey("x", "1")})
+	cleared.Apply([]cosmossdk.StateChange{deleteKey("x")})
+	assert.Equal(t, empty, cleared.Hash())
+}
+
+func TestStateViewPrior(t *testing.T) {
+	store := NewStateStore()
+	store.Apply([]cosmossdk.StateChange{setKey("kept", "1"), setKey("changed", "1"), setKey("deleted", "1")})
+	view := store.Apply([]cosmossdk.StateChange{setKey("changed", "2"), setKey("changed", "3"), deleteKey("deleted"), setKey("added", "1")})
+
+	tests := []struct {
+		key         string
+		before      string
+		beforeFound bool
+		after       string
+		afterFound  bool
+	}{
+		{"kept", "1", true, "1", true},
+		{"changed", "1", true, "3", true},
+		{"deleted", "1", true, "", false},
+		{"added", "", false, "1", true},
+		{"missing", "", false, "", false},
+	}
+
+	prior := view.Prior()
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			value, ok := view.Before(tt.key)
+			assert.Equal(t, tt.beforeFound, ok)
+			assert.Equal(t, tt.before, string(value))
+
+			value, ok = prior.Get(tt.key)
+			assert.Equal(t, tt.beforeFound, ok)
+			assert.Equal(t, tt.before, string(value))
+
+			value, ok = view.After(tt.key)
+			assert.Equal(t, tt.afterFound, ok)
+			assert.Equal(t, tt.after, string(value))
+		})
+	}
+
+	assert.True(t, view.Touches("chan"))
+	assert.False(t, view.Touches("kept"))
+	assert.Equal(t, []string{"added", "changed", "kept"}, view.Keys(""))
+}
+
+func TestHistory(t *testing.T) {
+	tests := []struct {
+		name  string
+		execs int
+		steps int // Steps kept before the next input
+		base  int // Keys in the base store
+	}{
+		{"empty", 0, 0, 0},
+		{"within the window", 10, 10, 0},
+		{"full window", historySteps, historySteps, 0},
+		{"past the window", historySteps + 5, historySteps, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := NewHistory()
+			store := NewStateStore()
+			for i := range tt.execs {
+				delta := []cosmossdk.StateChange{setKey(fmt.Sprint(i), "v")}
+				store.Apply(delta)
+				history.Record([]byte{byte(i)}, delta, nil)
+
+				// Executions that wrote nothing are left out
+				history.Record([]byte("read only"), nil, nil)
+			}
+
+			steps := history.Steps([]byte("next"))
+			require.Len(t, steps, tt.steps+1)
+			assert.Equal(t, []byte("next"), steps[tt.steps])
+			assert.Len(t, history.Base().Values(), tt.base)
+
+			// Replaying the steps on the base rebuilds the store
+			replayed := NewStateStore()
+			replayed.Restore(history.Base().Values())
+			for i := range tt.steps {
+				replayed.Apply([]cosmossdk.StateChange{setKey(fmt.Sprint(tt.execs-tt.steps+i), "v")})
+				assert.Equal(t, []byte{byte(tt.execs - tt.steps + i)}, steps[i])
+			}
+			assert.Equal(t, store.Hash(), replayed.Hash())
+
+			data, err := history.MarshalState()
+			require.NoError(t, err)
+			restored := NewHistory()
+			require.NoError(t, restored.UnmarshalState(data))
+			assert.Equal(t, steps, restored.Steps([]byte("next")))
+			assert.Equal(t, history.Base().Hash(), restored.Base().Hash())
+		})
+	}
+}
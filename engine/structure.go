@@ -0,0 +1,427 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"strings"
+
+	"github.com/GoSec-Labs/StateStinger/utils/target/cosmossdk"
+)
+
+/*
+Structured inputs keep the layout the target expects from every input: the
+first byte selects the handler and the second the simulated outcome. They
+are followed by the JSON encoding of a message built from the schema of the
+handler's Msg type, or of a random state type if the handler has none.
+*/
+
+// maxStructDepth limits how deep nested messages are generated
+const maxStructDepth = 4
+
+// maxStringLength is the long-string boundary value
+const maxStringLength = 4096
+
+var (
+	two256     = new(big.Int).Lsh(big.NewInt(1), 256)
+	maxUint256 = new(big.Int).Sub(two256, big.NewInt(1))
+)
+
+// StructMutator generates and mutates typed messages from the schemas of
+// the target's types/ package
+type StructMutator struct {
+	BaseMutator
+	target          *cosmossdk.CosmosModule
+	dictionary      [][]byte
+	addresses       *AddressGenerator
+	maxSlice        int
+	boundaryPercent int
+	last            map[string][]byte // Last message generated per type, to mutate further
+}
+
+func NewStructMutator(r *rand.Rand, target *cosmossdk.CosmosModule, dictionary [][]byte, prefix string) *StructMutator {
+	return &StructMutator{
+		BaseMutator: BaseMutator{
+			rand: r,
+			name: "StructMutator",
+		},
+		target:          target,
+		dictionary:      dictionary,
+		addresses:       NewAddressGenerator(r, prefix),
+		maxSlice:        256,
+		boundaryPercent: 30,
+		last:            make(map[string][]byte),
+	}
+}
+
+func (m *StructMutator) GenerateFuzzInput() []byte {
+	handler := 0
+	if len(m.target.Handlers) > 0 {
+		handler = m.rand.Intn(len(m.target.Handlers))
+	}
+	input := []byte{byte(handler), byte(m.rand.Intn(256))}
+
+	var schema *cosmossdk.StructSchema
+	if len(m.target.Handlers) > 0 {
+		schema = m.target.MessageSchema(m.target.Handlers[handler])
+	}
+	if schema == nil && len(m.target.Schemas) > 0 {
+		schema = &m.target.Schemas[m.rand.Intn(len(m.target.Schemas))]
+	}
+	if schema == nil {
+		return append(input, "{}"...)
+	}
+
+	// Half the time keep working on the previous message of this type
+	var value interface{}
+	if last, ok := m.last[schema.Name]; ok && m.rand.Intn(2) == 0 {
+		decoder := json.NewDecoder(bytes.NewReader(last))
+		decoder.UseNumber()
+		if decoder.Decode(&value) == nil {
+			value = m.mutateMessage(schema, value, 0)
+		}
+	}
+	if value == nil {
+		value = m.generateMessage(schema, 0, false)
+	}
+
+	payload, err := json.Marshal(value)
+	if err != nil {
+		payload = []byte("{}")
+	}
+	m.last[schema.Name] = payload
+
+	return append(input, payload...)
+}
+
+// boundary decides whether the next value is a boundary value
+func (m *StructMutator) boundary() bool {
+	return m.rand.Intn(100) < m.boundaryPercent
+}
+
+// generateMessage builds an object with every field of schema set
+func (m *StructMutator) generateMessage(schema *cosmossdk.StructSchema, depth int, boundary bool) map[string]interface{} {
+	message := make(map[string]interface{}, len(schema.Fields))
+	for _, field := range schema.Fields {
+		// Boundary messages leave some fields out entirely
+		if boundary && m.rand.Intn(4) == 0 {
+			continue
+		}
+		message[field.JSONName] = m.fieldValue(field, depth, m.boundary())
+	}
+	return message
+}
+
+// fieldValue generates a value for a field. String fields named like
+// addresses get a bech32 address, a confused one if boundary is set.
+func (m *StructMutator) fieldValue(field cosmossdk.FieldSchema, depth int, boundary bool) interface{} {
+	if role, ok := addressFieldRole(field.Name); ok && field.Type.Kind == cosmossdk.KindString {
+		if boundary {
+			return m.addresses.Confused(role)
+		}
+		return m.addresses.Valid(role)
+	}
+	return m.generateValue(field.Type, depth, boundary)
+}
+
+// mutateMessage changes one field of a decoded message, descending into
+// nested messages and slices now and then
+func (m *StructMutator) mutateMessage(schema *cosmossdk.StructSchema, value interface{}, depth int) interface{} {
+	message, ok := value.(map[string]interface{})
+	if !ok || len(schema.Fields) == 0 {
+		return m.generateMessage(schema, depth, true)
+	}
+
+	field := schema.Fields[m.rand.Intn(len(schema.Fields))]
+	if _, ok := addressFieldRole(field.Name); ok && field.Type.Kind == cosmossdk.KindString {
+		message[field.JSONName] = m.fieldValue(field, depth+1, m.rand.Intn(2) == 0)
+		return message
+	}
+	message[field.JSONName] = m.mutateValue(field.Type, message[field.JSONName], depth+1)
+	return message
+}
+
+func (m *StructMutator) mutateValue(t *cosmossdk.TypeSchema, value interface{}, depth int) interface{} {
+	switch t.Kind {
+	case cosmossdk.KindMessage:
+		if nested := m.target.Schema(t.Message); nested != nil && depth < maxStructDepth && m.rand.Intn(2) == 0 {
+			return m.mutateMessage(nested, value, depth)
+		}
+	case cosmossdk.KindSlice:
+		elems, ok := value.([]interface{})
+		if !ok || len(elems) == 0 {
+			break
+		}
+		switch m.rand.Intn(4) {
+		case 0:
+			// Change one element
+			i := m.rand.Intn(len(elems))
+			elems[i] = m.mutateValue(t.Elem, elems[i], depth+1)
+			return elems
+		case 1:
+			// Append a new element
+			return append(elems, m.generateValue(t.Elem, depth+1, m.boundary()))
+		case 2:
+			// Drop an element
+			i := m.rand.Intn(len(elems))
+			return append(elems[:i], elems[i+1:]...)
+		}
+	}
+
+	return m.generateValue(t, depth, m.rand.Intn(2) == 0)
+}
+
+// generateValue builds a value of type t, a boundary value if boundary is set
+func (m *StructMutator) generateValue(t *cosmossdk.TypeSchema, depth int, boundary bool) interface{} {
+	if t.Nullable && boundary && m.rand.Intn(4) == 0 {
+		return nil
+	}
+
+	switch t.Kind {
+	case cosmossdk.KindString:
+		return m.stringValue(boundary)
+	case cosmossdk.KindBool:
+		return m.rand.Intn(2) == 0
+	case cosmossdk.KindInt:
+		return m.intValue(t.Bits, true, boundary)
+	case cosmossdk.KindUint:
+		return m.intValue(t.Bits, false, boundary)
+	case cosmossdk.KindFloat:
+		return m.floatValue(boundary)
+	case cosmossdk.KindBytes:
+		return m.bytesValue(boundary)
+	case cosmossdk.KindBigInt:
+		return m.bigIntValue(true, boundary)
+	case cosmossdk.KindBigUint:
+		return m.bigIntValue(false, boundary)
+	case cosmossdk.KindDec:
+		return m.decValue(boundary)
+	case cosmossdk.KindMessage:
+		nested := m.target.Schema(t.Message)
+		if nested == nil || depth >= maxStructDepth {
+			return map[string]interface{}{}
+		}
+		return m.generateMessage(nested, depth+1, boundary)
+	case cosmossdk.KindSlice:
+		return m.sliceValue(t.Elem, depth, boundary)
+	case cosmossdk.KindMap:
+		values := make(map[string]interface{})
+		for i := m.rand.Intn(4); i > 0; i-- {
+			values[m.stringValue(boundary)] = m.generateValue(t.Elem, depth+1, m.boundary())
+		}
+		return values
+	}
+
+	// Nothing is known about opaque types, try the JSON shapes in turn
+	switch m.rand.Intn(4) {
+	case 0:
+		return nil
+	case 1:
+		return map[string]interface{}{}
+	case 2:
+		return []interface{}{}
+	}
+	return m.stringValue(boundary)
+}
+
+func (m *StructMutator) stringValue(boundary bool) string {
+	if len(m.dictionary) > 0 && m.rand.Intn(4) == 0 {
+		return string(m.dictionary[m.rand.Intn(len(m.dictionary))])
+	}
+
+	if boundary {
+		cases := []string{
+			"",
+			" ",
+			"\x00",
+			strings.Repeat("a", maxStringLength),
+			"\u202e\u00e9\U0001F600",
+			"-1",
+			"../../",
+			"%s%n",
+		}
+		return cases[m.rand.Intn(len(cases))]
+	}
+
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	s := make([]byte, 1+m.rand.Intn(32))
+	for i := range s {
+		s[i] = alphabet[m.rand.Intn(len(alphabet))]
+	}
+	return string(s)
+}
+
+// intValue returns an integer of the given width as a JSON number. Boundary
+// values include one past either end of the range.
+func (m *StructMutator) intValue(bits int, signed bool, boundary bool) json.Number {
+	lo, hi := new(big.Int), new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	if signed {
+		hi.Rsh(hi, 1)
+		lo.Neg(hi)
+	}
+	hi.Sub(hi, big.NewInt(1))
+
+	if boundary {
+		cases := []*big.Int{
+			big.NewInt(0),
+			big.NewInt(1),
+			big.NewInt(-1),
+			lo,
+			hi,
+			new(big.Int).Sub(lo, big.NewInt(1)),
+			new(big.Int).Add(hi, big.NewInt(1)),
+		}
+		return json.Number(cases[m.rand.Intn(len(cases))].String())
+	}
+
+	// Mostly small values, which get past range checks
+	span := new(big.Int).Sub(hi, lo)
+	if m.rand.Intn(2) == 0 && span.Cmp(big.NewInt(1000)) > 0 {
+		span = big.NewInt(1000)
+		if signed {
+			lo = big.NewInt(-500)
+		}
+	}
+	value := new(big.Int).Rand(m.rand, new(big.Int).Add(span, big.NewInt(1)))
+	return json.Number(value.Add(value, lo).String())
+}
+
+func (m *StructMutator) floatValue(boundary bool) json.Number {
+	if boundary {
+		cases := []string{"0", "-1", "1e308", "-1e308", "5e-324", "1e309"}
+		return json.Number(cases[m.rand.Intn(len(cases))])
+	}
+	return json.Number(fmt.Sprintf("%g", (m.rand.Float64()-0.5)*1e6))
+}
+
+// bytesValue returns bytes in the base64 form encoding/json uses for []byte
+func (m *StructMutator) bytesValue(boundary bool) interface{} {
+	var b []byte
+	if boundary {
+		switch m.rand.Intn(4) {
+		case 0:
+			return nil
+		case 1:
+			b = []byte{}
+		case 2:
+			b = []byte{0}
+		default:
+			b = bytes.Repeat([]byte{0xFF}, 256)
+		}
+	} else {
+		b = make([]byte, 1+m.rand.Intn(64))
+		m.rand.Read(b)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// bigIntValue returns a math.Int or math.Uint in its JSON string form.
+// Boundary values sit at the 256-bit limits the SDK enforces and include
+// strings the SDK parser must reject.
+func (m *StructMutator) bigIntValue(signed bool, boundary bool) string {
+	if boundary {
+		cases := []string{
+			"0",
+			"-1",
+			maxUint256.String(),
+			two256.String(),
+			"",
+			"0x10",
+			"1e18",
+			" 1",
+		}
+		if signed {
+			cases = append(cases,
+				new(big.Int).Neg(maxUint256).String(),
+				new(big.Int).Neg(two256).String())
+		}
+		return cases[m.rand.Intn(len(cases))]
+	}
+
+	value := new(big.Int).Rand(m.rand, big.NewInt(1<<62))
+	if m.rand.Intn(4) == 0 {
+		// Amounts beyond 64 bits
+		value.Rand(m.rand, maxUint256)
+	}
+	if signed && m.rand.Intn(8) == 0 {
+		value.Neg(value)
+	}
+	return value.String()
+}
+
+// decValue returns a math.LegacyDec in its JSON string form, which has 18
+// decimal places
+func (m *StructMutator) decValue(boundary bool) string {
+	if boundary {
+		cases := []string{
+			"0.000000000000000000",
+			"-1.000000000000000000",
+			"-0.000000000000000001",
+			"0.000000000000000001",
+			"1.0000000000000000001",
+			maxUint256.String() + ".000000000000000000",
+			"",
+			".5",
+			"1.",
+			"1e18",
+		}
+		return cases[m.rand.Intn(len(cases))]
+	}
+
+	whole := m.rand.Int63n(1 << 40)
+	frac := fmt.Sprintf("%018d", m.rand.Int63n(1_000_000_000_000_000_000))
+	sign := ""
+	if m.rand.Intn(8) == 0 {
+		sign = "-"
+	}
+	return fmt.Sprintf("%s%d.%s", sign, whole, frac)
+}
+
+func (m *StructMutator) sliceValue(elem *cosmossdk.TypeSchema, depth int, boundary bool) interface{} {
+	n := m.rand.Intn(5)
+	if boundary {
+		switch m.rand.Intn(4) {
+		case 0:
+			return nil
+		case 1:
+			n = 0
+		case 2:
+			n = 1
+		default:
+			n = m.maxSlice
+		}
+	}
+
+	// Every element is generated on its own, sharing no maps or slices with
+	// the others, so mutating one leaves the rest as they are
+	elems := make([]interface{}, n)
+	for i := range elems {
+		elems[i] = m.generateValue(elem, depth+1, m.boundary())
+	}
+	return elems
+}
+
+// DecodeInput returns the JSON message of a structured input
+func (m *StructMutator) DecodeInput(input []byte) json.RawMessage {
+	if len(input) < 3 || !json.Valid(input[2:]) {
+		return nil
+	}
+	return json.RawMessage(input[2:])
+}
+
+// MarshalState saves the last message of each type so mutation chains
+// continue after a resume
+func (m *StructMutator) MarshalState() ([]byte, error) {
+	return json.Marshal(m.last)
+}
+
+func (m *StructMutator) UnmarshalState(data []byte) error {
+	return json.Unmarshal(data, &m.last)
+}
+
+func (m *StructMutator) ValidateOutput(output []byte, err error) *FuzzResult {
+	return nil
+}
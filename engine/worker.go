@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/GoSec-Labs/StateStinger/utils/target/cosmossdk"
+)
+
+// fuzzWorker runs a share of the campaign's iterations with its own RNG and mutators
+type fuzzWorker struct {
+	id        int
+	seed      int64 // Campaign seed, recorded with every finding
+	rand      *rand.Rand
+	exec      Executor // The target itself, or a harness running it in a child process
+	mutators  []StateMutator
+	oracles   []Oracle
+	params    map[string]map[string]string // Oracle parameters by oracle name
+	mutParams map[string]map[string]string // Mutator parameters by mutator name
+	prefix    string                       // Bech32 account prefix the mutators were built with
+	state     *StateStore                  // Store as built up by this worker's executions
+	diff      Executor                     // Module of a differential run, or a harness running it, nil if there is none
+	diffState *StateStore
+	history   *History    // Recent executions that wrote to the stores, to replay findings from
+	corpus    *CorpusView // nil unless coverage guidance is enabled
+	schedule  *ScheduleView
+}
+
+// iterationOutcome is what a worker hands to the collector after each iteration
+type iterationOutcome struct {
+	worker      int
+	iteration   int
+	mutator     int // Index of the mutator in the worker's list
+	newCoverage bool
+	result      *FuzzResult
+}
+
+// workerSeed derives the RNG seed of a worker from the campaign seed.
+// Worker 0 keeps the campaign seed so single-worker runs are unchanged.
+func workerSeed(seed int64, id int) int64 {
+	if id == 0 {
+		return seed
+	}
+
+	// splitmix64 finalizer, spreads neighbouring worker ids far apart
+	z := uint64(seed) + uint64(id)*0x9E3779B97F4A7C15
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return int64(z ^ (z >> 31))
+}
+
+// roundSeed derives the seed a worker is reseeded with at the start of a
+// checkpoint round. Round 0 keeps the worker seed.
+func roundSeed(seed int64, round int) int64 {
+	if round == 0 {
+		return seed
+	}
+	return workerSeed(seed, round)
+}
+
+// generate picks a mutator and has it build the next input. Mutators that
+// gave up are taken out of the schedule and another one is picked, so their
+// failures are not executed as inputs. It returns -1 if none is left.
+func (w *fuzzWorker) generate() (int, []byte) {
+	for {
+		index := w.schedule.Pick(w.rand)
+		if index < 0 {
+			return -1, nil
+		}
+		input := w.mutators[index].GenerateFuzzInput()
+		if d, ok := w.mutators[index].(Disabler); ok && d.Disabled() {
+			log.Printf("Warning: Removing mutator %s from the schedule", w.mutators[index].Name())
+			w.schedule.Disable(index)
+			continue
+		}
+		return index, input
+	}
+}
+
+// run executes the iterations in [start, end) that are congruent to the
+// worker id modulo stride, without an upper bound if end is not positive,
+// until ctx is cancelled. The fixed assignment keeps findings reproducible
+// for a given seed and worker count.
+func (w *fuzzWorker) run(ctx context.Context, target *cosmossdk.CosmosModule, start, end, stride int, outcomes chan<- iterationOutcome) {
+	first := start + ((w.id-start%stride)+stride)%stride
+	for i := first; end <= 0 || i < end; i += stride {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Choose the next mutator and generate fuzz input
+		index, input := w.generate()
+		if index < 0 {
+			log.Printf("Warning: Worker %d stopping, every mutator is disabled", w.id)
+			return
+		}
+		mutator := w.mutators[index]
+
+		// Execute on target
+		start := time.Now()
+		execution := w.exec.ExecuteOn(input, w.state)
+		elapsed := time.Since(start)
+		view := w.state.Apply(execution.Delta)
+
+		// Keep inputs that reached new edges
+		newCoverage := false
+		if w.corpus != nil {
+			newCoverage = w.corpus.AddIfNew(input, execution.Edges)
+		}
+
+		exec := Execution{
+			Input:    input,
+			Output:   execution.Output,
+			Err:      execution.Err,
+			Handler:  execution.Handler,
+			Events:   execution.Events,
+			GasUsed:  execution.GasUsed,
+			Panic:    execution.Panic,
+			Duration: elapsed,
+			State:    view,
+			Mutator:  mutator,
+		}
+
+		// Differential runs send the same input to the second module
+		if w.diff != nil {
+			start := time.Now()
+			other := w.diff.ExecuteOn(input, w.diffState)
+			exec.Diff = &Execution{
+				Input:    input,
+				Output:   other.Output,
+				Err:      other.Err,
+				Handler:  other.Handler,
+				Events:   other.Events,
+				GasUsed:  other.GasUsed,
+				Panic:    other.Panic,
+				Duration: time.Since(start),
+				State:    w.diffState.Apply(other.Delta),
+				Mutator:  mutator,
+			}
+		}
+
+		// Let the oracles judge the execution
+		result := checkOracles(w.oracles, exec)
+		if result != nil {
+			// Record where the finding came from so it can be replayed
+			result.Mutator = mutator.Name()
+			result.OracleParams = w.params[result.Oracle]
+			result.MutatorParams = w.mutParams[mutator.Name()]
+			result.Bech32Prefix = w.prefix
+			result.SDKPanics = target.SDKPanics
+			if plugin, ok := mutator.(*PluginMutator); ok {
+				result.Plugin = plugin.path
+			}
+			result.Module = target.Name
+			result.Seed = w.seed
+			result.Worker = w.id
+			result.Iteration = i
+			result.Handler = execution.Handler
+			if execution.Err != nil {
+				result.TargetError = execution.Err.Error()
+			}
+			attachPanic(result, execution.Panic)
+			if len(result.Input) == 0 {
+				result.Input = input
+			}
+			if w.history.Len() > 0 {
+				result.Steps = w.history.Steps(input)
+			}
+			result.State = w.history.Base().Values()
+			result.DiffState = w.history.DiffBase().Values()
+			if decoder, ok := mutator.(InputDecoder); ok && result.Decoded == nil {
+				result.Decoded = decoder.DecodeInput(input)
+			}
+		}
+
+		var diffDelta []cosmossdk.StateChange
+		if exec.Diff != nil {
+			diffDelta = exec.Diff.State.Delta
+		}
+		w.history.Record(input, execution.Delta, diffDelta)
+
+		if fm, ok := mutator.(FeedbackMutator); ok {
+			fm.Feedback(newCoverage || result != nil)
+		}
+		if observer, ok := mutator.(Observer); ok {
+			observer.Observe(Observation{
+				Input:  input,
+				Output: execution.Output,
+				Err:    execution.Err,
+				Delta:  execution.Delta,
+			})
+		}
+
+		outcomes <- iterationOutcome{
+			worker:      w.id,
+			iteration:   i,
+			mutator:     index,
+			newCoverage: newCoverage,
+			result:      result,
+		}
+	}
+}
@@ -0,0 +1,94 @@
+#!/bin/bash
+
+# StateStinger - invariant oracle reproduction
+# Usage: ./invariants.sh
+#
+# Builds minimal bank, staking and distribution modules, then replays two
+# findings against each with the matching invariant oracle: one whose last
+# step hits the simulated bookkeeping bug (outcome 3), which must reproduce,
+# and the same steps with correct books, which must not.
+
+cd "$(dirname "$0")/.." || exit 1
+
+WORK=$(mktemp -d)
+trap 'rm -rf "$WORK"' EXIT
+
+go build -o "$WORK/statestinger" ./cmd/statestinger || exit 1
+
+# module NAME HANDLER... creates a module whose keeper has the given handlers
+module() {
+    local name=$1
+    shift
+    mkdir -p "$WORK/$name/keeper" "$WORK/$name/types"
+    echo "package types" > "$WORK/$name/types/types.go"
+    echo "package keeper" > "$WORK/$name/keeper/msg_server.go"
+    for handler in "$@"; do
+        echo "func Handle$handler(ctx context.Context, msg *types.$handler) error { return nil }" >> "$WORK/$name/keeper/msg_server.go"
+    done
+}
+
+# step HANDLER OUTCOME JSON encodes one input for the simulated target
+step() {
+    { printf "\\x$(printf %02x "$1")\\x$(printf %02x "$2")"; printf '%s' "$3"; } | base64 -w0
+}
+
+# finding FILE MODULE ORACLE INVARIANT STEP... writes a finding to replay
+finding() {
+    local file=$1 name=$2 oracle=$3 invariant=$4
+    shift 4
+    local steps last
+    steps=$(printf '"%s",' "$@")
+    last=${!#}
+    cat > "$file" <<EOF
+{"ID": "$(basename "$file" .json)", "Mutator": "StructMutator", "Oracle": "$oracle", "Module": "$name",
+ "Input": "$last", "Steps": [${steps%,}], "Failed": true, "StateInconsistency": true, "Invariant": "$invariant"}
+EOF
+}
+
+# check MODULE ORACLE INVARIANT STEP... replays the steps with correct books
+# and again with the last one faulty
+check() {
+    local name=$1 oracle=$2 invariant=$3
+    shift 3
+    local steps=("$@")
+    local last=$((${#steps[@]} - 1))
+    local handler=${steps[$last]%%:*}
+    local msg=${steps[$last]#*:}
+    local encoded=()
+    for s in "${steps[@]:0:$last}"; do
+        encoded+=("$(step "${s%%:*}" 0 "${s#*:}")")
+    done
+
+    finding "$WORK/${name}_ok.json" "$name" "$oracle" "$invariant" "${encoded[@]}" "$(step "$handler" 0 "$msg")"
+    finding "$WORK/${name}_bug.json" "$name" "$oracle" "$invariant" "${encoded[@]}" "$(step "$handler" 3 "$msg")"
+
+    if ! "$WORK/statestinger" replay -target "$WORK/$name" "$WORK/${name}_ok.json"; then
+        echo "FAIL: $invariant fired on correct books"
+        FAILED=true
+    fi
+    if "$WORK/statestinger" replay -target "$WORK/$name" "$WORK/${name}_bug.json"; then
+        echo "FAIL: $invariant did not fire on the bookkeeping bug"
+        FAILED=true
+    fi
+}
+
+FAILED=false
+
+module bank MsgSend
+check bank bank bank/total-supply \
+    '0:{"from_address": "cosmos1a", "to_address": "cosmos1b", "amount": [{"denom": "stake", "amount": "5"}]}'
+
+module staking MsgCreateValidator MsgDelegate
+check staking staking staking/bonded-pool \
+    '0:{"validator_address": "cosmosvaloper1v", "value": {"denom": "stake", "amount": "100"}}' \
+    '1:{"delegator_address": "cosmos1d", "validator_address": "cosmosvaloper1v", "amount": {"denom": "stake", "amount": "5"}}'
+
+module distribution MsgSetWithdrawAddress MsgWithdrawDelegatorReward
+check distribution distribution distribution/nonnegative-outstanding \
+    '0:{"delegator_address": "cosmos1d", "validator_address": "cosmosvaloper1v"}' \
+    '1:{"delegator_address": "cosmos1d", "validator_address": "cosmosvaloper1v"}'
+
+if [ "$FAILED" = true ]; then
+    exit 1
+fi
+echo "Every invariant fired on the bookkeeping bug and only there."
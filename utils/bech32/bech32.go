@@ -0,0 +1,180 @@
+// Package bech32 implements the bech32 (BIP 173) and bech32m (BIP 350)
+// address formats used by Cosmos SDK chains
+package bech32
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Encoding selects the checksum variant
+type Encoding int
+
+const (
+	Bech32 Encoding = iota
+	Bech32m
+)
+
+// Charset holds the 32 data characters, indexed by the 5-bit value they encode
+const Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// checksumConst is what the polymod of a valid string evaluates to
+var checksumConst = map[Encoding]uint32{
+	Bech32:  1,
+	Bech32m: 0x2bc830a3,
+}
+
+// MaxLength is the longest string BIP 173 allows. The Cosmos SDK accepts
+// longer ones for 32-byte payloads, so Decode does not enforce it.
+const MaxLength = 90
+
+var charsetRev = func() [128]int8 {
+	var rev [128]int8
+	for i := range rev {
+		rev[i] = -1
+	}
+	for i, c := range Charset {
+		rev[c] = int8(i)
+	}
+	return rev
+}()
+
+func polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func hrpExpand(hrp string) []byte {
+	out := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]>>5)
+	}
+	out = append(out, 0)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]&31)
+	}
+	return out
+}
+
+// Checksum computes the six checksum characters, as 5-bit values, for an
+// hrp and data already converted to 5-bit groups
+func Checksum(hrp string, data []byte, enc Encoding) []byte {
+	values := append(hrpExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	mod := polymod(values) ^ checksumConst[enc]
+	out := make([]byte, 6)
+	for i := range out {
+		out[i] = byte(mod>>uint(5*(5-i))) & 31
+	}
+	return out
+}
+
+// EncodeGroups encodes 5-bit groups with the given hrp
+func EncodeGroups(hrp string, data []byte, enc Encoding) (string, error) {
+	hrp = strings.ToLower(hrp)
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, group := range append(append([]byte{}, data...), Checksum(hrp, data, enc)...) {
+		if group > 31 {
+			return "", fmt.Errorf("invalid 5-bit group %d", group)
+		}
+		sb.WriteByte(Charset[group])
+	}
+	return sb.String(), nil
+}
+
+// Encode converts 8-bit data to 5-bit groups and encodes it with hrp
+func Encode(hrp string, data []byte, enc Encoding) (string, error) {
+	groups, err := ConvertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	return EncodeGroups(hrp, groups, enc)
+}
+
+// Decode parses a bech32 or bech32m string and returns the hrp, the data
+// in 8-bit bytes and the checksum variant it was encoded with
+func Decode(s string) (string, []byte, Encoding, error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, 0, errors.New("mixed case")
+	}
+	s = strings.ToLower(s)
+
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 || sep+7 > len(s) {
+		return "", nil, 0, errors.New("missing separator or checksum")
+	}
+	hrp := s[:sep]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, 0, fmt.Errorf("invalid hrp character %q", hrp[i])
+		}
+	}
+
+	groups := make([]byte, 0, len(s)-sep-1)
+	for i := sep + 1; i < len(s); i++ {
+		c := s[i]
+		if c >= 128 || charsetRev[c] < 0 {
+			return "", nil, 0, fmt.Errorf("invalid data character %q", c)
+		}
+		groups = append(groups, byte(charsetRev[c]))
+	}
+
+	var enc Encoding
+	switch polymod(append(hrpExpand(hrp), groups...)) {
+	case checksumConst[Bech32]:
+		enc = Bech32
+	case checksumConst[Bech32m]:
+		enc = Bech32m
+	default:
+		return "", nil, 0, errors.New("invalid checksum")
+	}
+
+	data, err := ConvertBits(groups[:len(groups)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	return hrp, data, enc, nil
+}
+
+// ConvertBits regroups data from fromBits-wide to toBits-wide values,
+// padding the last group with zeros if pad is set
+func ConvertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var acc uint32
+	var bits uint
+	maxv := uint32(1)<<toBits - 1
+	out := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+
+	for _, value := range data {
+		if uint32(value)>>fromBits != 0 {
+			return nil, fmt.Errorf("value %d does not fit in %d bits", value, fromBits)
+		}
+		acc = acc<<fromBits | uint32(value)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, errors.New("invalid padding")
+	}
+	return out, nil
+}
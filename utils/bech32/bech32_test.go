@@ -0,0 +1,122 @@
+package bech32
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDecodeValid checks the valid test vectors of BIP 173 and BIP 350
+func TestDecodeValid(t *testing.T) {
+	tests := []struct {
+		input string
+		hrp   string
+		enc   Encoding
+	}{
+		{"A12UEL5L", "a", Bech32},
+		{"a12uel5l", "a", Bech32},
+		{"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs", "an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio", Bech32},
+		{"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw", "abcdef", Bech32},
+		{"11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j", "1", Bech32},
+		{"?1ezyfcl", "?", Bech32},
+		{"A1LQFN3A", "a", Bech32m},
+		{"a1lqfn3a", "a", Bech32m},
+		{"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx", "abcdef", Bech32m},
+		{"?1v759aa", "?", Bech32m},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			hrp, data, enc, err := Decode(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.hrp, hrp)
+			assert.Equal(t, tt.enc, enc)
+
+			encoded, err := Encode(hrp, data, enc)
+			require.NoError(t, err)
+			assert.Equal(t, strings.ToLower(tt.input), encoded)
+		})
+	}
+}
+
+// TestDecodeInvalid checks the invalid test vectors of BIP 173, except the
+// one over MaxLength, which Decode accepts on purpose
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"hrp character below range", "\x201nwldj5"},
+		{"hrp character DEL", "\x7f1axkwrx"},
+		{"hrp character above ASCII", "\x801eym55h"},
+		{"no separator", "pzry9x0s0muk"},
+		{"empty hrp", "1pzry9x0s0muk"},
+		{"invalid data character", "x1b4n0q5v"},
+		{"checksum too short", "li1dgmt3"},
+		{"invalid checksum character", "de1lg7wt\xff"},
+		{"checksum of the uppercase hrp", "A1G7SGD8"},
+		{"empty hrp without data", "10a06t8"},
+		{"empty hrp with data", "1qzzfhee"},
+		{"mixed case", "A12uEL5L"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := Decode(tt.input)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// TestEncodeCosmosAddress round trips a 20-byte account address
+func TestEncodeCosmosAddress(t *testing.T) {
+	address := make([]byte, 20)
+	for i := range address {
+		address[i] = byte(i)
+	}
+
+	for _, prefix := range []string{"cosmos", "osmo", "cosmosvaloper"} {
+		t.Run(prefix, func(t *testing.T) {
+			encoded, err := Encode(prefix, address, Bech32)
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(encoded, prefix+"1"))
+
+			hrp, data, enc, err := Decode(encoded)
+			require.NoError(t, err)
+			assert.Equal(t, prefix, hrp)
+			assert.Equal(t, address, data)
+			assert.Equal(t, Bech32, enc)
+		})
+	}
+}
+
+func TestConvertBits(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		from, to uint
+		pad      bool
+		want     []byte
+		wantErr  bool
+	}{
+		{"8 to 5 padded", []byte{0xff}, 8, 5, true, []byte{31, 28}, false},
+		{"5 to 8 exact", []byte{31, 28}, 5, 8, false, []byte{0xff}, false},
+		{"5 to 8 nonzero padding", []byte{31, 29}, 5, 8, false, nil, true},
+		{"value too wide", []byte{32}, 5, 8, false, nil, true},
+		{"empty", nil, 8, 5, true, []byte{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertBits(tt.data, tt.from, tt.to, tt.pad)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,181 @@
+// Package protowire reads and writes the protobuf wire format at the level
+// of individual fields, without a schema and without rejecting anything a
+// fuzzer may want to produce
+package protowire
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Type is a protobuf wire type
+type Type int8
+
+const (
+	VarintType     Type = 0
+	Fixed64Type    Type = 1
+	BytesType      Type = 2
+	StartGroupType Type = 3
+	EndGroupType   Type = 4
+	Fixed32Type    Type = 5
+)
+
+// MaxFieldNumber is the largest field number protobuf allows
+const MaxFieldNumber = 1<<29 - 1
+
+var (
+	errTruncated = errors.New("truncated field")
+	errOverflow  = errors.New("varint overflows 64 bits")
+)
+
+// Field is one encoded field: its tag and everything after it, including
+// the length prefix of length-delimited fields
+type Field struct {
+	Number int32
+	Type   Type
+	Tag    []byte
+	Body   []byte
+}
+
+// AppendVarint appends v in base 128 varint encoding
+func AppendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// AppendTag appends the tag of a field
+func AppendTag(b []byte, num int32, t Type) []byte {
+	return AppendVarint(b, uint64(num)<<3|uint64(t&7))
+}
+
+// AppendFixed32 appends v little-endian in four bytes
+func AppendFixed32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+// AppendFixed64 appends v little-endian in eight bytes
+func AppendFixed64(b []byte, v uint64) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24),
+		byte(v>>32), byte(v>>40), byte(v>>48), byte(v>>56))
+}
+
+// AppendBytes appends v with its length prefix
+func AppendBytes(b []byte, v []byte) []byte {
+	return append(AppendVarint(b, uint64(len(v))), v...)
+}
+
+// EncodeZigZag maps signed integers to unsigned ones for sint32 and sint64
+func EncodeZigZag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// DecodeZigZag reverses EncodeZigZag
+func DecodeZigZag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// ConsumeVarint decodes a varint and returns it with the number of bytes read
+func ConsumeVarint(b []byte) (uint64, int, error) {
+	var v uint64
+	for i := 0; i < len(b); i++ {
+		if i == 10 || (i == 9 && b[i] > 1) {
+			return 0, 0, errOverflow
+		}
+		v |= uint64(b[i]&0x7F) << (7 * uint(i))
+		if b[i] < 0x80 {
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, errTruncated
+}
+
+// ConsumeTag decodes a field tag
+func ConsumeTag(b []byte) (int32, Type, int, error) {
+	v, n, err := ConsumeVarint(b)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	num := v >> 3
+	if num == 0 || num > MaxFieldNumber {
+		return 0, 0, 0, fmt.Errorf("invalid field number %d", num)
+	}
+	return int32(num), Type(v & 7), n, nil
+}
+
+// bodyLength returns how many bytes the value of a field of type t takes
+func bodyLength(t Type, b []byte) (int, error) {
+	switch t {
+	case VarintType:
+		_, n, err := ConsumeVarint(b)
+		return n, err
+	case Fixed32Type:
+		if len(b) < 4 {
+			return 0, errTruncated
+		}
+		return 4, nil
+	case Fixed64Type:
+		if len(b) < 8 {
+			return 0, errTruncated
+		}
+		return 8, nil
+	case BytesType:
+		size, n, err := ConsumeVarint(b)
+		if err != nil {
+			return 0, err
+		}
+		if size > uint64(len(b)-n) {
+			return 0, errTruncated
+		}
+		return n + int(size), nil
+	}
+	return 0, fmt.Errorf("unsupported wire type %d", t)
+}
+
+// ParseFields splits a message into its top-level fields. Groups are not
+// supported, Cosmos SDK messages never use them.
+func ParseFields(b []byte) ([]Field, error) {
+	var fields []Field
+	for len(b) > 0 {
+		num, t, n, err := ConsumeTag(b)
+		if err != nil {
+			return fields, err
+		}
+		size, err := bodyLength(t, b[n:])
+		if err != nil {
+			return fields, fmt.Errorf("field %d: %v", num, err)
+		}
+		fields = append(fields, Field{
+			Number: num,
+			Type:   t,
+			Tag:    b[:n:n],
+			Body:   b[n : n+size : n+size],
+		})
+		b = b[n+size:]
+	}
+	return fields, nil
+}
+
+// AppendFields encodes fields back into a message
+func AppendFields(b []byte, fields []Field) []byte {
+	for _, f := range fields {
+		b = append(b, f.Tag...)
+		b = append(b, f.Body...)
+	}
+	return b
+}
+
+// Content returns the value of a length-delimited field without its length
+// prefix, or nil if the field is not length-delimited
+func (f Field) Content() []byte {
+	if f.Type != BytesType {
+		return nil
+	}
+	_, n, err := ConsumeVarint(f.Body)
+	if err != nil {
+		return nil
+	}
+	return f.Body[n:]
+}
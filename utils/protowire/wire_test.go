@@ -0,0 +1,157 @@
+package protowire
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVarint(t *testing.T) {
+	tests := []struct {
+		value   uint64
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{1, []byte{0x01}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{300, []byte{0xac, 0x02}},
+		{math.MaxUint64, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+	}
+
+	for _, tt := range tests {
+		encoded := AppendVarint(nil, tt.value)
+		assert.Equal(t, tt.encoded, encoded, "encoding %d", tt.value)
+
+		value, n, err := ConsumeVarint(append(encoded, 0xaa))
+		require.NoError(t, err)
+		assert.Equal(t, tt.value, value)
+		assert.Equal(t, len(tt.encoded), n)
+	}
+}
+
+func TestConsumeVarintErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+		err   error
+	}{
+		{"empty", nil, errTruncated},
+		{"unterminated", []byte{0x80, 0x80}, errTruncated},
+		{"tenth byte too large", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02}, errOverflow},
+		{"eleven bytes", []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}, errOverflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ConsumeVarint(tt.input)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestZigZag(t *testing.T) {
+	tests := []struct {
+		value   int64
+		encoded uint64
+	}{
+		{0, 0},
+		{-1, 1},
+		{1, 2},
+		{-2, 3},
+		{math.MaxInt64, math.MaxUint64 - 1},
+		{math.MinInt64, math.MaxUint64},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.encoded, EncodeZigZag(tt.value), "encoding %d", tt.value)
+		assert.Equal(t, tt.value, DecodeZigZag(tt.encoded), "decoding %d", tt.encoded)
+	}
+}
+
+func TestConsumeTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []byte
+		num     int32
+		typ     Type
+		wantErr bool
+	}{
+		{"varint field 1", []byte{0x08}, 1, VarintType, false},
+		{"bytes field 2", []byte{0x12}, 2, BytesType, false},
+		{"largest field", AppendTag(nil, MaxFieldNumber, Fixed32Type), MaxFieldNumber, Fixed32Type, false},
+		{"field 0", []byte{0x00}, 0, 0, true},
+		{"field above the limit", AppendVarint(nil, uint64(MaxFieldNumber+1)<<3), 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			num, typ, _, err := ConsumeTag(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.num, num)
+			assert.Equal(t, tt.typ, typ)
+		})
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	var message []byte
+	message = AppendVarint(AppendTag(message, 1, VarintType), 150)
+	message = AppendBytes(AppendTag(message, 2, BytesType), []byte("cosmos"))
+	message = AppendFixed32(AppendTag(message, 3, Fixed32Type), 0xdeadbeef)
+	message = AppendFixed64(AppendTag(message, 4, Fixed64Type), 1)
+	message = AppendVarint(AppendTag(message, 1, VarintType), EncodeZigZag(-3))
+
+	fields, err := ParseFields(message)
+	require.NoError(t, err)
+	require.Len(t, fields, 5)
+
+	tests := []struct {
+		num     int32
+		typ     Type
+		body    []byte
+		content []byte
+	}{
+		{1, VarintType, []byte{0x96, 0x01}, nil},
+		{2, BytesType, append([]byte{6}, "cosmos"...), []byte("cosmos")},
+		{3, Fixed32Type, []byte{0xef, 0xbe, 0xad, 0xde}, nil},
+		{4, Fixed64Type, []byte{1, 0, 0, 0, 0, 0, 0, 0}, nil},
+		{1, VarintType, []byte{0x05}, nil},
+	}
+	for i, tt := range tests {
+		assert.Equal(t, tt.num, fields[i].Number, "field %d", i)
+		assert.Equal(t, tt.typ, fields[i].Type, "field %d", i)
+		assert.Equal(t, tt.body, fields[i].Body, "field %d", i)
+		assert.Equal(t, tt.content, fields[i].Content(), "field %d", i)
+	}
+
+	assert.Equal(t, message, AppendFields(nil, fields))
+}
+
+func TestParseFieldsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  []byte
+		parsed int // Fields returned before the error
+	}{
+		{"bytes longer than the message", []byte{0x12, 0x05, 'a'}, 0},
+		{"truncated fixed32", []byte{0x08, 0x01, 0x1d, 0x00}, 1},
+		{"truncated fixed64", []byte{0x21, 0x00, 0x00}, 0},
+		{"group", []byte{0x0b, 0x0c}, 0},
+		{"field 0", []byte{0x00, 0x01}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := ParseFields(tt.input)
+			assert.Error(t, err)
+			assert.Len(t, fields, tt.parsed)
+		})
+	}
+}
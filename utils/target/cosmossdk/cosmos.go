@@ -1,13 +1,20 @@
 package cosmossdk
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
 	"strings"
+
+	"github.com/GoSec-Labs/StateStinger/utils/protowire"
 )
 
 type CosmosModule struct {
@@ -15,6 +22,21 @@ type CosmosModule struct {
 	Name       string
 	Handlers   []string
 	StateTypes []string
+	Schemas    []StructSchema // Field schemas of the StateTypes, in the same order
+	Proto      *ProtoSchema   // Messages from the .proto files, nil if there are none
+	Dictionary [][]byte       // Tokens extracted from the module's constants
+	SDKPanics  bool           // Recover panics into ErrPanic errors as baseapp does, except runtime errors
+}
+
+// ErrPanic is the error a panic is recovered into when SDKPanics is set,
+// standing in for the SDK's own sdkerrors.ErrPanic
+var ErrPanic = errors.New("panic")
+
+// PanicInfo describes a panic recovered from a handler
+type PanicInfo struct {
+	Value   string
+	Stack   string // Stack of the panicking goroutine
+	Runtime bool   // A runtime error such as a nil dereference rather than a call to panic
 }
 
 func LoadCosmosModule(path, moduleName string) (*CosmosModule, error) {
@@ -35,8 +57,20 @@ func LoadCosmosModule(path, moduleName string) (*CosmosModule, error) {
 		return nil, err
 	}
 
-	log.Printf("Loaded module '%s' with %d handlers and %d state types",
-		moduleName, len(module.Handlers), len(module.StateTypes))
+	if err := module.discoverProto(); err != nil {
+		return nil, err
+	}
+
+	if err := module.discoverDictionary(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Loaded module '%s' with %d handlers, %d state types and %d dictionary tokens",
+		moduleName, len(module.Handlers), len(module.StateTypes), len(module.Dictionary))
+	if module.Proto != nil {
+		log.Printf("Parsed %d protobuf messages, %d in the Msg service",
+			len(module.Proto.Messages), len(module.Proto.Msgs))
+	}
 
 	return module, nil
 }
@@ -77,50 +111,97 @@ func (m *CosmosModule) discoverMessageHandlers() error {
 	return nil
 }
 
-// discoverStateTypes finds the state types defined in the module
-func (m *CosmosModule) discoverStateTypes() error {
-	typesDir := filepath.Join(m.Path, "types")
-	if _, err := os.Stat(typesDir); os.IsNotExist(err) {
-		return fmt.Errorf("types directory not found: %s", typesDir)
-	}
+// ExecResult captures everything observed while executing one fuzz input
+type ExecResult struct {
+	Output  []byte
+	Err     error
+	Handler string        // Handler the input was dispatched to, empty if none
+	Edges   []uint32      // Edges visited during execution, AFL style (prev>>1 ^ cur)
+	Delta   []StateChange // Store writes of an execution that did not fail
+	Events  []string      // Events emitted, as "type.attribute=value"
+	GasUsed uint64        // Gas consumed by the handler, zero if none ran
+	Panic   *PanicInfo    // Set if the handler panicked
+}
 
-	// Look for structs that might be state objects
-	cmd := exec.Command("grep", "-r", "type.*struct", typesDir)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to discover state types: %v", err)
-	}
+// edgeTracer records the control-flow edges taken through the target
+type edgeTracer struct {
+	prev  uint32
+	edges []uint32
+}
 
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "type") && strings.Contains(line, "struct") {
-			// Extract type name
-			parts := strings.Split(line, "type ")
-			if len(parts) < 2 {
-				continue
-			}
-			typeParts := strings.Split(parts[1], " ")
-			if len(typeParts) < 2 {
-				continue
-			}
-			typeName := strings.TrimSpace(typeParts[0])
-			if typeName != "" {
-				m.StateTypes = append(m.StateTypes, typeName)
-			}
+// hit marks a location as reached and records the edge leading to it
+func (t *edgeTracer) hit(location string) {
+	cur := crc32.ChecksumIEEE([]byte(location))
+	t.edges = append(t.edges, (t.prev>>1)^cur)
+	t.prev = cur
+}
+
+// ExecuteFuzz runs a fuzzing input against the target module
+func (m *CosmosModule) ExecuteFuzz(input []byte) ([]byte, error) {
+	result := m.Execute(input)
+	return result.Output, result.Err
+}
+
+// Execute runs a fuzzing input against the target module on an empty store
+func (m *CosmosModule) Execute(input []byte) *ExecResult {
+	return m.ExecuteOn(input, nil)
+}
+
+// ExecuteOn runs a fuzzing input against the target module, whose handlers
+// read the store, and reports the edges it covered on the way. Every branch
+// of the dispatch logic is a coverage location, which stands in for
+// compiler-inserted counters. Panics are recovered and reported as errors,
+// so a handler bug never takes the fuzzer down. A nil store is empty.
+func (m *CosmosModule) ExecuteOn(input []byte, store Store) (result *ExecResult) {
+	if store == nil {
+		store = emptyStore{}
+	}
+	tracer := &edgeTracer{}
+	result = &ExecResult{}
+	defer func() {
+		if r := recover(); r != nil {
+			m.recovered(result, tracer, r)
 		}
+	}()
+	m.execute(input, store, result, tracer)
+	return result
+}
+
+// recovered turns a panic into the result of the execution. Nothing a
+// panicking handler did is committed.
+func (m *CosmosModule) recovered(result *ExecResult, tracer *edgeTracer, r interface{}) {
+	_, isRuntime := r.(runtime.Error)
+	result.Panic = &PanicInfo{
+		Value:   fmt.Sprint(r),
+		Stack:   string(debug.Stack()),
+		Runtime: isRuntime,
+	}
+	result.Output = nil
+	result.Delta = nil
+	result.Events = nil
+	if m.SDKPanics && !isRuntime {
+		result.Err = fmt.Errorf("recovered: %v: %w", r, ErrPanic)
+	} else {
+		result.Err = fmt.Errorf("panic: %v", r)
 	}
 
-	return nil
+	tracer.hit("panic")
+	result.Edges = tracer.edges
 }
 
-// ExecuteFuzz runs a fuzzing input against the target module
-func (m *CosmosModule) ExecuteFuzz(input []byte) ([]byte, error) {
+// execute runs the simulated handler for input, filling in result
+func (m *CosmosModule) execute(input []byte, store Store, result *ExecResult, tracer *edgeTracer) {
+	tracer.hit("entry")
+
 	// In a real implementation, this would use reflection or code generation
 	// to create and execute actual Cosmos SDK messages
 
 	// For now, we'll simulate the process
 	if len(input) < 4 {
-		return nil, errors.New("input too short")
+		tracer.hit("input_too_short")
+		result.Err = errors.New("input too short")
+		result.Edges = tracer.edges
+		return
 	}
 
 	// First byte determines which handler to target
@@ -128,29 +209,98 @@ func (m *CosmosModule) ExecuteFuzz(input []byte) ([]byte, error) {
 
 	// If we're at the last index, simulate a random error
 	if handlerIndex == len(m.Handlers) {
-		return nil, fmt.Errorf("simulated error: invalid handler")
+		tracer.hit("invalid_handler")
+		result.Err = fmt.Errorf("simulated error: invalid handler")
+		result.Edges = tracer.edges
+		return
 	}
+	result.Handler = m.Handlers[handlerIndex]
+	tracer.hit("handler:" + result.Handler)
 
 	// Second byte determines a simulated outcome
 	outcome := input[1] % 5
+	tracer.hit(fmt.Sprintf("handler:%s:outcome:%d", result.Handler, outcome))
+
+	// Structured inputs carry a JSON or protobuf encoded message after the
+	// two selector bytes
+	if len(input) > 2 && input[2] == '{' {
+		traceMessage(tracer, result.Handler, input[2:])
+	} else if msg := m.ProtoMessageFor(result.Handler); msg != nil && len(input) > 2 {
+		m.traceProto(tracer, result.Handler, msg, input[2:])
+	}
 
 	switch outcome {
 	case 0:
-		// Successful execution
-		return []byte("success"), nil
+		// Successful execution, unless the module's books refuse it
+		books, err := m.simulateLedger(store, result.Handler, input[2:], false)
+		if err != nil {
+			tracer.hit("handler:" + result.Handler + ":rejected")
+			result.Err = err
+			break
+		}
+		result.Output = []byte("success")
+		result.Delta = append(m.simulateWrites(result.Handler, input[2:]), books...)
+		result.Events = m.simulateEvents(result.Handler, result.Delta)
 	case 1:
 		// Invalid arguments
-		return nil, fmt.Errorf("invalid arguments")
+		result.Err = fmt.Errorf("invalid arguments")
 	case 2:
 		// Permission denied
-		return nil, fmt.Errorf("permission denied")
+		result.Err = fmt.Errorf("permission denied")
 	case 3:
-		// State inconsistency
-		return []byte("state_inconsistent"), nil
+		// State inconsistency, the module's books are written with a bug
+		result.Output = []byte("state_inconsistent")
+		result.Delta, _ = m.simulateLedger(store, result.Handler, input[2:], true)
 	case 4:
 		// Consensus failure
-		return []byte("consensus_failure"), nil
+		result.Output = []byte("consensus_failure")
 	}
 
-	return []byte("unknown"), nil
+	result.GasUsed = simulateGas(input, result.Delta)
+	result.Edges = tracer.edges
+}
+
+// traceMessage records which fields of a structured message were set, and
+// which were left empty, as coverage locations
+func traceMessage(tracer *edgeTracer, handler string, payload []byte) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		tracer.hit("handler:" + handler + ":decode_error")
+		return
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		location := "handler:" + handler + ":field:" + key
+		switch string(fields[key]) {
+		case "null", `""`, "[]", "{}", "0", `"0"`:
+			location += ":empty"
+		}
+		tracer.hit(location)
+	}
+}
+
+// traceProto records which fields of a protobuf message were present, and
+// whether they had the wire type the schema expects, as coverage locations
+func (m *CosmosModule) traceProto(tracer *edgeTracer, handler string, msg *ProtoMessage, payload []byte) {
+	fields, err := protowire.ParseFields(payload)
+	for _, f := range fields {
+		field := msg.FieldByNumber(f.Number)
+		switch {
+		case field == nil:
+			tracer.hit("handler:" + handler + ":proto:unknown_field")
+		case f.Type != m.Proto.WireType(field.Type) && !(field.Repeated && m.Proto.Packable(field.Type) && f.Type == protowire.BytesType):
+			tracer.hit("handler:" + handler + ":proto:" + field.Name + ":wrong_type")
+		default:
+			tracer.hit("handler:" + handler + ":proto:" + field.Name)
+		}
+	}
+	if err != nil {
+		tracer.hit("handler:" + handler + ":proto:malformed")
+	}
 }
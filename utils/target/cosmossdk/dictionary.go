@@ -0,0 +1,188 @@
+package cosmossdk
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxTokenLength skips literals too long to be useful as dictionary tokens
+const maxTokenLength = 128
+
+// tokenSet collects unique dictionary tokens
+type tokenSet map[string]bool
+
+func (t tokenSet) add(token []byte) {
+	if len(token) > 0 && len(token) <= maxTokenLength {
+		t[string(token)] = true
+	}
+}
+
+// addInt adds the decimal form of an integer and, for values that fit,
+// the single byte store keys and error codes are usually encoded as
+func (t tokenSet) addInt(value int64) {
+	t.add([]byte(strconv.FormatInt(value, 10)))
+	if value >= 0 && value < 256 {
+		t.add([]byte{byte(value)})
+	}
+}
+
+// discoverDictionary extracts tokens from the constants in the module's
+// keeper/ and types/ sources: string and integer constants, store key
+// prefixes from keys.go, registered error codes, event attribute keys and
+// Msg type URLs
+func (m *CosmosModule) discoverDictionary() error {
+	tokens := make(tokenSet)
+	fset := token.NewFileSet()
+
+	for _, dir := range []string{"keeper", "types"} {
+		files, err := filepath.Glob(filepath.Join(m.Path, dir, "*.go"))
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			if strings.HasSuffix(file, "_test.go") {
+				continue
+			}
+			src, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			parsed, err := parser.ParseFile(fset, file, src, 0)
+			if err != nil {
+				// Generated or broken files should not stop the campaign
+				continue
+			}
+			collectTokens(parsed, tokens)
+		}
+	}
+
+	m.Dictionary = make([][]byte, 0, len(tokens))
+	for tok := range tokens {
+		m.Dictionary = append(m.Dictionary, []byte(tok))
+	}
+	sort.Slice(m.Dictionary, func(i, j int) bool {
+		return string(m.Dictionary[i]) < string(m.Dictionary[j])
+	})
+
+	return nil
+}
+
+// collectTokens walks one file and adds the literals worth fuzzing with
+func collectTokens(file *ast.File, tokens tokenSet) {
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.ValueSpec:
+			// const and var declarations, e.g. StoreKey = "bank" or
+			// BalancesPrefix = []byte{0x02}
+			for _, value := range node.Values {
+				addLiteral(value, tokens)
+			}
+		case *ast.CallExpr:
+			collectCallTokens(node, tokens)
+		}
+		return true
+	})
+}
+
+// collectCallTokens handles calls whose literal arguments are interesting
+func collectCallTokens(call *ast.CallExpr, tokens tokenSet) {
+	name := ""
+	switch fun := call.Fun.(type) {
+	case *ast.SelectorExpr:
+		name = fun.Sel.Name
+	case *ast.Ident:
+		name = fun.Name
+	}
+
+	switch name {
+	case "Register", "RegisterConcrete", "NewAttribute", "NewEvent", "NewPrefix":
+		// errorsmod.Register(ModuleName, 2, "insufficient funds"),
+		// cdc.RegisterConcrete(&MsgSend{}, "cosmos-sdk/MsgSend", nil),
+		// sdk.NewAttribute("sender", ...), collections.NewPrefix(2)
+		for _, arg := range call.Args {
+			addLiteral(arg, tokens)
+		}
+	case "RegisterType":
+		// proto.RegisterType((*MsgSend)(nil), "cosmos.bank.v1beta1.MsgSend")
+		for _, arg := range call.Args {
+			if s, ok := stringLiteral(arg); ok {
+				tokens.add([]byte(s))
+				tokens.add([]byte("/" + s))
+			}
+		}
+	}
+}
+
+// addLiteral adds the value of a literal expression, looking through
+// []byte{...} and []byte("...") forms
+func addLiteral(expr ast.Expr, tokens tokenSet) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if s, ok := stringLiteral(e); ok {
+			tokens.add([]byte(s))
+		} else if v, ok := intLiteral(e); ok {
+			tokens.addInt(v)
+		}
+	case *ast.CompositeLit:
+		if !isByteSlice(e.Type) {
+			return
+		}
+		prefix := make([]byte, 0, len(e.Elts))
+		for _, elt := range e.Elts {
+			lit, ok := elt.(*ast.BasicLit)
+			if !ok {
+				return
+			}
+			v, ok := intLiteral(lit)
+			if !ok || v < 0 || v > 255 {
+				return
+			}
+			prefix = append(prefix, byte(v))
+		}
+		tokens.add(prefix)
+	case *ast.CallExpr:
+		// []byte("prefix")
+		if isByteSlice(e.Fun) && len(e.Args) == 1 {
+			if s, ok := stringLiteral(e.Args[0]); ok {
+				tokens.add([]byte(s))
+			}
+		}
+	}
+}
+
+func isByteSlice(expr ast.Expr) bool {
+	array, ok := expr.(*ast.ArrayType)
+	if !ok || array.Len != nil {
+		return false
+	}
+	ident, ok := array.Elt.(*ast.Ident)
+	return ok && ident.Name == "byte"
+}
+
+func stringLiteral(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+func intLiteral(lit *ast.BasicLit) (int64, bool) {
+	if lit.Kind != token.INT && lit.Kind != token.CHAR {
+		return 0, false
+	}
+	if lit.Kind == token.CHAR {
+		r, _, _, err := strconv.UnquoteChar(strings.Trim(lit.Value, "'"), '\'')
+		return int64(r), err == nil
+	}
+	v, err := strconv.ParseInt(lit.Value, 0, 64)
+	return v, err == nil
+}
@@ -0,0 +1,397 @@
+package cosmossdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+/*
+Modules named bank, staking and distribution also keep simulated books,
+under the key layout of the SDK's own stores:
+
+	bank/balances/<address>/<denom>             math.Int
+	bank/supply/<denom>                         math.Int
+	staking/params/bond_denom                   denom
+	staking/validators/<valoper>                {"tokens": "<math.Int>", "status": "bonded"}
+	staking/pool/bonded                         math.Int
+	distribution/outstanding/<valoper>          math.LegacyDec
+	distribution/rewards/<delegator>/<valoper>  math.LegacyDec
+
+Handlers read the books from the store they execute against. An account is
+funded with genesisBalance of a denom, which adds to its supply, the first
+time it spends it. The state inconsistency outcome runs the handler with a
+bookkeeping bug: a send keeps the coins with the sender, a delegation
+leaves the bonded pool record behind and a reward is allocated without
+adding to the validator's outstanding rewards.
+*/
+
+// genesisBalance is what an account holds of a denom before it first spends it
+const genesisBalance = 1000000000
+
+// bondedPoolAccount is the module account holding the bonded tokens
+const bondedPoolAccount = "bonded_tokens_pool"
+
+// decScale is 10^18, math.LegacyDec values are kept multiplied by it
+var decScale = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+
+// rewardPerMessage is what a delegation is allocated with each distribution
+// message, standing in for the blocks in between: 1.0 scaled by decScale
+var rewardPerMessage = decScale
+
+// errRejected is the error the simulated books reject a message with, the
+// same one the invalid arguments outcome returns
+var errRejected = errors.New("invalid arguments")
+
+// Store is the read side of the store a handler executes against
+type Store interface {
+	Get(key string) ([]byte, bool)
+}
+
+// emptyStore is the store of an execution without any prior state
+type emptyStore struct{}
+
+func (emptyStore) Get(key string) ([]byte, bool) {
+	return nil, false
+}
+
+// ledgerCoin is a well-formed coin of a message
+type ledgerCoin struct {
+	denom  string
+	amount *big.Int
+}
+
+// ledger collects the writes of one execution on top of the store
+type ledger struct {
+	store  Store
+	values map[string][]byte
+	keys   []string // Written keys, in the order of their first write
+	faulty bool     // Run with the bookkeeping bug of the state inconsistency outcome
+}
+
+// simulateLedger runs the bookkeeping of the simulated bank, staking and
+// distribution modules for a message and returns its writes, or errRejected
+// if the module refuses the message. Other modules and messages the books
+// do not cover write nothing.
+func (m *CosmosModule) simulateLedger(store Store, handler string, payload []byte, faulty bool) ([]StateChange, error) {
+	var simulate func(l *ledger, handler string, msg map[string]interface{}) error
+	switch m.Name {
+	case "bank":
+		simulate = simulateBank
+	case "staking":
+		simulate = simulateStaking
+	case "distribution":
+		simulate = simulateDistribution
+	default:
+		return nil, nil
+	}
+
+	if len(payload) == 0 || payload[0] != '{' {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var msg map[string]interface{}
+	if err := decoder.Decode(&msg); err != nil {
+		return nil, nil
+	}
+
+	l := &ledger{store: store, values: make(map[string][]byte), faulty: faulty}
+	if err := simulate(l, handler, msg); err != nil {
+		return nil, err
+	}
+	return l.changes(), nil
+}
+
+func (l *ledger) get(key string) ([]byte, bool) {
+	if value, ok := l.values[key]; ok {
+		return value, value != nil
+	}
+	return l.store.Get(key)
+}
+
+func (l *ledger) set(key string, value []byte) {
+	if _, ok := l.values[key]; !ok {
+		l.keys = append(l.keys, key)
+	}
+	l.values[key] = value
+}
+
+// amount reads a math.Int, zero if the key is missing or malformed
+func (l *ledger) amount(key string) *big.Int {
+	value, _ := l.get(key)
+	amount, ok := new(big.Int).SetString(string(value), 10)
+	if !ok {
+		return new(big.Int)
+	}
+	return amount
+}
+
+// add adds delta to the math.Int at key
+func (l *ledger) add(key string, delta *big.Int) {
+	l.set(key, []byte(new(big.Int).Add(l.amount(key), delta).String()))
+}
+
+// dec reads a math.LegacyDec scaled by decScale, zero if the key is missing or
+// malformed
+func (l *ledger) dec(key string) *big.Int {
+	value, _ := l.get(key)
+	whole, frac, _ := strings.Cut(string(value), ".")
+	if len(frac) > 18 {
+		return new(big.Int)
+	}
+	raw, ok := new(big.Int).SetString(whole+frac+strings.Repeat("0", 18-len(frac)), 10)
+	if !ok {
+		return new(big.Int)
+	}
+	return raw
+}
+
+// setDec writes a math.LegacyDec from its value scaled by decScale
+func (l *ledger) setDec(key string, raw *big.Int) {
+	sign := ""
+	if raw.Sign() < 0 {
+		sign = "-"
+	}
+	whole, frac := new(big.Int).QuoRem(new(big.Int).Abs(raw), decScale, new(big.Int))
+	l.set(key, []byte(fmt.Sprintf("%s%s.%018s", sign, whole, frac)))
+}
+
+// covers reports whether an account can spend coin, funding it from
+// genesis first if it never held the denom
+func (l *ledger) covers(address string, coin ledgerCoin) bool {
+	key := balanceKey(address, coin.denom)
+	if _, ok := l.get(key); !ok {
+		l.add(key, big.NewInt(genesisBalance))
+		l.add("bank/supply/"+escapeID(coin.denom), big.NewInt(genesisBalance))
+	}
+	return l.amount(key).Cmp(coin.amount) >= 0
+}
+
+func (l *ledger) changes() []StateChange {
+	changes := make([]StateChange, 0, len(l.keys))
+	for _, key := range l.keys {
+		changes = append(changes, StateChange{Key: key, Value: l.values[key]})
+	}
+	return changes
+}
+
+func balanceKey(address, denom string) string {
+	return "bank/balances/" + escapeID(address) + "/" + escapeID(denom)
+}
+
+// escapeID escapes the '/' of an id so it stays one segment of a key
+func escapeID(id string) string {
+	return strings.ReplaceAll(id, "/", "%2F")
+}
+
+// parseCoin reads a Coin value, which must be well-formed and positive
+func parseCoin(value interface{}) (ledgerCoin, bool) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return ledgerCoin{}, false
+	}
+	denom, _ := object["denom"].(string)
+	var amount string
+	switch v := object["amount"].(type) {
+	case string:
+		amount = v
+	case json.Number:
+		amount = v.String()
+	}
+	n, ok := new(big.Int).SetString(amount, 10)
+	if denom == "" || !ok || n.Sign() <= 0 {
+		return ledgerCoin{}, false
+	}
+	return ledgerCoin{denom: denom, amount: n}, true
+}
+
+// parseCoins reads the well-formed coins of a Coins value, the books leave
+// any others alone
+func parseCoins(value interface{}) []ledgerCoin {
+	list, _ := value.([]interface{})
+	var coins []ledgerCoin
+	for _, elem := range list {
+		if coin, ok := parseCoin(elem); ok {
+			coins = append(coins, coin)
+		}
+	}
+	return coins
+}
+
+// simulateBank moves the coins of MsgSend and MsgMultiSend between accounts
+func simulateBank(l *ledger, handler string, msg map[string]interface{}) error {
+	if inputs, ok := msg["inputs"].([]interface{}); ok {
+		outputs, _ := msg["outputs"].([]interface{})
+		return simulateMultiSend(l, inputs, outputs)
+	}
+
+	from, _ := msg["from_address"].(string)
+	to, _ := msg["to_address"].(string)
+	if from == "" || to == "" {
+		return nil
+	}
+	for _, coin := range parseCoins(msg["amount"]) {
+		if !l.covers(from, coin) {
+			return errRejected
+		}
+		if !l.faulty {
+			l.add(balanceKey(from, coin.denom), new(big.Int).Neg(coin.amount))
+		}
+		l.add(balanceKey(to, coin.denom), coin.amount)
+	}
+	return nil
+}
+
+// simulateMultiSend moves coins from the inputs to the outputs, which must
+// add up to the same coins
+func simulateMultiSend(l *ledger, inputs, outputs []interface{}) error {
+	totals := make(map[string]*big.Int)
+	transfer := func(entries []interface{}, sign int) error {
+		for _, entry := range entries {
+			object, _ := entry.(map[string]interface{})
+			address, _ := object["address"].(string)
+			if address == "" {
+				continue
+			}
+			for _, coin := range parseCoins(object["coins"]) {
+				amount := new(big.Int).Mul(coin.amount, big.NewInt(int64(sign)))
+				if totals[coin.denom] == nil {
+					totals[coin.denom] = new(big.Int)
+				}
+				totals[coin.denom].Add(totals[coin.denom], amount)
+
+				if sign < 0 {
+					if !l.covers(address, coin) {
+						return errRejected
+					}
+					if l.faulty {
+						continue
+					}
+				}
+				l.add(balanceKey(address, coin.denom), amount)
+			}
+		}
+		return nil
+	}
+
+	if err := transfer(inputs, -1); err != nil {
+		return err
+	}
+	if err := transfer(outputs, 1); err != nil {
+		return err
+	}
+	for _, total := range totals {
+		if total.Sign() != 0 {
+			return errRejected
+		}
+	}
+	return nil
+}
+
+// ledgerValidator is a validator as the simulated staking module stores it
+type ledgerValidator struct {
+	Tokens string `json:"tokens"`
+	Status string `json:"status"`
+}
+
+// simulateStaking bonds the tokens of MsgCreateValidator and MsgDelegate to
+// a validator and returns those of MsgUndelegate to the delegator at once
+func simulateStaking(l *ledger, handler string, msg map[string]interface{}) error {
+	create := strings.Contains(handler, "CreateValidator")
+	undelegate := strings.Contains(handler, "Undelegate")
+	if !create && !undelegate && !strings.Contains(handler, "Delegate") {
+		return nil
+	}
+
+	validator, _ := msg["validator_address"].(string)
+	delegator, _ := msg["delegator_address"].(string)
+	if delegator == "" {
+		delegator = validator // Self delegation of a new validator
+	}
+	coin, ok := parseCoin(msg["amount"])
+	if create {
+		coin, ok = parseCoin(msg["value"])
+	}
+	if validator == "" || !ok {
+		return nil
+	}
+
+	// The first denom bonded becomes the bond denom
+	if denom, ok := l.get("staking/params/bond_denom"); !ok {
+		l.set("staking/params/bond_denom", []byte(coin.denom))
+	} else if string(denom) != coin.denom {
+		return errRejected
+	}
+
+	key := "staking/validators/" + escapeID(validator)
+	tokens := new(big.Int)
+	value, exists := l.get(key)
+	if exists {
+		var stored ledgerValidator
+		if err := json.Unmarshal(value, &stored); err != nil {
+			return errRejected
+		}
+		if _, ok := tokens.SetString(stored.Tokens, 10); !ok {
+			return errRejected
+		}
+	}
+	if create == exists {
+		return errRejected
+	}
+
+	amount := coin.amount
+	if undelegate {
+		if tokens.Cmp(amount) < 0 {
+			return errRejected
+		}
+		amount = new(big.Int).Neg(amount)
+	} else if !l.covers(delegator, coin) {
+		return errRejected
+	}
+
+	tokens.Add(tokens, amount)
+	value, _ = json.Marshal(ledgerValidator{Tokens: tokens.String(), Status: "bonded"})
+	l.set(key, value)
+	l.add(balanceKey(delegator, coin.denom), new(big.Int).Neg(amount))
+	l.add(balanceKey(bondedPoolAccount, coin.denom), amount)
+	if !l.faulty {
+		l.add("staking/pool/bonded", amount)
+	}
+	return nil
+}
+
+// simulateDistribution allocates a reward to the delegation a message
+// names, the validator's outstanding rewards growing with it, and pays the
+// delegation's rewards out of them for MsgWithdrawDelegatorReward
+func simulateDistribution(l *ledger, handler string, msg map[string]interface{}) error {
+	delegator, _ := msg["delegator_address"].(string)
+	validator, _ := msg["validator_address"].(string)
+	if delegator == "" || validator == "" {
+		return nil
+	}
+
+	rewardsKey := "distribution/rewards/" + escapeID(delegator) + "/" + escapeID(validator)
+	outstandingKey := "distribution/outstanding/" + escapeID(validator)
+	rewards := l.dec(rewardsKey)
+	outstanding := l.dec(outstandingKey)
+
+	rewards.Add(rewards, rewardPerMessage)
+	if !l.faulty {
+		outstanding.Add(outstanding, rewardPerMessage)
+	}
+
+	if strings.Contains(handler, "WithdrawDelegatorReward") {
+		l.setDec(outstandingKey, outstanding.Sub(outstanding, rewards))
+		if _, ok := l.get(rewardsKey); ok {
+			l.set(rewardsKey, nil)
+		}
+		return nil
+	}
+	l.setDec(rewardsKey, rewards)
+	l.setDec(outstandingKey, outstanding)
+	return nil
+}
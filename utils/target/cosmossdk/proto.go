@@ -0,0 +1,799 @@
+package cosmossdk
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/GoSec-Labs/StateStinger/utils/protowire"
+)
+
+// ProtoSchema holds the messages declared in a module's .proto files
+type ProtoSchema struct {
+	Messages map[string]*ProtoMessage // Keyed by full name, e.g. cosmos.bank.v1beta1.MsgSend
+	Enums    map[string]*ProtoEnum
+	Msgs     []string // Request types of the module's Msg service
+}
+
+// ProtoMessage is one message type
+type ProtoMessage struct {
+	Name   string // Full name
+	Fields []ProtoField
+}
+
+// ProtoField is one field of a message. Map fields are represented the way
+// they are encoded, as repeated key/value entry messages.
+type ProtoField struct {
+	Name     string
+	Number   int32
+	Type     string // Scalar type name, or the full name of a message or enum
+	Repeated bool
+	Packed   bool // Repeated scalars encoded in a single length-delimited field
+	Options  map[string]string
+}
+
+// ProtoEnum is one enum type
+type ProtoEnum struct {
+	Name   string
+	Values map[int32]string
+}
+
+// IsScalar reports whether t is one of protobuf's scalar types
+func IsScalar(t string) bool {
+	switch t {
+	case "double", "float", "int32", "int64", "uint32", "uint64", "sint32", "sint64",
+		"fixed32", "fixed64", "sfixed32", "sfixed64", "bool", "string", "bytes":
+		return true
+	}
+	return false
+}
+
+// Packable reports whether repeated fields of type t may use packed encoding
+func (s *ProtoSchema) Packable(t string) bool {
+	if _, ok := s.Enums[t]; ok {
+		return true
+	}
+	return IsScalar(t) && t != "string" && t != "bytes"
+}
+
+// ProtoMessageFor returns the message a handler processes, matching
+// HandleMsgSend and Send to the Msg service's MsgSend, or nil if unknown
+func (m *CosmosModule) ProtoMessageFor(handler string) *ProtoMessage {
+	if m.Proto == nil {
+		return nil
+	}
+	name := strings.TrimPrefix(handler, "Handle")
+	if !strings.HasPrefix(name, "Msg") {
+		name = "Msg" + name
+	}
+
+	for _, full := range m.Proto.Msgs {
+		if full == name || strings.HasSuffix(full, "."+name) {
+			return m.Proto.Messages[full]
+		}
+	}
+	return nil
+}
+
+// findProtoDir looks for a proto/ directory in the module and the
+// directories above it, where Cosmos SDK repositories keep their .proto files
+func findProtoDir(path string) string {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	for i := 0; i < 5; i++ {
+		candidate := filepath.Join(dir, "proto")
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return ""
+}
+
+// discoverProto parses the .proto files that belong to the module. A
+// module without them keeps a nil Proto.
+func (m *CosmosModule) discoverProto() error {
+	protoDir := findProtoDir(m.Path)
+	if protoDir == "" {
+		return nil
+	}
+
+	var files []string
+	err := filepath.Walk(protoDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(path, ".proto") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	schema := &ProtoSchema{
+		Messages: make(map[string]*ProtoMessage),
+		Enums:    make(map[string]*ProtoEnum),
+	}
+	var parsers []*protoParser
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		p := &protoParser{schema: schema, tokens: tokenizeProto(string(src))}
+		if err := p.parseFile(); err != nil {
+			// Parse what can be parsed, a broken file should not stop the campaign
+			continue
+		}
+		rel, _ := filepath.Rel(protoDir, file)
+		p.own = strings.Contains("/"+filepath.ToSlash(rel), "/"+m.Name+"/")
+		parsers = append(parsers, p)
+	}
+
+	// Types can only be resolved once every file has been read
+	var ownMsgs, allMsgs []string
+	for _, p := range parsers {
+		p.resolve()
+		allMsgs = append(allMsgs, p.msgs...)
+		if p.own {
+			ownMsgs = append(ownMsgs, p.msgs...)
+		}
+	}
+
+	schema.Msgs = ownMsgs
+	if len(schema.Msgs) == 0 {
+		schema.Msgs = allMsgs
+	}
+	if len(schema.Messages) > 0 {
+		m.Proto = schema
+	}
+	return nil
+}
+
+// tokenizeProto splits a .proto source into identifiers, numbers, quoted
+// strings and punctuation, dropping comments
+func tokenizeProto(src string) []string {
+	var tokens []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return tokens
+			}
+			tokens = append(tokens, src[i:j+1])
+			i = j + 1
+		case c == '_' || c == '.' || c == '-' || c == '+' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, src[i:j])
+			i = j
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens
+}
+
+// protoParser parses one file into the shared schema
+type protoParser struct {
+	schema  *ProtoSchema
+	tokens  []string
+	pos     int
+	pkg     string
+	proto3  bool
+	own     bool           // The file is under a directory named after the module
+	msgs    []string       // Request types of the file's Msg service
+	pending []pendingField // Fields whose types are resolved once all files are read
+}
+
+type pendingField struct {
+	message *ProtoMessage
+	index   int
+	scope   string
+}
+
+func (p *protoParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *protoParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *protoParser) expect(tok string) error {
+	if got := p.next(); got != tok {
+		return fmt.Errorf("expected %q, got %q", tok, got)
+	}
+	return nil
+}
+
+// skipStatement skips to the end of the current statement, including any
+// aggregate option value in braces
+func (p *protoParser) skipStatement() {
+	depth := 0
+	for p.pos < len(p.tokens) {
+		switch p.next() {
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth <= 0 {
+				return
+			}
+		case ";":
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+func (p *protoParser) parseFile() error {
+	for p.pos < len(p.tokens) {
+		switch tok := p.next(); tok {
+		case "syntax", "edition":
+			p.expect("=")
+			p.proto3 = strings.Trim(p.next(), `"'`) != "proto2"
+			p.skipStatement()
+		case "package":
+			p.pkg = p.next()
+			p.skipStatement()
+		case "message":
+			if err := p.parseMessage(p.pkg); err != nil {
+				return err
+			}
+		case "enum":
+			if err := p.parseEnum(p.pkg); err != nil {
+				return err
+			}
+		case "service":
+			if err := p.parseService(); err != nil {
+				return err
+			}
+		case ";":
+		default:
+			// import, option, extend
+			p.skipStatement()
+		}
+	}
+	return nil
+}
+
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+func (p *protoParser) parseMessage(scope string) error {
+	msg := &ProtoMessage{Name: qualify(scope, p.next())}
+	p.schema.Messages[msg.Name] = msg
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	return p.parseMessageBody(msg)
+}
+
+func (p *protoParser) parseMessageBody(msg *ProtoMessage) error {
+	for p.pos < len(p.tokens) {
+		switch tok := p.peek(); tok {
+		case "}":
+			p.next()
+			return nil
+		case ";":
+			p.next()
+		case "message":
+			p.next()
+			if err := p.parseMessage(msg.Name); err != nil {
+				return err
+			}
+		case "enum":
+			p.next()
+			if err := p.parseEnum(msg.Name); err != nil {
+				return err
+			}
+		case "oneof":
+			p.next()
+			p.next()
+			if err := p.expect("{"); err != nil {
+				return err
+			}
+			// oneof members are plain fields on the wire
+			if err := p.parseMessageBody(msg); err != nil {
+				return err
+			}
+		case "option", "reserved", "extensions", "extend":
+			p.skipStatement()
+		case "map":
+			p.next()
+			if err := p.parseMapField(msg); err != nil {
+				return err
+			}
+		default:
+			if err := p.parseField(msg); err != nil {
+				return err
+			}
+		}
+	}
+	return fmt.Errorf("unterminated message %s", msg.Name)
+}
+
+func (p *protoParser) parseField(msg *ProtoMessage) error {
+	field := ProtoField{}
+	switch p.peek() {
+	case "repeated":
+		field.Repeated = true
+		p.next()
+	case "optional", "required":
+		p.next()
+	}
+
+	field.Type = p.next()
+	field.Name = p.next()
+	if err := p.expect("="); err != nil {
+		return fmt.Errorf("field %s.%s: %v", msg.Name, field.Name, err)
+	}
+	number, err := strconv.ParseInt(p.next(), 0, 32)
+	if err != nil {
+		return fmt.Errorf("field %s.%s: bad number", msg.Name, field.Name)
+	}
+	field.Number = int32(number)
+
+	field.Options = p.parseOptions()
+	p.skipStatement()
+
+	// proto3 packs repeated scalars unless told otherwise, proto2 only when told
+	if field.Repeated {
+		field.Packed = p.proto3
+		if packed, ok := field.Options["packed"]; ok {
+			field.Packed = packed == "true"
+		}
+		if IsScalar(field.Type) && !p.schema.Packable(field.Type) {
+			field.Packed = false
+		}
+	}
+
+	msg.Fields = append(msg.Fields, field)
+	if !IsScalar(field.Type) {
+		p.pending = append(p.pending, pendingField{message: msg, index: len(msg.Fields) - 1, scope: msg.Name})
+	}
+	return nil
+}
+
+// parseMapField turns map<K, V> name = N into a repeated entry message
+func (p *protoParser) parseMapField(msg *ProtoMessage) error {
+	if err := p.expect("<"); err != nil {
+		return err
+	}
+	keyType := p.next()
+	if err := p.expect(","); err != nil {
+		return err
+	}
+	valueType := p.next()
+	if err := p.expect(">"); err != nil {
+		return err
+	}
+	name := p.next()
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	number, err := strconv.ParseInt(p.next(), 0, 32)
+	if err != nil {
+		return fmt.Errorf("map field %s.%s: bad number", msg.Name, name)
+	}
+	options := p.parseOptions()
+	p.skipStatement()
+
+	entryName := ""
+	for _, part := range strings.Split(name, "_") {
+		if part != "" {
+			entryName += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	entry := &ProtoMessage{
+		Name: qualify(msg.Name, entryName+"Entry"),
+		Fields: []ProtoField{
+			{Name: "key", Number: 1, Type: keyType},
+			{Name: "value", Number: 2, Type: valueType},
+		},
+	}
+	p.schema.Messages[entry.Name] = entry
+	if !IsScalar(valueType) {
+		p.pending = append(p.pending, pendingField{message: entry, index: 1, scope: msg.Name})
+	}
+
+	msg.Fields = append(msg.Fields, ProtoField{
+		Name:     name,
+		Number:   int32(number),
+		Type:     entry.Name,
+		Repeated: true,
+		Options:  options,
+	})
+	return nil
+}
+
+// parseOptions reads [name = value, ...] after a field, if present. Option
+// names keep their parentheses, e.g. (gogoproto.customtype).
+func (p *protoParser) parseOptions() map[string]string {
+	options := make(map[string]string)
+	if p.peek() != "[" {
+		return options
+	}
+	p.next()
+
+	for p.pos < len(p.tokens) {
+		name := ""
+		for tok := p.peek(); tok != "=" && tok != "]" && tok != ""; tok = p.peek() {
+			name += p.next()
+		}
+		if p.peek() == "=" {
+			p.next()
+			value := p.next()
+			if value == "{" {
+				// Aggregate values are not needed, skip them
+				depth := 1
+				for depth > 0 && p.pos < len(p.tokens) {
+					switch p.next() {
+					case "{":
+						depth++
+					case "}":
+						depth--
+					}
+				}
+				value = ""
+			}
+			options[name] = strings.Trim(value, `"'`)
+		}
+		switch p.next() {
+		case ",":
+			continue
+		default:
+			return options
+		}
+	}
+	return options
+}
+
+func (p *protoParser) parseEnum(scope string) error {
+	enum := &ProtoEnum{Name: qualify(scope, p.next()), Values: make(map[int32]string)}
+	p.schema.Enums[enum.Name] = enum
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	for p.pos < len(p.tokens) {
+		switch tok := p.next(); tok {
+		case "}":
+			return nil
+		case ";":
+		case "option", "reserved":
+			p.skipStatement()
+		default:
+			if p.next() != "=" {
+				return fmt.Errorf("enum %s: expected = after %s", enum.Name, tok)
+			}
+			value, err := strconv.ParseInt(p.next(), 0, 32)
+			if err != nil {
+				return fmt.Errorf("enum %s: bad value for %s", enum.Name, tok)
+			}
+			if _, ok := enum.Values[int32(value)]; !ok {
+				enum.Values[int32(value)] = tok
+			}
+			p.parseOptions()
+			p.skipStatement()
+		}
+	}
+	return fmt.Errorf("unterminated enum %s", enum.Name)
+}
+
+// parseService records the request types of a service named Msg
+func (p *protoParser) parseService() error {
+	name := p.next()
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+
+	for p.pos < len(p.tokens) {
+		switch tok := p.next(); tok {
+		case "}":
+			return nil
+		case "rpc":
+			p.next()
+			if err := p.expect("("); err != nil {
+				return err
+			}
+			request := p.next()
+			if request == "stream" {
+				request = p.next()
+			}
+			if name == "Msg" {
+				p.msgs = append(p.msgs, request)
+			}
+			p.skipStatement()
+		case ";":
+		default:
+			p.skipStatement()
+		}
+	}
+	return fmt.Errorf("unterminated service %s", name)
+}
+
+// lookup resolves a type reference the way protoc does, from the innermost
+// scope outwards. Unknown names are returned as written.
+func (p *protoParser) lookup(scope, name string) string {
+	if strings.HasPrefix(name, ".") {
+		return name[1:]
+	}
+	for {
+		candidate := qualify(scope, name)
+		if _, ok := p.schema.Messages[candidate]; ok {
+			return candidate
+		}
+		if _, ok := p.schema.Enums[candidate]; ok {
+			return candidate
+		}
+		if scope == "" {
+			return name
+		}
+		if i := strings.LastIndex(scope, "."); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// resolve qualifies the message and enum types used by the file's fields
+func (p *protoParser) resolve() {
+	for _, pending := range p.pending {
+		field := &pending.message.Fields[pending.index]
+		field.Type = p.lookup(pending.scope, field.Type)
+		if field.Repeated && !p.schema.Packable(field.Type) {
+			field.Packed = false
+		}
+	}
+	for i, msg := range p.msgs {
+		p.msgs[i] = p.lookup(p.pkg, msg)
+	}
+}
+
+// WireType returns the wire type values of type t are encoded with when
+// not packed
+func (s *ProtoSchema) WireType(t string) protowire.Type {
+	switch t {
+	case "int32", "int64", "uint32", "uint64", "sint32", "sint64", "bool":
+		return protowire.VarintType
+	case "fixed32", "sfixed32", "float":
+		return protowire.Fixed32Type
+	case "fixed64", "sfixed64", "double":
+		return protowire.Fixed64Type
+	}
+	if _, ok := s.Enums[t]; ok {
+		return protowire.VarintType
+	}
+	return protowire.BytesType
+}
+
+// FieldByNumber returns the field of msg with the given number, or nil
+func (msg *ProtoMessage) FieldByNumber(number int32) *ProtoField {
+	for i := range msg.Fields {
+		if msg.Fields[i].Number == number {
+			return &msg.Fields[i]
+		}
+	}
+	return nil
+}
+
+// maxDecodeDepth stops decoding deeply nested messages, which a fuzzer
+// produces easily
+const maxDecodeDepth = 16
+
+// Decode turns an encoded message into a tree of field names and values
+// for display. Fields that do not match the schema are kept by number with
+// their raw value, and decoding errors are reported under "!error".
+func (s *ProtoSchema) Decode(name string, data []byte) map[string]interface{} {
+	return s.decode(s.Messages[name], data, 0)
+}
+
+func (s *ProtoSchema) decode(msg *ProtoMessage, data []byte, depth int) map[string]interface{} {
+	out := make(map[string]interface{})
+	fields, err := protowire.ParseFields(data)
+
+	for _, f := range fields {
+		var field *ProtoField
+		if msg != nil {
+			field = msg.FieldByNumber(f.Number)
+		}
+
+		key := fmt.Sprintf("#%d", f.Number)
+		if field != nil {
+			key = field.Name
+		}
+		values := s.decodeField(field, f, depth)
+
+		// Repeated fields, and duplicated singular ones, collect every value
+		if existing, ok := out[key]; ok || (field != nil && field.Repeated) {
+			list, _ := existing.([]interface{})
+			if ok && list == nil {
+				list = []interface{}{existing}
+			}
+			out[key] = append(list, values...)
+		} else if len(values) == 1 {
+			out[key] = values[0]
+		} else {
+			out[key] = values
+		}
+	}
+
+	if err != nil {
+		out["!error"] = err.Error()
+	}
+	return out
+}
+
+// decodeField decodes the value, or the packed values, of one field
+func (s *ProtoSchema) decodeField(field *ProtoField, f protowire.Field, depth int) []interface{} {
+	raw := func() []interface{} {
+		return []interface{}{map[string]interface{}{
+			"!wire_type": int(f.Type),
+			"!raw":       "0x" + hex.EncodeToString(f.Body),
+		}}
+	}
+	if field == nil {
+		return raw()
+	}
+
+	expected := s.WireType(field.Type)
+	if f.Type != expected {
+		if f.Type != protowire.BytesType || !field.Repeated || !s.Packable(field.Type) {
+			return raw()
+		}
+
+		// Packed repeated scalars
+		var values []interface{}
+		content := f.Content()
+		for len(content) > 0 {
+			n := 0
+			switch expected {
+			case protowire.VarintType:
+				_, n, _ = protowire.ConsumeVarint(content)
+			case protowire.Fixed32Type:
+				n = 4
+			case protowire.Fixed64Type:
+				n = 8
+			}
+			if n == 0 || n > len(content) {
+				values = append(values, map[string]interface{}{"!error": "truncated packed value"})
+				break
+			}
+			values = append(values, s.scalarValue(field.Type, content[:n]))
+			content = content[n:]
+		}
+		return values
+	}
+
+	if expected != protowire.BytesType {
+		return []interface{}{s.scalarValue(field.Type, f.Body)}
+	}
+
+	content := f.Content()
+	switch field.Type {
+	case "string":
+		if utf8.Valid(content) {
+			return []interface{}{string(content)}
+		}
+		return []interface{}{map[string]interface{}{"!invalid_utf8": "0x" + hex.EncodeToString(content)}}
+	case "bytes":
+		return []interface{}{"0x" + hex.EncodeToString(content)}
+	}
+
+	nested, ok := s.Messages[field.Type]
+	if !ok || depth >= maxDecodeDepth {
+		return []interface{}{"0x" + hex.EncodeToString(content)}
+	}
+	return []interface{}{s.decode(nested, content, depth+1)}
+}
+
+// scalarValue decodes one varint or fixed-size value of type t
+func (s *ProtoSchema) scalarValue(t string, b []byte) interface{} {
+	switch s.WireType(t) {
+	case protowire.Fixed32Type:
+		v := binary.LittleEndian.Uint32(b)
+		switch t {
+		case "sfixed32":
+			return int32(v)
+		case "float":
+			return jsonFloat(float64(math.Float32frombits(v)))
+		}
+		return v
+	case protowire.Fixed64Type:
+		v := binary.LittleEndian.Uint64(b)
+		switch t {
+		case "sfixed64":
+			return int64(v)
+		case "double":
+			return jsonFloat(math.Float64frombits(v))
+		}
+		return v
+	}
+
+	v, _, err := protowire.ConsumeVarint(b)
+	if err != nil {
+		return map[string]interface{}{"!error": err.Error()}
+	}
+	switch t {
+	case "int32":
+		return int32(v)
+	case "int64":
+		return int64(v)
+	case "uint32":
+		return uint32(v)
+	case "sint32", "sint64":
+		return protowire.DecodeZigZag(v)
+	case "bool":
+		if v > 1 {
+			return v
+		}
+		return v == 1
+	}
+	if enum, ok := s.Enums[t]; ok {
+		if name, ok := enum.Values[int32(v)]; ok {
+			return name
+		}
+	}
+	return v
+}
+
+// jsonFloat keeps values JSON cannot represent displayable
+func jsonFloat(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Sprint(f)
+	}
+	return f
+}
@@ -0,0 +1,289 @@
+package cosmossdk
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldKind classifies a field type by how values of it are generated
+type FieldKind int
+
+const (
+	KindOpaque  FieldKind = iota // A type the fuzzer knows nothing about
+	KindString                   // string
+	KindBool                     // bool
+	KindInt                      // int, int8 ... int64
+	KindUint                     // uint, uint8 ... uint64
+	KindFloat                    // float32, float64
+	KindBytes                    // []byte and byte-slice address types
+	KindBigInt                   // math.Int, a signed 256-bit integer
+	KindBigUint                  // math.Uint, an unsigned 256-bit integer
+	KindDec                      // math.LegacyDec, 18 decimal places
+	KindMessage                  // A struct declared in the module's types/
+	KindSlice                    // Any other slice
+	KindMap                      // A map with string keys
+)
+
+var kindNames = []string{
+	"opaque", "string", "bool", "int", "uint", "float", "bytes",
+	"bigint", "biguint", "dec", "message", "slice", "map",
+}
+
+func (k FieldKind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "unknown"
+}
+
+// TypeSchema describes the type of a field
+type TypeSchema struct {
+	Kind     FieldKind
+	GoType   string      // Type as written in the source, e.g. "math.Int"
+	Bits     int         // Width of fixed-size integers and floats
+	Message  string      // Struct name for KindMessage
+	Elem     *TypeSchema // Element type of slices and map values
+	Nullable bool        // Pointer fields may be null
+}
+
+// FieldSchema is one exported field of a struct
+type FieldSchema struct {
+	Name     string
+	JSONName string
+	Type     *TypeSchema
+}
+
+// StructSchema is a struct type declared in the module's types/ package
+type StructSchema struct {
+	Name   string
+	Fields []FieldSchema
+}
+
+// Schema returns the schema of the named struct, or nil if there is none
+func (m *CosmosModule) Schema(name string) *StructSchema {
+	for i := range m.Schemas {
+		if m.Schemas[i].Name == name {
+			return &m.Schemas[i]
+		}
+	}
+	return nil
+}
+
+// MessageSchema returns the schema of the message a handler processes,
+// matching HandleMsgSend and Send to MsgSend, or nil if none is known
+func (m *CosmosModule) MessageSchema(handler string) *StructSchema {
+	name := strings.TrimPrefix(handler, "Handle")
+	if !strings.HasPrefix(name, "Msg") {
+		name = "Msg" + name
+	}
+	return m.Schema(name)
+}
+
+// discoverStateTypes parses the module's types/ package and records every
+// struct declared there along with its field schema
+func (m *CosmosModule) discoverStateTypes() error {
+	typesDir := filepath.Join(m.Path, "types")
+	if _, err := os.Stat(typesDir); os.IsNotExist(err) {
+		return fmt.Errorf("types directory not found: %s", typesDir)
+	}
+
+	files, err := filepath.Glob(filepath.Join(typesDir, "*.go"))
+	if err != nil {
+		return err
+	}
+
+	// Collect every named type first, so fields can refer to types
+	// declared later or in another file
+	fset := token.NewFileSet()
+	decls := make(map[string]ast.Expr)
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		parsed, err := parser.ParseFile(fset, file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			// Generated or broken files should not stop the campaign
+			continue
+		}
+		for _, decl := range parsed.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if ts.TypeParams == nil {
+					decls[ts.Name.Name] = ts.Type
+				}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(decls))
+	for name, expr := range decls {
+		if _, ok := expr.(*ast.StructType); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	resolver := &schemaResolver{decls: decls}
+	for _, name := range names {
+		m.StateTypes = append(m.StateTypes, name)
+		m.Schemas = append(m.Schemas, resolver.structSchema(name, decls[name].(*ast.StructType)))
+	}
+
+	return nil
+}
+
+// schemaResolver turns type expressions into schemas
+type schemaResolver struct {
+	decls map[string]ast.Expr
+}
+
+func (r *schemaResolver) structSchema(name string, st *ast.StructType) StructSchema {
+	schema := StructSchema{Name: name}
+	for _, field := range st.Fields.List {
+		jsonName := ""
+		if field.Tag != nil {
+			tag := reflect.StructTag(strings.Trim(field.Tag.Value, "`"))
+			jsonName, _, _ = strings.Cut(tag.Get("json"), ",")
+		}
+		if jsonName == "-" {
+			continue
+		}
+
+		// Embedded fields would be inlined by encoding/json, their
+		// schema is not followed
+		for _, ident := range field.Names {
+			if !ident.IsExported() || strings.HasPrefix(ident.Name, "XXX_") {
+				continue
+			}
+			fieldJSON := jsonName
+			if fieldJSON == "" {
+				fieldJSON = ident.Name
+			}
+			schema.Fields = append(schema.Fields, FieldSchema{
+				Name:     ident.Name,
+				JSONName: fieldJSON,
+				Type:     r.typeSchema(field.Type, 0),
+			})
+		}
+	}
+	return schema
+}
+
+// typeSchema classifies a type expression. depth guards against named
+// types that are defined in terms of each other.
+func (r *schemaResolver) typeSchema(expr ast.Expr, depth int) *TypeSchema {
+	schema := &TypeSchema{Kind: KindOpaque, GoType: types.ExprString(expr)}
+	if depth > 8 {
+		return schema
+	}
+
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if kind, bits, ok := basicKind(e.Name); ok {
+			schema.Kind = kind
+			schema.Bits = bits
+			return schema
+		}
+		decl, ok := r.decls[e.Name]
+		if !ok {
+			return schema
+		}
+		if _, ok := decl.(*ast.StructType); ok {
+			schema.Kind = KindMessage
+			schema.Message = e.Name
+			return schema
+		}
+		// A named non-struct type, e.g. type Status int32
+		underlying := r.typeSchema(decl, depth+1)
+		underlying.GoType = schema.GoType
+		return underlying
+
+	case *ast.StarExpr:
+		elem := r.typeSchema(e.X, depth+1)
+		elem.Nullable = true
+		elem.GoType = schema.GoType
+		return elem
+
+	case *ast.ArrayType:
+		if ident, ok := e.Elt.(*ast.Ident); ok && (ident.Name == "byte" || ident.Name == "uint8") {
+			schema.Kind = KindBytes
+			return schema
+		}
+		schema.Kind = KindSlice
+		schema.Elem = r.typeSchema(e.Elt, depth+1)
+		return schema
+
+	case *ast.MapType:
+		if ident, ok := e.Key.(*ast.Ident); !ok || ident.Name != "string" {
+			return schema
+		}
+		schema.Kind = KindMap
+		schema.Elem = r.typeSchema(e.Value, depth+1)
+		return schema
+
+	case *ast.SelectorExpr:
+		schema.Kind = selectorKind(e.Sel.Name)
+		return schema
+	}
+
+	return schema
+}
+
+// basicKind maps Go's predeclared types to kinds and bit widths
+func basicKind(name string) (FieldKind, int, bool) {
+	switch name {
+	case "string":
+		return KindString, 0, true
+	case "bool":
+		return KindBool, 0, true
+	case "int", "int64":
+		return KindInt, 64, true
+	case "int8":
+		return KindInt, 8, true
+	case "int16":
+		return KindInt, 16, true
+	case "int32", "rune":
+		return KindInt, 32, true
+	case "uint", "uint64", "uintptr":
+		return KindUint, 64, true
+	case "uint8", "byte":
+		return KindUint, 8, true
+	case "uint16":
+		return KindUint, 16, true
+	case "uint32":
+		return KindUint, 32, true
+	case "float32":
+		return KindFloat, 32, true
+	case "float64":
+		return KindFloat, 64, true
+	}
+	return KindOpaque, 0, false
+}
+
+// selectorKind recognizes the SDK types that matter for generation by
+// name, whichever package alias they are imported under
+func selectorKind(name string) FieldKind {
+	switch name {
+	case "Int":
+		return KindBigInt
+	case "Uint":
+		return KindBigUint
+	case "Dec", "LegacyDec":
+		return KindDec
+	case "AccAddress", "ValAddress", "ConsAddress", "HexBytes":
+		return KindBytes
+	}
+	return KindOpaque
+}
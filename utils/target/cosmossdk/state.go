@@ -0,0 +1,142 @@
+package cosmossdk
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// StateChange is one store write made by a successful execution
+type StateChange struct {
+	Key   string // Store key, "<module>/<kind>/<id>"
+	Value []byte // Nil if the key was deleted
+}
+
+// ID returns the object identifier at the end of the key
+func (c StateChange) ID() string {
+	return strings.ReplaceAll(c.Key[strings.LastIndexByte(c.Key, '/')+1:], "%2F", "/")
+}
+
+// Kind returns the kind of object the key stores
+func (c StateChange) Kind() string {
+	parts := strings.Split(c.Key, "/")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[len(parts)-2]
+}
+
+// deletingHandler reports whether a handler removes the objects its
+// message names rather than creating them
+func deletingHandler(handler string) bool {
+	for _, verb := range []string{"Delete", "Remove", "Burn", "Cancel", "Close", "Undelegate"} {
+		if strings.Contains(handler, verb) {
+			return true
+		}
+	}
+	return false
+}
+
+// objectField reports whether a message field identifies a store object,
+// such as an address, a denom or a proposal id
+func objectField(name string) bool {
+	lower := strings.ToLower(name)
+	switch lower {
+	case "name", "validator", "proposal", "pool", "creator", "sender", "owner":
+		return true
+	}
+	return strings.HasSuffix(lower, "address") || strings.HasSuffix(lower, "denom") ||
+		strings.HasSuffix(lower, "id")
+}
+
+// simulateWrites derives the store writes a handler would make for a
+// payload. Structured messages write one key per object they name, holding
+// the JSON of the object; raw payloads write a single key derived from their
+// first bytes.
+func (m *CosmosModule) simulateWrites(handler string, payload []byte) []StateChange {
+	deleting := deletingHandler(handler)
+
+	if len(payload) > 0 && payload[0] == '{' {
+		var message interface{}
+		if err := json.Unmarshal(payload, &message); err == nil {
+			var changes []StateChange
+			collectObjects(message, func(kind, id string, object []byte) {
+				if deleting {
+					object = nil
+				}
+				changes = append(changes, StateChange{Key: m.Name + "/" + kind + "/" + id, Value: object})
+			})
+			return changes
+		}
+	}
+
+	if len(payload) == 0 {
+		return nil
+	}
+	value := append([]byte(nil), payload...)
+	if deleting {
+		value = nil
+	}
+	id := payload[:min(len(payload), 8)]
+	return []StateChange{{Key: m.Name + "/" + handler + "/" + hex.EncodeToString(id), Value: value}}
+}
+
+// simulateEvents derives the events a successful execution emits: the
+// message action and one event per object written
+func (m *CosmosModule) simulateEvents(handler string, delta []StateChange) []string {
+	events := []string{"message.action=" + handler, "message.module=" + m.Name}
+	for _, change := range delta {
+		kind := "write"
+		if change.Value == nil {
+			kind = "delete"
+		}
+		events = append(events, m.Name+"."+kind+"="+change.Key)
+	}
+	return events
+}
+
+// simulateGas derives the gas a handler consumes, after the SDK's KV store
+// gas config: a flat cost plus a cost per byte of the transaction and of
+// every key and value written
+func simulateGas(input []byte, delta []StateChange) uint64 {
+	gas := uint64(1000) + 10*uint64(len(input))
+	for _, change := range delta {
+		if change.Value == nil {
+			gas += 1000
+			continue
+		}
+		gas += 2000 + 30*uint64(len(change.Key)+len(change.Value))
+	}
+	return gas
+}
+
+// collectObjects calls found for every non-empty string in a decoded
+// message whose field identifies an object, in a stable order, along with
+// the JSON of the object the field belongs to
+func collectObjects(value interface{}, found func(kind, id string, object []byte)) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var object []byte
+		for _, key := range keys {
+			if id, ok := v[key].(string); ok && id != "" && objectField(key) {
+				if object == nil {
+					object, _ = json.Marshal(v)
+				}
+				// Keys are '/' separated, so ids such as ibc/... are escaped
+				found(strings.ToLower(key), escapeID(id), object)
+				continue
+			}
+			collectObjects(v[key], found)
+		}
+	case []interface{}:
+		for _, elem := range v {
+			collectObjects(elem, found)
+		}
+	}
+}